@@ -0,0 +1,152 @@
+package wf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Compensator is implemented by runnables that can undo the work of a completed run.
+// Step, Pipeline and Dag implement it.
+//
+// When a Pipeline or Dag fails, it compensates its completed nodes, in reverse
+// completion order. A completed Pipeline or Dag compensates its own completed nodes
+// the same way, so compensation reaches the steps of nested workflows.
+type Compensator interface {
+	// Compensate undoes the work of a completed run.
+	// It returns the outcome of every compensation handler that was executed.
+	Compensate(ctx context.Context, data map[string]any) (context.Context, map[string]any, []CompensationResult)
+}
+
+// CompensationResult is the outcome of the compensation handler of a single step
+type CompensationResult struct {
+	// StepID is the ID of the compensated step
+	StepID string
+
+	// StepName is the name of the compensated step
+	StepName string
+
+	// Err is the error returned by the compensation handler, nil if it succeeded
+	Err error
+}
+
+// CompensationError is returned by a Pipeline or Dag that failed and ran
+// the compensation handlers of its completed steps.
+//
+// It wraps the error that caused the failure, and the errors of the failed
+// compensations, so errors.Is and errors.As work with any of them.
+type CompensationError struct {
+	// Cause is the error that made the workflow fail
+	Cause error
+
+	// Results holds the outcome of every compensation, in the order they ran
+	Results []CompensationResult
+}
+
+// Error returns a message with the cause and the failed compensations
+func (e *CompensationError) Error() string {
+	failed := e.Failed()
+	if len(failed) == 0 {
+		return fmt.Sprintf("%v (compensated %d steps)", e.Cause, len(e.Results))
+	}
+
+	messages := make([]string, 0, len(failed))
+	for _, result := range failed {
+		messages = append(messages, fmt.Sprintf("%s: %v", compensationStepName(result), result.Err))
+	}
+
+	return fmt.Sprintf("%v (compensation failed: %s)", e.Cause, strings.Join(messages, "; "))
+}
+
+// Unwrap returns the cause, followed by the errors of the failed compensations
+func (e *CompensationError) Unwrap() []error {
+	errs := []error{e.Cause}
+	for _, result := range e.Failed() {
+		errs = append(errs, result.Err)
+	}
+	return errs
+}
+
+// Failed returns the compensations that returned an error
+func (e *CompensationError) Failed() []CompensationResult {
+	failed := []CompensationResult{}
+	for _, result := range e.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// compensationStepName returns the name of the compensated step, or its ID if it has no name
+func compensationStepName(result CompensationResult) string {
+	if result.StepName != "" {
+		return result.StepName
+	}
+	return result.StepID
+}
+
+// compensateNodes compensates the completed nodes recorded in the state, in reverse completion order.
+// The nodes are compensated using their own states, from the given state.
+// Every compensation is recorded in the state, and the results are returned.
+func compensateNodes(ctx context.Context, data map[string]any, state StateInterface, lookup func(id string) (RunnableInterface, bool)) (context.Context, map[string]any, []CompensationResult) {
+	results := []CompensationResult{}
+
+	completedSteps := state.GetCompletedSteps()
+	for i := len(completedSteps) - 1; i >= 0; i-- {
+		node, ok := lookup(completedSteps[i])
+		if !ok {
+			continue
+		}
+
+		var nodeResults []CompensationResult
+		if r, ok := node.(runner); ok {
+			nodeState := stateChild(state, node.GetID())
+			if nodeState == nil {
+				continue
+			}
+			ctx, data, nodeResults = r.compensateWithState(ctx, data, nodeState)
+		} else if compensator, ok := node.(Compensator); ok {
+			ctx, data, nodeResults = compensator.Compensate(ctx, data)
+		}
+
+		for _, result := range nodeResults {
+			addStateCompensation(state, newStateCompensation(result))
+		}
+		results = append(results, nodeResults...)
+	}
+
+	return ctx, data, results
+}
+
+// compensateFailure compensates the completed nodes of a failed workflow.
+// The compensations run with a context that is not canceled, even if the
+// failure was caused by a timeout or a cancellation.
+//
+// If any compensation ran, the error is wrapped in a CompensationError.
+// An error that already is a CompensationError (from a nested workflow)
+// is extended with the new results.
+func compensateFailure(ctx context.Context, data map[string]any, state StateInterface, lookup func(id string) (RunnableInterface, bool), err error) (map[string]any, error) {
+	_, data, results := compensateNodes(context.WithoutCancel(ctx), data, state, lookup)
+	if len(results) == 0 {
+		return data, err
+	}
+
+	if compensationErr, ok := err.(*CompensationError); ok {
+		return data, &CompensationError{
+			Cause:   compensationErr.Cause,
+			Results: append(append([]CompensationResult{}, compensationErr.Results...), results...),
+		}
+	}
+
+	return data, &CompensationError{Cause: err, Results: results}
+}
+
+// newStateCompensation converts a compensation result to its state record
+func newStateCompensation(result CompensationResult) StateCompensation {
+	compensation := StateCompensation{StepID: result.StepID}
+	if result.Err != nil {
+		compensation.Error = result.Err.Error()
+	}
+	return compensation
+}
@@ -0,0 +1,148 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// newCompensatedStep creates a step recording its execution and compensation in the data
+func newCompensatedStep(name string, err error, compensationErr error) StepInterface {
+	return NewStep(
+		WithName(name),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			if err != nil {
+				return ctx, data, err
+			}
+			data["executed"] = append(data["executed"].([]string), name)
+			return ctx, data, nil
+		}),
+		WithCompensation(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			if compensationErr != nil {
+				return ctx, data, compensationErr
+			}
+			data["compensated"] = append(data["compensated"].([]string), name)
+			return ctx, data, nil
+		}),
+	)
+}
+
+func newCompensationData() map[string]any {
+	return map[string]any{
+		"executed":    []string{},
+		"compensated": []string{},
+	}
+}
+
+func Test_Pipeline_Compensation(t *testing.T) {
+	errPayment := errors.New("payment declined")
+
+	reserve := newCompensatedStep("reserve", nil, nil)
+	invoice := newCompensatedStep("invoice", nil, nil)
+	pay := newCompensatedStep("pay", errPayment, nil)
+	ship := newCompensatedStep("ship", nil, nil)
+
+	pipeline := NewPipeline(WithRunnables(reserve, invoice, pay, ship))
+
+	_, data, err := pipeline.Run(context.Background(), newCompensationData())
+	if !errors.Is(err, errPayment) {
+		t.Fatalf("Expected payment error, got %v", err)
+	}
+
+	var compensationErr *CompensationError
+	if !errors.As(err, &compensationErr) {
+		t.Fatalf("Expected a CompensationError, got %T", err)
+	}
+	if len(compensationErr.Results) != 2 || len(compensationErr.Failed()) != 0 {
+		t.Errorf("Expected 2 successful compensations, got %+v", compensationErr.Results)
+	}
+
+	if compensated := data["compensated"].([]string); !slices.Equal(compensated, []string{"invoice", "reserve"}) {
+		t.Errorf("Expected compensation in reverse completion order, got %v", compensated)
+	}
+
+	compensations := pipeline.GetState().(CompensationsState).GetCompensations()
+	if len(compensations) != 2 || compensations[0].StepID != invoice.GetID() || compensations[1].StepID != reserve.GetID() {
+		t.Errorf("Expected compensations to be recorded in the state, got %+v", compensations)
+	}
+	if !pipeline.IsFailed() {
+		t.Errorf("Expected pipeline to be failed, got %s", pipeline.GetState().GetStatus())
+	}
+}
+
+func Test_Dag_Compensation_Nested(t *testing.T) {
+	errShipping := errors.New("carrier unavailable")
+	errRelease := errors.New("stock service down")
+
+	reserve := newCompensatedStep("reserve", nil, errRelease)
+	invoice := newCompensatedStep("invoice", nil, nil)
+	billing := NewPipeline(WithName("billing"), WithRunnables(invoice))
+	ship := newCompensatedStep("ship", errShipping, nil)
+
+	dag := NewDag(
+		WithRunnables(reserve, billing, ship),
+		WithDependency(billing, reserve),
+		WithDependency(ship, billing),
+	)
+
+	_, data, err := dag.Run(context.Background(), newCompensationData())
+	if !errors.Is(err, errShipping) {
+		t.Fatalf("Expected shipping error, got %v", err)
+	}
+	if !errors.Is(err, errRelease) {
+		t.Errorf("Expected the failed compensation error to be wrapped, got %v", err)
+	}
+
+	var compensationErr *CompensationError
+	if !errors.As(err, &compensationErr) {
+		t.Fatalf("Expected a CompensationError, got %T", err)
+	}
+	if !errors.Is(compensationErr.Cause, errShipping) {
+		t.Errorf("Expected the cause to be the shipping error, got %v", compensationErr.Cause)
+	}
+
+	// The step inside the nested pipeline is compensated first
+	if len(compensationErr.Results) != 2 || compensationErr.Results[0].StepID != invoice.GetID() || compensationErr.Results[1].StepID != reserve.GetID() {
+		t.Errorf("Expected invoice then reserve to be compensated, got %+v", compensationErr.Results)
+	}
+	if message := err.Error(); message != compensationErr.Cause.Error()+" (compensation failed: reserve: stock service down)" {
+		t.Errorf("Unexpected error message: %s", message)
+	}
+
+	failed := compensationErr.Failed()
+	if len(failed) != 1 || failed[0].StepID != reserve.GetID() {
+		t.Errorf("Expected the reserve compensation to fail, got %+v", failed)
+	}
+
+	if compensated := data["compensated"].([]string); !slices.Equal(compensated, []string{"invoice"}) {
+		t.Errorf("Expected only invoice to be compensated successfully, got %v", compensated)
+	}
+
+	compensations := dag.GetState().(CompensationsState).GetCompensations()
+	if len(compensations) != 2 || compensations[1].Error != errRelease.Error() {
+		t.Errorf("Expected the failed compensation to be recorded in the state, got %+v", compensations)
+	}
+}
+
+func Test_Dag_Compensation_NoHandlers(t *testing.T) {
+	errFailed := errors.New("step2 failed")
+
+	step1 := NewStep(WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	step2 := NewStep(WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, errFailed
+	}))
+
+	dag := NewDag(
+		WithRunnables(step1, step2),
+		WithDependency(step2, step1),
+	)
+
+	_, _, err := dag.Run(context.Background(), map[string]any{})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Err != errFailed {
+		t.Errorf("Expected the error of the step when nothing was compensated, got %v", err)
+	}
+}
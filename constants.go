@@ -9,4 +9,25 @@ const (
 	StateStatusPaused   = "paused"
 	StateStatusComplete = "complete"
 	StateStatusFailed   = "failed"
+	StateStatusSkipped  = "skipped"
+)
+
+// StateErrorReason represents the reason a workflow failed
+type StateErrorReason string
+
+const (
+	// State error reason constants
+	StateErrorReasonError    = "error"    // a handler returned an error
+	StateErrorReasonTimeout  = "timeout"  // the timeout expired (see ErrTimeout)
+	StateErrorReasonCanceled = "canceled" // the context was canceled
+)
+
+// FailurePolicy tells a DAG what to do when one of its nodes fails
+type FailurePolicy string
+
+const (
+	// Failure policy constants
+	FailurePolicyFailFast    = "fail_fast"    // stop starting nodes after the first failure (the default)
+	FailurePolicyContinue    = "continue"     // run every node not downstream of a failed node
+	FailurePolicyContinueAll = "continue_all" // run every node, as if the failed nodes had completed
 )
@@ -3,11 +3,26 @@ package wf
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"maps"
+	"reflect"
 	"slices"
+	"time"
 
 	"github.com/dracory/uid"
 )
 
+// DependencyCondition decides, at run time, whether a node with a conditional
+// dependency should run. It receives the data as produced by the nodes that
+// completed so far.
+type DependencyCondition func(ctx context.Context, data map[string]any) bool
+
+// conditionalDependency is a set of dependencies guarded by a condition
+type conditionalDependency struct {
+	condition     DependencyCondition
+	dependencyIDs []string
+}
+
 type Dag struct {
 	// id of the dag
 	id string
@@ -24,6 +39,33 @@ type Dag struct {
 	// dependencies (DependentID, DependencyIDs []string)
 	dependencies map[string][]string
 
+	// conditional dependencies (DependentID, []conditionalDependency)
+	conditionalDependencies map[string][]conditionalDependency
+
+	// nodes that still run when one of their dependencies was skipped (ID, true)
+	runOnSkip map[string]bool
+
+	// maximum duration of a run, zero means no timeout
+	timeout time.Duration
+
+	// maximum number of nodes running at the same time (less than 1 means no limit)
+	maxConcurrency int
+
+	// what to do when a node fails
+	failurePolicy FailurePolicy
+
+	// store the state of a run is saved to, nil if it is not saved
+	store StateStore
+
+	// listeners of the events of a run
+	listeners []EventListener
+
+	// middlewares wrapping the runs
+	middlewares []Middleware
+
+	// logger of the runs, nil if they are not logged
+	logger *slog.Logger
+
 	// current state of the workflow
 	state StateInterface
 }
@@ -36,7 +78,12 @@ func NewDag(opts ...interface{}) DagInterface {
 		runnableSequence: make([]string, 0),
 		runnables:        make(map[string]RunnableInterface),
 		dependencies:     make(map[string][]string),
+		runOnSkip:        make(map[string]bool),
+		maxConcurrency:   1,
+		failurePolicy:    FailurePolicyFailFast,
 		state:            NewState(),
+
+		conditionalDependencies: make(map[string][]conditionalDependency),
 	}
 
 	// Apply all options
@@ -50,6 +97,20 @@ func NewDag(opts ...interface{}) DagInterface {
 			o(dag) // Handles WithRunnables
 		case func(DependencyAdder):
 			o(dag) // Handles WithDependency
+		case func(ConditionalDependencyAdder):
+			o(dag) // Handles WithDependencyIf
+		case func(StateStoreSetter):
+			o(dag) // Handles WithStateStore
+		case func(TimeoutSetter):
+			o(dag) // Handles WithTimeout
+		case func(ListenerAdder):
+			o(dag) // Handles WithListener
+		case func(MiddlewareAdder):
+			o(dag) // Handles WithMiddleware
+		case func(LoggerSetter):
+			o(dag) // Handles WithLogger
+		case func(DagInterface):
+			o(dag) // Handles WithMaxConcurrency and other Dag-specific options
 		}
 	}
 
@@ -72,6 +133,77 @@ func (d *Dag) SetName(name string) {
 	d.name = name
 }
 
+// GetTimeout returns the maximum duration of the DAG, zero if there is none
+func (d *Dag) GetTimeout() time.Duration {
+	return d.timeout
+}
+
+// SetTimeout sets the maximum duration of the DAG. Zero or less disables the timeout.
+func (d *Dag) SetTimeout(timeout time.Duration) {
+	d.timeout = timeout
+}
+
+// GetMaxConcurrency returns the maximum number of nodes that may run at the same time
+func (d *Dag) GetMaxConcurrency() int {
+	return d.maxConcurrency
+}
+
+// SetMaxConcurrency sets the maximum number of nodes that may run at the same time.
+// A value less than 1 removes the limit.
+func (d *Dag) SetMaxConcurrency(n int) {
+	d.maxConcurrency = n
+}
+
+// GetFailurePolicy returns what the DAG does when a node fails
+func (d *Dag) GetFailurePolicy() FailurePolicy {
+	return d.failurePolicy
+}
+
+// SetFailurePolicy sets what the DAG does when a node fails
+func (d *Dag) SetFailurePolicy(policy FailurePolicy) {
+	d.failurePolicy = policy
+}
+
+// GetStateStore returns the store the state of a run is saved to, nil if there is none
+func (d *Dag) GetStateStore() StateStore {
+	return d.store
+}
+
+// SetStateStore sets the store the state of a run is saved to, after every completed node
+func (d *Dag) SetStateStore(store StateStore) {
+	d.store = store
+}
+
+// GetListeners returns the listeners of the events of the DAG and its nodes
+func (d *Dag) GetListeners() []EventListener {
+	return d.listeners
+}
+
+// AddListener adds a listener of the events of the DAG and its nodes
+func (d *Dag) AddListener(listener EventListener) {
+	d.listeners = append(d.listeners, listener)
+}
+
+// GetMiddlewares returns the middlewares wrapping the runs of the DAG and its nodes
+func (d *Dag) GetMiddlewares() []Middleware {
+	return d.middlewares
+}
+
+// AddMiddleware adds a middleware wrapping the runs of the DAG and its nodes
+func (d *Dag) AddMiddleware(middleware Middleware) {
+	d.middlewares = append(d.middlewares, middleware)
+}
+
+// GetLogger returns the logger of the DAG and its nodes, nil if there is none
+func (d *Dag) GetLogger() *slog.Logger {
+	return d.logger
+}
+
+// SetLogger sets the logger of the DAG and its nodes
+func (d *Dag) SetLogger(logger *slog.Logger) {
+	d.logger = logger
+}
+
 // RunnableAdd adds a single node to the DAG.
 func (d *Dag) RunnableAdd(node ...RunnableInterface) {
 	for _, n := range node {
@@ -123,6 +255,7 @@ func (d *Dag) RunnableRemove(node RunnableInterface) bool {
 
 	// Remove dependencies
 	delete(d.dependencies, id)
+	delete(d.runOnSkip, id)
 
 	// Remove this node from other nodes' dependencies
 	for depID, depList := range d.dependencies {
@@ -134,6 +267,18 @@ func (d *Dag) RunnableRemove(node RunnableInterface) bool {
 		}
 	}
 
+	// Remove conditional dependencies
+	delete(d.conditionalDependencies, id)
+
+	// Remove this node from other nodes' conditional dependencies
+	for depID, conditions := range d.conditionalDependencies {
+		for i, condition := range conditions {
+			d.conditionalDependencies[depID][i].dependencyIDs = slices.DeleteFunc(condition.dependencyIDs, func(dependencyID string) bool {
+				return dependencyID == id
+			})
+		}
+	}
+
 	return true
 }
 
@@ -148,124 +293,372 @@ func (d *Dag) RunnableList() []RunnableInterface {
 
 // Run executes all nodes in the DAG in the correct order
 func (d *Dag) Run(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
-	// If we have a saved state, use it
-	if d.state.GetStatus() == StateStatus(StateStatusPaused) {
-		return d.resumeFromState(ctx, data)
+	// Initialize new state, unless a saved state is resumed
+	if d.state.GetStatus() != StateStatus(StateStatusPaused) {
+		d.state = NewState()
+	}
+
+	return runDefinition(ctx, data, d, d.state)
+}
+
+// NewRun creates a run of the DAG, with its own ID and state.
+// The DAG and its nodes are not modified by the run, so a DAG can be
+// built once, and run many times concurrently.
+func (d *Dag) NewRun(ctx context.Context, data map[string]any) RunInterface {
+	return newRun(d, ctx, data)
+}
+
+// ResumeRun creates a run from the state saved in the state store, with the given ID.
+// The nodes completed before the last checkpoint are not run again.
+func (d *Dag) ResumeRun(ctx context.Context, runID string, data map[string]any) (RunInterface, error) {
+	return resumeRun(ctx, d, runID, data)
+}
+
+// Pause pauses the workflow execution
+func (d *Dag) Pause() error {
+	return pause(d.state)
+}
+
+// Resume resumes the workflow execution from the last saved state
+func (d *Dag) Resume(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+	if d.state.GetStatus() != StateStatus(StateStatusPaused) {
+		return ctx, data, errors.New("workflow is not paused")
 	}
+	return runDefinition(ctx, data, d, d.state)
+}
 
-	// Initialize new state
-	d.state = NewState()
-	d.state.SetStatus(StateStatus(StateStatusRunning))
-	d.state.SetWorkflowData(data)
+// runWithState runs the DAG with the given state. A paused state is resumed.
+func (d *Dag) runWithState(ctx context.Context, data map[string]any, state StateInterface, ex *execution) (context.Context, map[string]any, error) {
+	if state.GetStatus() == StateStatus(StateStatusPaused) {
+		// Update data with saved state
+		savedData := state.GetWorkflowData()
+		for k, v := range savedData {
+			data[k] = v
+		}
+		state.SetStatus(StateStatus(StateStatusRunning))
+	} else {
+		state.SetStatus(StateStatus(StateStatusRunning))
+		state.SetWorkflowData(data)
+	}
 
 	// Build dependency graph
-	graph := buildDependencyGraph(d.runnables, d.dependencies)
+	graph := buildDependencyGraph(d.runnables, d.dependencyIDs())
 
 	// Get execution order
 	order, err := topologicalSort(graph)
 	if err != nil {
-		d.state.SetStatus(StateStatus(StateStatusFailed))
+		recordFailure(state, err)
 		return ctx, data, err
 	}
 
-	// Execute steps in order
-	for _, node := range order {
-		// Skip completed steps
-		if slices.Contains(d.state.GetCompletedSteps(), node.GetID()) {
+	// Execute remaining steps
+	return d.runNodes(ctx, data, graph, order, state, ex)
+}
+
+// dagNodeResult is the outcome of a single node run by the DAG scheduler
+type dagNodeResult struct {
+	node    RunnableInterface
+	ctx     context.Context
+	start   map[string]any // data the node started with, when nodes run in parallel
+	data    map[string]any
+	skipped bool
+	err     error
+}
+
+// runNodes executes the nodes that are not completed yet.
+//
+// Every node whose dependencies are completed or skipped is started, in
+// topological order, as long as fewer than maxConcurrency nodes are running.
+// With a limit of 1 the nodes run one after another and share the data map,
+// as before. With a higher limit each node receives a copy of the data, and
+// its result is merged back into the shared data once the node completes.
+//
+// A node is skipped, instead of started, when one of its dependencies was
+// skipped (unless the node was added with RunOnSkipAdd), or when one of its
+// conditional dependencies does not hold for the current data.
+//
+// The state is only updated from the calling goroutine, so CompletedSteps and
+// CurrentStepID stay consistent while nodes run in parallel.
+// On the first failure, or when the timeout expires, no new nodes are started,
+// the running nodes are awaited and the error is returned. With the continue
+// failure policies, the other nodes keep running, and the errors of all the
+// failed nodes are joined once no node is left to run.
+// When the workflow is paused, no new nodes are started either, the running
+// nodes are awaited, the state is saved and ErrPaused is returned.
+func (d *Dag) runNodes(ctx context.Context, data map[string]any, graph map[RunnableInterface][]RunnableInterface, order []RunnableInterface, state StateInterface, ex *execution) (context.Context, map[string]any, error) {
+	runCtx, cancel := withTimeout(ctx, d.timeout)
+	defer cancel()
+
+	parentCtx := ctx
+	ctx = runCtx
+
+	limit := d.maxConcurrency
+	if limit < 1 || limit > len(order) {
+		limit = len(order)
+	}
+	parallel := limit > 1
+	if parallel && data == nil {
+		data = make(map[string]any)
+	}
+
+	completed := make(map[string]bool, len(order))
+	for _, id := range state.GetCompletedSteps() {
+		completed[id] = true
+	}
+
+	skipped := make(map[string]bool, len(order))
+	for _, id := range skippedSteps(state) {
+		skipped[id] = true
+	}
+
+	// The nodes which failed before a pause run again
+	if s, ok := state.(FailedStepsState); ok && len(s.GetFailedSteps()) > 0 {
+		s.SetFailedSteps(nil)
+	}
+	failed := make(map[string]bool, len(order))
+	failures := []error{}
+
+	// With FailurePolicyContinueAll, the failed nodes count as done for their dependents
+	resolved := func(dependencies []RunnableInterface) bool {
+		for _, dep := range dependencies {
+			id := dep.GetID()
+			if !completed[id] && !skipped[id] && !(failed[id] && d.failurePolicy == FailurePolicyContinueAll) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// When nodes run in parallel, each node starts with the context returned by
+	// its last dependency, and the DAG returns the context of its last node, in
+	// the order of the nodes, so the contexts do not depend on which node ends first
+	contexts := make(map[string]context.Context, len(order))
+	nodeContext := func(node RunnableInterface) context.Context {
+		if !parallel {
+			return ctx
+		}
+		nodeCtx := runCtx
+		for _, dep := range graph[node] {
+			if depCtx, ok := contexts[dep.GetID()]; ok {
+				nodeCtx = depCtx
+			}
+		}
+		return nodeCtx
+	}
+
+	started := make(map[string]bool, len(order))
+	results := make(chan dagNodeResult, len(order))
+	running := 0
+	var runErr error
+	paused := false
+
+	for {
+		// Stop starting nodes, if the timeout expired or the context was canceled
+		// (unless a failed node already reported it)
+		if runErr == nil && ctx.Err() != nil {
+			cause := context.Cause(ctx)
+			if !slices.ContainsFunc(failures, func(err error) bool { return errors.Is(err, cause) }) {
+				runErr = cause
+			}
+		}
+
+		// Stop starting nodes, if the workflow was paused
+		if !paused && ex.pauseRequested(state) {
+			paused = true
+		}
+
+		// Start every ready node, while there is capacity.
+		// Skipping a node may make other nodes ready, so repeat until nothing changes.
+		for changed := true; changed; {
+			changed = false
+
+			for _, node := range order {
+				if runErr != nil || ctx.Err() != nil || paused || running >= limit {
+					break
+				}
+
+				id := node.GetID()
+				if completed[id] || skipped[id] || started[id] || failed[id] || !resolved(graph[node]) {
+					continue
+				}
+
+				// Skip the node, if a dependency was skipped or its conditions are not met
+				if (!d.runOnSkip[id] && dependenciesSkipped(graph[node], skipped)) || !d.conditionsMet(nodeContext(node), id, data) {
+					skipped[id] = true
+					markSkipped(state, node, ex)
+					addSkippedStep(state, id)
+					changed = true
+					continue
+				}
+
+				// Update current step
+				started[id] = true
+				running++
+				state.SetCurrentStepID(id)
+
+				var start map[string]any
+				nodeData := data
+				if parallel {
+					start = maps.Clone(data)
+					nodeData = maps.Clone(data)
+				}
+
+				go func(node RunnableInterface, ctx context.Context, data map[string]any, nodeState StateInterface) {
+					ctx, data, skipped, err := runNode(ctx, data, node, nodeState, ex)
+					results <- dagNodeResult{node: node, ctx: ctx, start: start, data: data, skipped: skipped, err: err}
+				}(node, nodeContext(node), nodeData, nodeState(state, node, ex))
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+
+		if parallel {
+			contexts[result.node.GetID()] = result.ctx
+			mergeNodeData(data, result.start, result.data)
+		} else {
+			ctx = result.ctx
+			data = result.data
+		}
+
+		// The node was paused, so is the DAG
+		if errors.Is(result.err, ErrPaused) {
+			paused = true
 			continue
 		}
 
-		// Update current step
-		d.state.SetCurrentStepID(node.GetID())
+		if result.err != nil {
+			err := timeoutError(runCtx, result.err)
+			failed[result.node.GetID()] = true
+			failures = append(failures, err)
+			addFailedStep(state, result.node.GetID())
 
-		// Execute step
-		ctx, data, err = node.Run(ctx, data)
-		if err != nil {
-			d.state.SetStatus(StateStatus(StateStatusFailed))
-			return ctx, data, err
+			if !d.continueOnFailure() {
+				if runErr == nil {
+					runErr = err
+				}
+				continue
+			}
+		} else if result.skipped {
+			// The node decided to skip itself
+			skipped[result.node.GetID()] = true
+			addSkippedStep(state, result.node.GetID())
+		} else {
+			// Mark step as completed
+			completed[result.node.GetID()] = true
+			state.AddCompletedStep(result.node.GetID())
 		}
+		state.SetWorkflowData(data)
 
-		// Mark step as completed
-		d.state.AddCompletedStep(node.GetID())
-		d.state.SetWorkflowData(data)
+		if err := ex.checkpoint(ctx); err != nil && runErr == nil {
+			runErr = err
+		}
 	}
 
-	d.state.SetStatus(StateStatus(StateStatusComplete))
-	return ctx, data, nil
-}
+	if parallel {
+		for _, node := range order {
+			if nodeCtx, ok := contexts[node.GetID()]; ok {
+				ctx = nodeCtx
+			}
+		}
+	}
 
-// Pause pauses the workflow execution
-func (d *Dag) Pause() error {
-	if d.state.GetStatus() != StateStatus(StateStatusRunning) {
-		return errors.New("workflow is not running")
+	// Nodes are left to run, after the pause
+	if runErr == nil && paused && d.nodesLeft(order, graph, completed, skipped, failed) {
+		ctx = detachTimeout(parentCtx, runCtx, ctx)
+		return ctx, data, recordPause(ctx, data, state, ex)
 	}
-	d.state.SetStatus(StateStatus(StateStatusPaused))
-	return nil
-}
 
-// Resume resumes the workflow execution from the last saved state
-func (d *Dag) Resume(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
-	if d.state.GetStatus() != StateStatus(StateStatusPaused) {
-		return ctx, data, errors.New("workflow is not paused")
+	// With the continue policies, the errors of all the failed nodes are returned
+	if d.continueOnFailure() {
+		runErr = errors.Join(append(failures, runErr)...)
 	}
-	return d.resumeFromState(ctx, data)
-}
 
-// resumeFromState resumes the workflow from the saved state
-func (d *Dag) resumeFromState(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
-	// Update data with saved state
-	savedData := d.state.GetWorkflowData()
-	for k, v := range savedData {
-		data[k] = v
+	if runErr != nil {
+		ctx = detachTimeout(parentCtx, runCtx, ctx)
+		data, runErr = compensateFailure(ctx, data, state, d.findNode, runErr)
+		recordFailure(state, runErr)
+		return ctx, data, runErr
 	}
 
-	// Build dependency graph
-	graph := buildDependencyGraph(d.runnables, d.dependencies)
+	recordCompletion(state)
+	return detachTimeout(parentCtx, runCtx, ctx), data, nil
+}
 
-	// Get execution order
-	order, err := topologicalSort(graph)
-	if err != nil {
-		d.state.SetStatus(StateStatus(StateStatusFailed))
-		return ctx, data, err
+// mergeNodeData copies to the data of the DAG the keys a node running in parallel
+// added, changed or deleted, compared with the data it started with, so the node
+// does not overwrite the keys updated by the nodes that ended before it.
+// A node returning no data changes nothing.
+func mergeNodeData(data map[string]any, start map[string]any, result map[string]any) {
+	if result == nil {
+		return
 	}
 
-	// Find the current step
-	currentStepID := d.state.GetCurrentStepID()
-	var currentStepIndex int
-	for i, node := range order {
-		if node.GetID() == currentStepID {
-			currentStepIndex = i
-			break
+	for key, value := range result {
+		if previous, ok := start[key]; !ok || !reflect.DeepEqual(previous, value) {
+			data[key] = value
 		}
 	}
 
-	// Execute remaining steps
-	d.state.SetStatus(StateStatus(StateStatusRunning))
-	for i := currentStepIndex; i < len(order); i++ {
-		node := order[i]
+	for key := range start {
+		if _, ok := result[key]; !ok {
+			delete(data, key)
+		}
+	}
+}
+
+// continueOnFailure checks whether the failure policy runs the other nodes, after a node failed
+func (d *Dag) continueOnFailure() bool {
+	return d.failurePolicy == FailurePolicyContinue || d.failurePolicy == FailurePolicyContinueAll
+}
+
+// nodesLeft checks whether some nodes may still run: nodes that are not done,
+// and, unless the failure policy is FailurePolicyContinueAll, that are not
+// downstream of a failed node
+func (d *Dag) nodesLeft(order []RunnableInterface, graph map[RunnableInterface][]RunnableInterface, completed map[string]bool, skipped map[string]bool, failed map[string]bool) bool {
+	blocked := make(map[string]bool, len(order))
+
+	for _, node := range order {
+		id := node.GetID()
+		if completed[id] || skipped[id] || failed[id] {
+			continue
+		}
 
-		// Skip completed steps
-		if slices.Contains(d.state.GetCompletedSteps(), node.GetID()) {
+		if d.failurePolicy != FailurePolicyContinueAll && slices.ContainsFunc(graph[node], func(dep RunnableInterface) bool {
+			return failed[dep.GetID()] || blocked[dep.GetID()]
+		}) {
+			blocked[id] = true
 			continue
 		}
 
-		// Update current step
-		d.state.SetCurrentStepID(node.GetID())
+		return true
+	}
 
-		// Execute step
-		ctx, data, err = node.Run(ctx, data)
-		if err != nil {
-			d.state.SetStatus(StateStatus(StateStatusFailed))
-			return ctx, data, err
-		}
+	return false
+}
 
-		// Mark step as completed
-		d.state.AddCompletedStep(node.GetID())
-		d.state.SetWorkflowData(data)
+// Compensate undoes the work of a completed DAG, by compensating
+// its completed nodes in reverse completion order
+func (d *Dag) Compensate(ctx context.Context, data map[string]any) (context.Context, map[string]any, []CompensationResult) {
+	return d.compensateWithState(ctx, data, d.state)
+}
+
+// compensateWithState compensates the completed nodes, if the given state is completed
+func (d *Dag) compensateWithState(ctx context.Context, data map[string]any, state StateInterface) (context.Context, map[string]any, []CompensationResult) {
+	if state.GetStatus() != StateStatusComplete {
+		return ctx, data, nil
 	}
+	return compensateNodes(ctx, data, state, d.findNode)
+}
 
-	d.state.SetStatus(StateStatus(StateStatusComplete))
-	return ctx, data, nil
+// findNode returns the node with the given ID
+func (d *Dag) findNode(id string) (RunnableInterface, bool) {
+	node, ok := d.runnables[id]
+	return node, ok
 }
 
 // GetState returns the current workflow state
@@ -295,6 +688,10 @@ func (d *Dag) IsFailed() bool {
 	return d.state.GetStatus() == StateStatusFailed
 }
 
+func (d *Dag) IsSkipped() bool {
+	return d.state.GetStatus() == StateStatusSkipped
+}
+
 func (d *Dag) IsWaiting() bool {
 	return d.state.GetStatus() == "" // Initial state before running
 }
@@ -308,7 +705,27 @@ func (d *Dag) DependencyAdd(dependent RunnableInterface, dependency ...RunnableI
 	}
 }
 
-// DependencyList returns all dependencies for a given node.
+// DependencyAddIf adds a conditional dependency between nodes.
+// The dependent node waits for the dependency nodes, like with DependencyAdd.
+// Once they are done, the condition is evaluated against the current data,
+// and the dependent node is skipped if the condition returns false.
+func (d *Dag) DependencyAddIf(dependent RunnableInterface, condition DependencyCondition, dependency ...RunnableInterface) {
+	dependencyIDs := make([]string, 0, len(dependency))
+	for _, dep := range dependency {
+		dependencyIDs = append(dependencyIDs, dep.GetID())
+	}
+
+	dependentID := dependent.GetID()
+	d.conditionalDependencies[dependentID] = append(d.conditionalDependencies[dependentID], conditionalDependency{
+		condition:     condition,
+		dependencyIDs: dependencyIDs,
+	})
+}
+
+// DependencyList returns all dependencies for a given node, the conditional
+// ones included whatever their condition: the node waits for all of them at run
+// time, and a condition returning false skips the node (see DependencyAddIf).
+// The context and data are not used.
 func (d *Dag) DependencyList(ctx context.Context, node RunnableInterface, data map[string]any) []RunnableInterface {
 	dependencies := []RunnableInterface{}
 
@@ -326,5 +743,53 @@ func (d *Dag) DependencyList(ctx context.Context, node RunnableInterface, data m
 		}
 	}
 
+	// Get conditional dependencies
+	for _, conditional := range d.conditionalDependencies[dependentID] {
+		for _, depID := range conditional.dependencyIDs {
+			dep, ok := d.runnables[depID]
+			if !ok {
+				continue
+			}
+
+			// Add conditional dependency
+			dependencies = append(dependencies, dep)
+		}
+	}
+
+	return dependencies
+}
+
+// RunOnSkipAdd marks nodes that should still run when one of their dependencies was skipped.
+// By default, a node depending on a skipped node is skipped too.
+func (d *Dag) RunOnSkipAdd(node ...RunnableInterface) {
+	for _, n := range node {
+		if n == nil {
+			continue
+		}
+		d.runOnSkip[n.GetID()] = true
+	}
+}
+
+// dependencyIDs returns the IDs of all dependencies, regular and conditional, for each dependent node
+func (d *Dag) dependencyIDs() map[string][]string {
+	dependencies := make(map[string][]string, len(d.dependencies)+len(d.conditionalDependencies))
+	for dependentID, dependencyIDs := range d.dependencies {
+		dependencies[dependentID] = append(dependencies[dependentID], dependencyIDs...)
+	}
+	for dependentID, conditions := range d.conditionalDependencies {
+		for _, conditional := range conditions {
+			dependencies[dependentID] = append(dependencies[dependentID], conditional.dependencyIDs...)
+		}
+	}
 	return dependencies
 }
+
+// conditionsMet evaluates the conditional dependencies of a node against the current data
+func (d *Dag) conditionsMet(ctx context.Context, nodeID string, data map[string]any) bool {
+	for _, conditional := range d.conditionalDependencies[nodeID] {
+		if conditional.condition != nil && !conditional.condition(ctx, data) {
+			return false
+		}
+	}
+	return true
+}
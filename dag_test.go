@@ -3,7 +3,12 @@ package wf
 import (
 	"context"
 	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_Dag_Basic(t *testing.T) {
@@ -270,10 +275,11 @@ func Test_Dag_Run_ErrorPropagation(t *testing.T) {
 	ctx := context.Background()
 	data := make(map[string]any)
 	_, _, err := dag.Run(ctx, data)
+	var stepErr *StepError
 	if err == nil {
 		t.Error("Expected error from step1, got nil")
-	} else if err.Error() != "step1 failed" {
-		t.Errorf("Expected specific error message, got: %v", err)
+	} else if !errors.As(err, &stepErr) || stepErr.NodeID != step1.GetID() || stepErr.Err.Error() != "step1 failed" {
+		t.Errorf("Expected specific error message from step1, got: %v", err)
 	}
 }
 
@@ -394,3 +400,554 @@ func Test_Dag_Run_DependencyResolution(t *testing.T) {
 		t.Errorf("Expected value to be 2, got: %v", data["value"])
 	}
 }
+
+func Test_Dag_Run_MaxConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+
+	newFetchStep := func(key string) StepInterface {
+		return NewStep(
+			WithName(key),
+			WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+
+				data[key] = true
+				return ctx, data, nil
+			}),
+		)
+	}
+
+	fetch1 := newFetchStep("fetch1")
+	fetch2 := newFetchStep("fetch2")
+	fetch3 := newFetchStep("fetch3")
+	fetch4 := newFetchStep("fetch4")
+	merge := NewStep(
+		WithName("merge"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			for _, key := range []string{"fetch1", "fetch2", "fetch3", "fetch4"} {
+				if data[key] != true {
+					return ctx, data, errors.New(key + " not merged before dependent ran")
+				}
+			}
+			data["merged"] = true
+			return ctx, data, nil
+		}),
+	)
+
+	dag := NewDag(
+		WithRunnables(fetch1, fetch2, fetch3, fetch4, merge),
+		WithDependency(merge, fetch1, fetch2, fetch3, fetch4),
+		WithMaxConcurrency(2),
+	)
+
+	_, data, err := dag.Run(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if maxRunning != 2 {
+		t.Errorf("Expected at most 2 nodes running at the same time, got %d", maxRunning)
+	}
+
+	if data["merged"] != true {
+		t.Error("Expected merge step to run after the fetch steps")
+	}
+
+	completed := dag.GetState().GetCompletedSteps()
+	if len(completed) != 5 {
+		t.Fatalf("Expected 5 completed steps, got %d", len(completed))
+	}
+	if completed[4] != merge.GetID() {
+		t.Errorf("Expected merge to complete last, got %s", completed[4])
+	}
+	if !dag.IsCompleted() {
+		t.Errorf("Expected DAG to be completed, got %s", dag.GetState().GetStatus())
+	}
+}
+
+func Test_Dag_Run_ParallelDataMerge(t *testing.T) {
+	type contextKey struct{}
+	counted := make(chan struct{})
+
+	newStep := func(name string, handler func(data map[string]any)) StepInterface {
+		return NewStep(
+			WithID(name),
+			WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				handler(data)
+				return context.WithValue(ctx, contextKey{}, name), data, nil
+			}),
+		)
+	}
+
+	users := newStep("a-users", func(data map[string]any) {
+		<-counted
+		data["users"] = 2
+	})
+	orders := newStep("b-orders", func(data map[string]any) {
+		// Ends after the counter was updated, with the data it started with
+		<-counted
+		time.Sleep(20 * time.Millisecond)
+		data["orders"] = 3
+	})
+	counter := newStep("c-counter", func(data map[string]any) {
+		data["counter"] = data["counter"].(int) + 1
+		delete(data, "stale")
+		close(counted)
+	})
+
+	dag := NewDag(WithRunnables(users, orders, counter), WithMaxConcurrency(3))
+
+	ctx, data, err := dag.Run(context.Background(), map[string]any{"counter": 0, "stale": true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := map[string]any{"users": 2, "orders": 3, "counter": 1}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected the changes of every node to be kept, %v, got %v", expected, data)
+	}
+
+	// The context of the last node in order, not of the node that ended last
+	if name := ctx.Value(contextKey{}); name != "c-counter" {
+		t.Errorf("Expected the context of the last node, got the context of %v", name)
+	}
+}
+
+func Test_Dag_Run_ParallelError(t *testing.T) {
+	var mu sync.Mutex
+	executed := []string{}
+
+	newStep := func(name string, err error) StepInterface {
+		return NewStep(
+			WithName(name),
+			WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				mu.Lock()
+				executed = append(executed, name)
+				mu.Unlock()
+				return ctx, data, err
+			}),
+		)
+	}
+
+	failing := newStep("failing", errors.New("fetch failed"))
+	other := newStep("other", nil)
+	dependent := newStep("dependent", nil)
+
+	dag := NewDag(
+		WithRunnables(failing, other, dependent),
+		WithDependency(dependent, failing, other),
+		WithMaxConcurrency(0),
+	)
+
+	_, _, err := dag.Run(context.Background(), map[string]any{})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Err.Error() != "fetch failed" {
+		t.Fatalf("Expected fetch failed error, got %v", err)
+	}
+
+	if slices.Contains(executed, "dependent") {
+		t.Error("Dependent step should not run after a dependency failed")
+	}
+
+	if !dag.IsFailed() {
+		t.Errorf("Expected DAG to be failed, got %s", dag.GetState().GetStatus())
+	}
+}
+
+func Test_Dag_Run_FailurePolicy(t *testing.T) {
+	fetchErr := errors.New("fetch failed")
+	exportErr := errors.New("export failed")
+
+	// The node IDs, in alphabetical order
+	tests := []struct {
+		policy    FailurePolicy
+		executed  []string
+		completed []string
+		failed    []string
+	}{
+		{FailurePolicyFailFast, []string{"export"}, []string{}, []string{"export"}},
+		{FailurePolicyContinue, []string{"export", "fetch", "other", "report"}, []string{"other", "report"}, []string{"export", "fetch"}},
+		{FailurePolicyContinueAll, []string{"export", "fetch", "load", "other", "report", "transform"}, []string{"load", "other", "report", "transform"}, []string{"export", "fetch"}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			executed := []string{}
+			newStep := func(name string, err error) StepInterface {
+				return NewStep(
+					WithID(name),
+					WithName(name),
+					WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+						executed = append(executed, name)
+						return ctx, data, err
+					}),
+				)
+			}
+
+			fetch := newStep("fetch", fetchErr)
+			other := newStep("other", nil)
+			export := newStep("export", exportErr)
+			transform := newStep("transform", nil)
+			load := newStep("load", nil)
+			report := newStep("report", nil)
+
+			dag := NewDag(
+				WithRunnables(fetch, other, export, transform, load, report),
+				WithDependency(transform, fetch),
+				WithDependency(load, transform),
+				WithDependency(report, other),
+				WithFailurePolicy(test.policy),
+			)
+
+			_, _, err := dag.Run(context.Background(), map[string]any{})
+			if !errors.Is(err, exportErr) {
+				t.Fatalf("Expected the error of export, got %v", err)
+			}
+
+			// With the continue policies, the error joins the errors of all the failed nodes
+			if test.policy != FailurePolicyFailFast {
+				joined, ok := err.(interface{ Unwrap() []error })
+				if !ok || len(joined.Unwrap()) != 2 || !errors.Is(err, fetchErr) {
+					t.Fatalf("Expected the joined errors of export and fetch, got %v", err)
+				}
+				for _, nodeErr := range joined.Unwrap() {
+					var stepErr *StepError
+					if !errors.As(nodeErr, &stepErr) {
+						t.Errorf("Expected a StepError, got %v", nodeErr)
+					}
+				}
+			}
+
+			// The independent nodes may run in any order
+			sorted := func(ids []string) []string {
+				return slices.Sorted(slices.Values(ids))
+			}
+
+			if !slices.Equal(sorted(executed), test.executed) {
+				t.Errorf("Expected %v to execute, got %v", test.executed, executed)
+			}
+			if completed := dag.GetState().GetCompletedSteps(); !slices.Equal(sorted(completed), test.completed) {
+				t.Errorf("Expected the completed steps %v, got %v", test.completed, completed)
+			}
+			if failed := dag.GetState().(FailedStepsState).GetFailedSteps(); !slices.Equal(sorted(failed), test.failed) {
+				t.Errorf("Expected the failed steps %v, got %v", test.failed, failed)
+			}
+
+			if !dag.IsFailed() {
+				t.Errorf("Expected DAG to be failed, got %s", dag.GetState().GetStatus())
+			}
+		})
+	}
+}
+
+func Test_Dag_DependencyAddIf(t *testing.T) {
+	newStep := func(name string) StepInterface {
+		return NewStep(
+			WithName(name),
+			WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				data["executed"] = append(data["executed"].([]string), name)
+				return ctx, data, nil
+			}),
+		)
+	}
+
+	isPhysical := func(ctx context.Context, data map[string]any) bool {
+		return data["orderType"] == "physical"
+	}
+
+	testCases := []struct {
+		orderType string
+		expected  []string
+	}{
+		{"physical", []string{"process", "shipping", "tax"}},
+		{"digital", []string{"process", "tax"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.orderType, func(t *testing.T) {
+			process := newStep("process")
+			shipping := newStep("shipping")
+			tax := newStep("tax")
+
+			dag := NewDag(
+				WithRunnables(process, shipping, tax),
+				WithDependencyIf(shipping, isPhysical, process),
+				WithDependency(tax, process, shipping),
+				WithRunOnSkip(tax),
+			)
+
+			_, data, err := dag.Run(context.Background(), map[string]any{
+				"orderType": tc.orderType,
+				"executed":  []string{},
+			})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if executed := data["executed"].([]string); !slices.Equal(executed, tc.expected) {
+				t.Errorf("Expected steps %v, got %v", tc.expected, executed)
+			}
+		})
+	}
+}
+
+func Test_Dag_DependencyList_Conditional(t *testing.T) {
+	step1 := NewStep(WithName("Step1"))
+	step2 := NewStep(WithName("Step2"))
+	step3 := NewStep(WithName("Step3"))
+
+	dag := NewDag(WithRunnables(step1, step2, step3))
+	dag.DependencyAdd(step3, step1)
+	dag.DependencyAddIf(step3, func(ctx context.Context, data map[string]any) bool {
+		return data["enabled"] == true
+	}, step2)
+
+	deps := dag.DependencyList(context.Background(), step3, map[string]any{"enabled": true})
+	if len(deps) != 2 {
+		t.Errorf("Expected 2 dependencies when the condition holds, got %d", len(deps))
+	}
+
+	// The node still waits for the conditional dependency, and is skipped at run time
+	deps = dag.DependencyList(context.Background(), step3, map[string]any{"enabled": false})
+	if len(deps) != 2 {
+		t.Errorf("Expected 2 dependencies when the condition does not hold, got %d", len(deps))
+	}
+
+	// Removing a node removes it from the conditional dependencies too
+	dag.RunnableRemove(step2)
+	deps = dag.DependencyList(context.Background(), step3, map[string]any{"enabled": true})
+	if len(deps) != 1 {
+		t.Errorf("Expected 1 dependency after removal, got %d", len(deps))
+	}
+}
+
+func Test_Dag_Run_SkipPropagation(t *testing.T) {
+	executed := []string{}
+	newStep := func(name string, err error) StepInterface {
+		return NewStep(
+			WithName(name),
+			WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				executed = append(executed, name)
+				return ctx, data, err
+			}),
+		)
+	}
+
+	skipper := newStep("skipper", ErrSkip)
+	downstream := newStep("downstream", nil)
+	transitive := newStep("transitive", nil)
+	cleanup := newStep("cleanup", nil)
+
+	dag := NewDag(
+		WithRunnables(skipper, downstream, transitive, cleanup),
+		WithDependency(downstream, skipper),
+		WithDependency(transitive, downstream),
+		WithDependency(cleanup, transitive),
+		WithRunOnSkip(cleanup),
+	)
+
+	_, _, err := dag.Run(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !slices.Equal(executed, []string{"skipper", "cleanup"}) {
+		t.Errorf("Expected only skipper and cleanup to execute, got %v", executed)
+	}
+
+	for _, node := range []StepInterface{skipper, downstream, transitive} {
+		if !node.IsSkipped() {
+			t.Errorf("Expected %s to be skipped, got status %q", node.GetName(), node.GetState().GetStatus())
+		}
+	}
+
+	if !cleanup.IsCompleted() {
+		t.Errorf("Expected cleanup to be completed, got status %q", cleanup.GetState().GetStatus())
+	}
+
+	skippedSteps := dag.GetState().(SkippedStepsState).GetSkippedSteps()
+	if len(skippedSteps) != 3 {
+		t.Errorf("Expected 3 skipped steps in the DAG state, got %v", skippedSteps)
+	}
+
+	if !dag.IsCompleted() {
+		t.Errorf("Expected DAG to be completed, got %s", dag.GetState().GetStatus())
+	}
+}
+
+func Test_Dag_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	hung := NewStep(
+		WithName("Hung"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			<-release // ignores the context
+			return ctx, data, nil
+		}),
+	)
+	fast := NewStep(
+		WithName("Fast"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			return ctx, data, nil
+		}),
+	)
+	dependent := NewStep(
+		WithName("Dependent"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			return ctx, data, nil
+		}),
+	)
+
+	dag := NewDag(
+		WithRunnables(hung, fast, dependent),
+		WithDependency(dependent, hung, fast),
+		WithMaxConcurrency(2),
+		WithTimeout(20*time.Millisecond),
+	)
+
+	start := time.Now()
+	ctx, _, err := dag.Run(context.Background(), map[string]any{})
+	if time.Since(start) > time.Second {
+		t.Fatal("Expected the DAG to stop after the timeout")
+	}
+
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("The returned context should not carry the DAG's deadline")
+	}
+	if !dag.IsFailed() {
+		t.Errorf("Expected DAG to be failed, got %s", dag.GetState().GetStatus())
+	}
+	if reason := dag.GetState().(ErrorReasonState).GetErrorReason(); reason != StateErrorReasonTimeout {
+		t.Errorf("Expected error reason %q, got %q", StateErrorReasonTimeout, reason)
+	}
+	if !fast.IsCompleted() {
+		t.Error("Expected the fast step to complete before the timeout")
+	}
+	if dependent.IsCompleted() {
+		t.Error("Dependent step should not run after the timeout")
+	}
+}
+
+func Test_Dag_Pause(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	executed := []string{}
+	record := func(id string) {
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, id)
+	}
+
+	slow := NewStep(WithID("slow"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		close(started)
+		<-release
+		record("slow")
+		return ctx, data, nil
+	}))
+	fast := NewStep(WithID("fast"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		record("fast")
+		return ctx, data, nil
+	}))
+	last := NewStep(WithID("last"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		record("last")
+		return ctx, data, nil
+	}))
+
+	dag := NewDag(
+		WithRunnables(slow, fast, last),
+		WithDependency(last, slow, fast),
+		WithMaxConcurrency(2),
+	)
+
+	// Pause from another goroutine, while the slow step is running
+	go func() {
+		<-started
+		if err := dag.Pause(); err != nil {
+			t.Errorf("Pause failed: %v", err)
+		}
+		close(release)
+	}()
+
+	ctx, data, err := dag.Run(context.Background(), map[string]any{})
+	if !errors.Is(err, ErrPaused) {
+		t.Fatalf("Expected ErrPaused, got %v", err)
+	}
+
+	// The running steps finish, the last one is not started
+	mu.Lock()
+	slices.Sort(executed)
+	if !slices.Equal(executed, []string{"fast", "slow"}) {
+		t.Errorf("Expected fast and slow to run, got %v", executed)
+	}
+	executed = []string{}
+	mu.Unlock()
+
+	if !dag.IsPaused() {
+		t.Fatalf("Expected DAG to be paused, got %s", dag.GetState().GetStatus())
+	}
+
+	if _, _, err := dag.Resume(ctx, data); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if !slices.Equal(executed, []string{"last"}) {
+		t.Errorf("Expected only last to run when resumed, got %v", executed)
+	}
+	if !dag.IsCompleted() {
+		t.Errorf("Expected DAG to be completed, got %s", dag.GetState().GetStatus())
+	}
+}
+
+func Test_Dag_Pause_NestedStep(t *testing.T) {
+	var verifyCalls atomic.Int32
+
+	// A step waiting for input pauses the workflow
+	verify := NewStep(WithID("verify"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		verifyCalls.Add(1)
+		if _, ok := data["code"]; !ok {
+			return ctx, data, ErrPaused
+		}
+		data["verified"] = true
+		return ctx, data, nil
+	}))
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(verify))
+	dag := NewDag(WithRunnables(pipeline))
+
+	_, data, err := dag.Run(context.Background(), map[string]any{})
+	if !errors.Is(err, ErrPaused) {
+		t.Fatalf("Expected ErrPaused, got %v", err)
+	}
+	if !dag.IsPaused() || !pipeline.IsPaused() || !verify.IsPaused() {
+		t.Fatalf("Expected the DAG, the pipeline and the step to be paused, got %s %s %s",
+			dag.GetState().GetStatus(), pipeline.GetState().GetStatus(), verify.GetState().GetStatus())
+	}
+
+	data["code"] = "123456"
+	_, data, err = dag.Resume(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if verifyCalls.Load() != 2 {
+		t.Errorf("Expected the paused step to run again, got %d calls", verifyCalls.Load())
+	}
+	if data["verified"] != true || !dag.IsCompleted() {
+		t.Errorf("Expected the DAG to be completed, got %s %v", dag.GetState().GetStatus(), data)
+	}
+}
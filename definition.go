@@ -0,0 +1,500 @@
+package wf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// DefinitionType is the type of the node described by a Definition
+type DefinitionType string
+
+const (
+	// Definition type constants
+	DefinitionTypeStep     = "step"
+	DefinitionTypePipeline = "pipeline"
+	DefinitionTypeDag      = "dag"
+)
+
+// Definition describes a workflow node (a step, a pipeline or a DAG) with its
+// nodes, so workflows can be kept in JSON or YAML files instead of Go code.
+// The handlers of the steps are referred to by the names they are registered
+// under in a HandlerRegistryInterface. Durations are strings like "1m30s".
+//
+// Example (YAML):
+//   id: orders
+//   name: Order Processing
+//   type: dag
+//   maxConcurrency: 2
+//   nodes:
+//     - id: validate
+//       type: step
+//       handler: orders.validate
+//     - id: charge
+//       type: step
+//       handler: orders.charge
+//       compensation: orders.refund
+//       retry: {maxAttempts: 3, initialBackoff: 1s, multiplier: 2}
+//       timeout: 30s
+//       dependsOn: [validate]
+type Definition struct {
+	ID   string         `json:"id,omitempty" yaml:"id,omitempty"`
+	Name string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Type DefinitionType `json:"type" yaml:"type"`
+
+	// Handler and Compensation are the names of the handlers of a step
+	Handler      string           `json:"handler,omitempty" yaml:"handler,omitempty"`
+	Compensation string           `json:"compensation,omitempty" yaml:"compensation,omitempty"`
+	Retry        *RetryDefinition `json:"retry,omitempty" yaml:"retry,omitempty"`
+
+	// Timeout is the maximum duration of the node, empty if there is none
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// Nodes of a pipeline, in order, or of a DAG
+	Nodes []*Definition `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+
+	// MaxConcurrency of a DAG, 1 if not set (see WithMaxConcurrency)
+	MaxConcurrency *int `json:"maxConcurrency,omitempty" yaml:"maxConcurrency,omitempty"`
+
+	// FailurePolicy of a DAG, FailurePolicyFailFast if not set
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty" yaml:"failurePolicy,omitempty"`
+
+	// DependsOn holds the IDs of the nodes of the same DAG the node depends on
+	DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+
+	// RunOnSkip runs the node of a DAG, when one of its dependencies was skipped
+	RunOnSkip bool `json:"runOnSkip,omitempty" yaml:"runOnSkip,omitempty"`
+}
+
+// RetryDefinition describes the RetryPolicy of a step. Durations are strings like "500ms".
+type RetryDefinition struct {
+	MaxAttempts    int     `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+	InitialBackoff string  `json:"initialBackoff,omitempty" yaml:"initialBackoff,omitempty"`
+	MaxBackoff     string  `json:"maxBackoff,omitempty" yaml:"maxBackoff,omitempty"`
+	Multiplier     float64 `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	Jitter         float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// ToJSON converts the definition to indented JSON
+func (d *Definition) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// FromJSON loads the definition from JSON
+func (d *Definition) FromJSON(data []byte) error {
+	return json.Unmarshal(data, d)
+}
+
+// ToYAML converts the definition to YAML
+func (d *Definition) ToYAML() ([]byte, error) {
+	return yaml.Marshal(d)
+}
+
+// FromYAML loads the definition from YAML
+func (d *Definition) FromYAML(data []byte) error {
+	return yaml.Unmarshal(data, d)
+}
+
+// LoadDefinition builds the step, pipeline or DAG described by the definition,
+// with the handlers of its steps resolved from the registry, or from
+// DefaultHandlerRegistry when the registry is nil.
+//
+// Example:
+//   definition := &Definition{}
+//   if err := definition.FromYAML(data); err != nil {
+//       return err
+//   }
+//   node, err := LoadDefinition(definition, registry)
+func LoadDefinition(definition *Definition, registry HandlerRegistryInterface) (RunnableInterface, error) {
+	if definition == nil {
+		return nil, errors.New("no definition")
+	}
+	return definition.build(registry)
+}
+
+// LoadDag builds the DAG described by the definition (see LoadDefinition)
+func LoadDag(definition *Definition, registry HandlerRegistryInterface) (DagInterface, error) {
+	if definition == nil || definition.Type != DefinitionTypeDag {
+		return nil, errors.New("the definition is not a dag")
+	}
+
+	node, err := definition.build(registry)
+	if err != nil {
+		return nil, err
+	}
+	return node.(DagInterface), nil
+}
+
+// LoadPipeline builds the pipeline described by the definition (see LoadDefinition)
+func LoadPipeline(definition *Definition, registry HandlerRegistryInterface) (PipelineInterface, error) {
+	if definition == nil || definition.Type != DefinitionTypePipeline {
+		return nil, errors.New("the definition is not a pipeline")
+	}
+
+	node, err := definition.build(registry)
+	if err != nil {
+		return nil, err
+	}
+	return node.(PipelineInterface), nil
+}
+
+// build builds the node described by the definition. The errors are
+// prefixed with the reference of the node, e.g. "orders: charge: ...".
+func (d *Definition) build(registry HandlerRegistryInterface) (RunnableInterface, error) {
+	node, err := d.buildNode(registry)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.reference(), err)
+	}
+	return node, nil
+}
+
+// buildNode builds the node described by the definition
+func (d *Definition) buildNode(registry HandlerRegistryInterface) (RunnableInterface, error) {
+	opts := []interface{}{}
+	if d.ID != "" {
+		opts = append(opts, WithID(d.ID))
+	}
+	if d.Name != "" {
+		opts = append(opts, WithName(d.Name))
+	}
+
+	timeout, err := parseDuration(d.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+	opts = append(opts, WithTimeout(timeout))
+
+	if d.Type != DefinitionTypeStep && (d.Handler != "" || d.Compensation != "" || d.Retry != nil) {
+		return nil, fmt.Errorf("only steps have handlers and retries, not a %q", d.Type)
+	}
+	if d.Type != DefinitionTypeDag && (d.MaxConcurrency != nil || d.FailurePolicy != "") {
+		return nil, fmt.Errorf("only dags have a max concurrency and a failure policy, not a %q", d.Type)
+	}
+
+	switch d.Type {
+	case DefinitionTypeStep:
+		return d.buildStep(registry, opts)
+	case DefinitionTypePipeline:
+		return d.buildPipeline(registry, opts)
+	case DefinitionTypeDag:
+		return d.buildDag(registry, opts)
+	default:
+		return nil, fmt.Errorf("unknown type %q", d.Type)
+	}
+}
+
+// buildStep builds the step described by the definition
+func (d *Definition) buildStep(registry HandlerRegistryInterface, opts []interface{}) (RunnableInterface, error) {
+	if len(d.Nodes) > 0 {
+		return nil, errors.New("a step has no nodes")
+	}
+
+	step := NewStep(opts...)
+
+	handler, err := resolveHandler(registry, d.Handler)
+	if err != nil {
+		return nil, err
+	}
+	step.SetHandler(handler)
+	step.SetHandlerName(d.Handler)
+
+	if d.Compensation != "" {
+		compensation, err := resolveHandler(registry, d.Compensation)
+		if err != nil {
+			return nil, err
+		}
+		step.SetCompensation(compensation)
+		step.SetCompensationName(d.Compensation)
+	}
+
+	if d.Retry != nil {
+		policy, err := d.Retry.policy()
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry: %w", err)
+		}
+		step.SetRetryPolicy(policy)
+	}
+
+	return step, nil
+}
+
+// buildPipeline builds the pipeline described by the definition
+func (d *Definition) buildPipeline(registry HandlerRegistryInterface, opts []interface{}) (RunnableInterface, error) {
+	nodes, err := buildNodes(d.Nodes, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range d.Nodes {
+		if len(node.DependsOn) > 0 || node.RunOnSkip {
+			return nil, fmt.Errorf("%s: only the nodes of a dag have dependencies", node.reference())
+		}
+	}
+
+	return NewPipeline(append(opts, WithRunnables(nodes...))...), nil
+}
+
+// buildDag builds the DAG described by the definition
+func (d *Definition) buildDag(registry HandlerRegistryInterface, opts []interface{}) (RunnableInterface, error) {
+	nodes, err := buildNodes(d.Nodes, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.MaxConcurrency != nil {
+		opts = append(opts, WithMaxConcurrency(*d.MaxConcurrency))
+	}
+
+	switch d.FailurePolicy {
+	case "":
+	case FailurePolicyFailFast, FailurePolicyContinue, FailurePolicyContinueAll:
+		opts = append(opts, WithFailurePolicy(d.FailurePolicy))
+	default:
+		return nil, fmt.Errorf("unknown failure policy %q", d.FailurePolicy)
+	}
+
+	byID := make(map[string]RunnableInterface, len(nodes))
+	for _, node := range nodes {
+		if _, exists := byID[node.GetID()]; exists {
+			return nil, fmt.Errorf("duplicate node id %q", node.GetID())
+		}
+		byID[node.GetID()] = node
+	}
+
+	dag := NewDag(append(opts, WithRunnables(nodes...))...)
+
+	for i, definition := range d.Nodes {
+		for _, id := range definition.DependsOn {
+			dependency, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown dependency %q", definition.reference(), id)
+			}
+			dag.DependencyAdd(nodes[i], dependency)
+		}
+
+		if definition.RunOnSkip {
+			dag.RunOnSkipAdd(nodes[i])
+		}
+	}
+
+	// Reject the cycles when loading, instead of on the first run
+	built := dag.(*Dag)
+	if _, err := topologicalSort(buildDependencyGraph(built.runnables, built.dependencyIDs())); err != nil {
+		return nil, err
+	}
+
+	return dag, nil
+}
+
+// buildNodes builds the nodes described by the definitions, in order
+func buildNodes(definitions []*Definition, registry HandlerRegistryInterface) ([]RunnableInterface, error) {
+	nodes := make([]RunnableInterface, 0, len(definitions))
+	for _, definition := range definitions {
+		if definition == nil {
+			return nil, errors.New("empty node definition")
+		}
+
+		node, err := definition.build(registry)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// reference returns how the node is referred to in errors: its ID, its name or its type
+func (d *Definition) reference() string {
+	if d.ID != "" {
+		return d.ID
+	}
+	if d.Name != "" {
+		return d.Name
+	}
+	return string(d.Type)
+}
+
+// resolveHandler returns the handler registered under the name
+func resolveHandler(registry HandlerRegistryInterface, name string) (StepHandler, error) {
+	if name == "" {
+		return nil, errors.New("no handler")
+	}
+	if registry == nil {
+		registry = DefaultHandlerRegistry
+	}
+
+	handler, ok := registry.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("handler %q is not registered", name)
+	}
+	return handler, nil
+}
+
+// policy converts the definition to a RetryPolicy
+func (r *RetryDefinition) policy() (*RetryPolicy, error) {
+	initialBackoff, err := parseDuration(r.InitialBackoff)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBackoff, err := parseDuration(r.MaxBackoff)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RetryPolicy{
+		MaxAttempts:    r.MaxAttempts,
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     maxBackoff,
+		Multiplier:     r.Multiplier,
+		Jitter:         r.Jitter,
+	}, nil
+}
+
+// ExportDefinition describes an existing step, pipeline or DAG as a Definition,
+// e.g. to save it to a file and load it with LoadDefinition. The handlers must
+// have names (see SetHandlerName), and a DAG must not have conditional
+// dependencies or retry policies with a Retryable function, as functions
+// cannot be exported. Listeners, middlewares, loggers and state stores are
+// not part of the definition.
+func ExportDefinition(node RunnableInterface) (*Definition, error) {
+	definition, err := exportNode(node)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", node.GetID(), err)
+	}
+	return definition, nil
+}
+
+// exportNode describes the node as a Definition
+func exportNode(node RunnableInterface) (*Definition, error) {
+	definition := &Definition{
+		ID:   node.GetID(),
+		Name: node.GetName(),
+	}
+
+	switch n := node.(type) {
+	case StepInterface:
+		definition.Type = DefinitionTypeStep
+		definition.Timeout = formatDuration(n.GetTimeout())
+		return definition, exportStep(n, definition)
+
+	case *Dag:
+		definition.Type = DefinitionTypeDag
+		definition.Timeout = formatDuration(n.GetTimeout())
+		return definition, exportDag(n, definition)
+
+	case DagInterface:
+		// The conditions and run-on-skip flags of other DAGs cannot be read,
+		// exporting them as a pipeline would lose their dependencies
+		return nil, fmt.Errorf("cannot export a DAG of type %T", node)
+
+	case PipelineInterface:
+		definition.Type = DefinitionTypePipeline
+		definition.Timeout = formatDuration(n.GetTimeout())
+
+		nodes, err := exportNodes(n.RunnableList())
+		if err != nil {
+			return nil, err
+		}
+		definition.Nodes = nodes
+		return definition, nil
+
+	default:
+		return nil, fmt.Errorf("cannot export a node of type %T", node)
+	}
+}
+
+// exportStep describes the handlers and retry policy of the step
+func exportStep(step StepInterface, definition *Definition) error {
+	if step.GetHandler() != nil && step.GetHandlerName() == "" {
+		return errors.New("the handler has no name")
+	}
+	definition.Handler = step.GetHandlerName()
+
+	if step.GetCompensation() != nil && step.GetCompensationName() == "" {
+		return errors.New("the compensation handler has no name")
+	}
+	definition.Compensation = step.GetCompensationName()
+
+	if policy := step.GetRetryPolicy(); policy != nil {
+		if policy.Retryable != nil {
+			return errors.New("the retry policy has a Retryable function")
+		}
+		definition.Retry = &RetryDefinition{
+			MaxAttempts:    policy.MaxAttempts,
+			InitialBackoff: formatDuration(policy.InitialBackoff),
+			MaxBackoff:     formatDuration(policy.MaxBackoff),
+			Multiplier:     policy.Multiplier,
+			Jitter:         policy.Jitter,
+		}
+	}
+
+	return nil
+}
+
+// exportDag describes the nodes, dependencies and options of the DAG
+func exportDag(dag *Dag, definition *Definition) error {
+	if len(dag.conditionalDependencies) > 0 {
+		return errors.New("conditional dependencies cannot be exported")
+	}
+
+	if dag.maxConcurrency != 1 {
+		maxConcurrency := dag.maxConcurrency
+		definition.MaxConcurrency = &maxConcurrency
+	}
+	if dag.failurePolicy != FailurePolicyFailFast {
+		definition.FailurePolicy = dag.failurePolicy
+	}
+
+	// The nodes are exported in the order they were added
+	nodes := make([]RunnableInterface, 0, len(dag.runnableSequence))
+	for _, id := range dag.runnableSequence {
+		nodes = append(nodes, dag.runnables[id])
+	}
+
+	definitions, err := exportNodes(nodes)
+	if err != nil {
+		return err
+	}
+
+	for _, nodeDefinition := range definitions {
+		nodeDefinition.DependsOn = slices.Clone(dag.dependencies[nodeDefinition.ID])
+		nodeDefinition.RunOnSkip = dag.runOnSkip[nodeDefinition.ID]
+	}
+	definition.Nodes = definitions
+
+	return nil
+}
+
+// exportNodes describes the nodes as Definitions, in order
+func exportNodes(nodes []RunnableInterface) ([]*Definition, error) {
+	definitions := make([]*Definition, 0, len(nodes))
+	for _, node := range nodes {
+		definition, err := ExportDefinition(node)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, nil
+}
+
+// parseDuration parses a duration like "1m30s", an empty string is zero
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// formatDuration formats a duration like "1m30s", zero is an empty string
+func formatDuration(duration time.Duration) string {
+	if duration == 0 {
+		return ""
+	}
+	return duration.String()
+}
@@ -0,0 +1,267 @@
+package wf
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newDefinitionRegistry returns a registry with handlers appending their name to data["executed"]
+func newDefinitionRegistry(names ...string) HandlerRegistryInterface {
+	registry := NewHandlerRegistry()
+	for _, name := range names {
+		registry.Register(name, func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			executed, _ := data["executed"].([]string)
+			data["executed"] = append(executed, name)
+			return ctx, data, nil
+		})
+	}
+	return registry
+}
+
+const testDefinitionYAML = `
+id: orders
+name: Order Processing
+type: dag
+maxConcurrency: 2
+failurePolicy: continue
+timeout: 1m
+nodes:
+  - id: validate
+    name: Validate
+    type: step
+    handler: orders.validate
+  - id: payment
+    name: Payment
+    type: pipeline
+    dependsOn: [validate]
+    nodes:
+      - id: charge
+        type: step
+        handler: orders.charge
+        compensation: orders.refund
+        timeout: 30s
+        retry:
+          maxAttempts: 3
+          initialBackoff: 100ms
+          multiplier: 2
+      - id: receipt
+        type: step
+        handler: orders.receipt
+  - id: ship
+    type: step
+    handler: orders.ship
+    dependsOn: [validate, payment]
+    runOnSkip: true
+`
+
+func Test_LoadDefinition_YAML(t *testing.T) {
+	definition := &Definition{}
+	if err := definition.FromYAML([]byte(testDefinitionYAML)); err != nil {
+		t.Fatalf("FromYAML failed: %v", err)
+	}
+
+	registry := newDefinitionRegistry("orders.validate", "orders.charge", "orders.refund", "orders.receipt", "orders.ship")
+	dag, err := LoadDag(definition, registry)
+	if err != nil {
+		t.Fatalf("LoadDag failed: %v", err)
+	}
+
+	if dag.GetID() != "orders" || dag.GetName() != "Order Processing" {
+		t.Errorf("Unexpected DAG %s (%s)", dag.GetID(), dag.GetName())
+	}
+	if dag.GetMaxConcurrency() != 2 || dag.GetFailurePolicy() != FailurePolicyContinue || dag.GetTimeout() != time.Minute {
+		t.Errorf("Unexpected DAG options: %d, %s, %s", dag.GetMaxConcurrency(), dag.GetFailurePolicy(), dag.GetTimeout())
+	}
+
+	var charge StepInterface
+	for _, node := range dag.RunnableList() {
+		if pipeline, ok := node.(PipelineInterface); ok {
+			charge = pipeline.RunnableList()[0].(StepInterface)
+		}
+	}
+	if charge == nil {
+		t.Fatal("Expected the payment pipeline")
+	}
+	if charge.GetTimeout() != 30*time.Second || charge.GetHandlerName() != "orders.charge" || charge.GetCompensationName() != "orders.refund" {
+		t.Errorf("Unexpected charge step: %s, %q, %q", charge.GetTimeout(), charge.GetHandlerName(), charge.GetCompensationName())
+	}
+	if policy := charge.GetRetryPolicy(); policy == nil || policy.MaxAttempts != 3 || policy.InitialBackoff != 100*time.Millisecond || policy.Multiplier != 2 {
+		t.Errorf("Unexpected retry policy: %+v", policy)
+	}
+
+	_, data, err := dag.Run(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := []string{"orders.validate", "orders.charge", "orders.receipt", "orders.ship"}
+	if !reflect.DeepEqual(data["executed"], expected) {
+		t.Errorf("Expected %v to execute, got %v", expected, data["executed"])
+	}
+}
+
+func Test_ExportDefinition_RoundTrip(t *testing.T) {
+	definition := &Definition{}
+	if err := definition.FromYAML([]byte(testDefinitionYAML)); err != nil {
+		t.Fatalf("FromYAML failed: %v", err)
+	}
+
+	registry := newDefinitionRegistry("orders.validate", "orders.charge", "orders.refund", "orders.receipt", "orders.ship")
+	dag, err := LoadDag(definition, registry)
+	if err != nil {
+		t.Fatalf("LoadDag failed: %v", err)
+	}
+
+	exported, err := ExportDefinition(dag)
+	if err != nil {
+		t.Fatalf("ExportDefinition failed: %v", err)
+	}
+
+	// The timeouts are exported in the format of time.Duration
+	definition.Timeout = "1m0s"
+	definition.Nodes[1].Nodes[0].Retry.InitialBackoff = "100ms"
+	if !reflect.DeepEqual(exported, definition) {
+		t.Errorf("Expected the exported definition to match the loaded one")
+	}
+
+	// Through JSON
+	jsonData, err := exported.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	loaded := &Definition{}
+	if err := loaded.FromJSON(jsonData); err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, exported) {
+		t.Errorf("Expected the definition to survive JSON, got %s", jsonData)
+	}
+
+	// Through YAML
+	yamlData, err := exported.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML failed: %v", err)
+	}
+	loaded = &Definition{}
+	if err := loaded.FromYAML(yamlData); err != nil {
+		t.Fatalf("FromYAML failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, exported) {
+		t.Errorf("Expected the definition to survive YAML, got %s", yamlData)
+	}
+}
+
+func Test_ExportDefinition_CopiesDependencies(t *testing.T) {
+	registry := newDefinitionRegistry("known")
+	dag, err := LoadDag(&Definition{ID: "d", Type: DefinitionTypeDag, Nodes: []*Definition{
+		{ID: "a", Type: DefinitionTypeStep, Handler: "known"},
+		{ID: "b", Type: DefinitionTypeStep, Handler: "known", DependsOn: []string{"a"}},
+	}}, registry)
+	if err != nil {
+		t.Fatalf("LoadDag failed: %v", err)
+	}
+
+	exported, err := ExportDefinition(dag)
+	if err != nil {
+		t.Fatalf("ExportDefinition failed: %v", err)
+	}
+	exported.Nodes[1].DependsOn[0] = "b"
+
+	for _, node := range dag.RunnableList() {
+		if node.GetID() != "b" {
+			continue
+		}
+		dependencies := dag.DependencyList(context.Background(), node, nil)
+		if len(dependencies) != 1 || dependencies[0].GetID() != "a" {
+			t.Errorf("Expected b to keep depending on a, got %v", dependencies)
+		}
+	}
+}
+
+func Test_LoadDefinition_Errors(t *testing.T) {
+	registry := newDefinitionRegistry("known")
+
+	step := func(id string, handler string) *Definition {
+		return &Definition{ID: id, Type: DefinitionTypeStep, Handler: handler}
+	}
+
+	tests := []struct {
+		name       string
+		definition *Definition
+		expected   string
+	}{
+		{"unknown handler", step("a", "unknown"), `a: handler "unknown" is not registered`},
+		{"no handler", step("a", ""), "a: no handler"},
+		{"unknown type", &Definition{ID: "a", Type: "job"}, `a: unknown type "job"`},
+		{"invalid timeout", &Definition{ID: "a", Type: DefinitionTypeStep, Handler: "known", Timeout: "soon"}, "a: invalid timeout"},
+		{"nested error", &Definition{ID: "p", Type: DefinitionTypePipeline, Nodes: []*Definition{step("a", "unknown")}}, `p: a: handler "unknown" is not registered`},
+		{"unknown dependency", &Definition{ID: "d", Type: DefinitionTypeDag, Nodes: []*Definition{
+			{ID: "a", Type: DefinitionTypeStep, Handler: "known", DependsOn: []string{"b"}},
+		}}, `d: a: unknown dependency "b"`},
+		{"dependency cycle", &Definition{ID: "d", Type: DefinitionTypeDag, Nodes: []*Definition{
+			{ID: "a", Type: DefinitionTypeStep, Handler: "known", DependsOn: []string{"b"}},
+			{ID: "b", Type: DefinitionTypeStep, Handler: "known", DependsOn: []string{"a"}},
+		}}, "d: cycle detected"},
+		{"duplicate id", &Definition{ID: "d", Type: DefinitionTypeDag, Nodes: []*Definition{step("a", "known"), step("a", "known")}}, `d: duplicate node id "a"`},
+		{"dependency in a pipeline", &Definition{ID: "p", Type: DefinitionTypePipeline, Nodes: []*Definition{
+			step("a", "known"),
+			{ID: "b", Type: DefinitionTypeStep, Handler: "known", DependsOn: []string{"a"}},
+		}}, "p: b: only the nodes of a dag have dependencies"},
+		{"handler of a pipeline", &Definition{ID: "p", Type: DefinitionTypePipeline, Handler: "known"}, "p: only steps have handlers"},
+		{"unknown failure policy", &Definition{ID: "d", Type: DefinitionTypeDag, FailurePolicy: "retry"}, `d: unknown failure policy "retry"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadDefinition(test.definition, registry)
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf("Expected the error %q, got %v", test.expected, err)
+			}
+		})
+	}
+
+	if _, err := LoadDag(step("a", "known"), registry); err == nil {
+		t.Error("Expected LoadDag to reject a step definition")
+	}
+}
+
+func Test_ExportDefinition_Errors(t *testing.T) {
+	handler := func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}
+
+	unnamed := NewStep(WithID("unnamed"), WithHandler(handler))
+	if _, err := ExportDefinition(NewPipeline(WithID("p"), WithRunnables(unnamed))); err == nil || err.Error() != "p: unnamed: the handler has no name" {
+		t.Errorf("Expected the handler without name to be rejected, got %v", err)
+	}
+
+	first := NewStep(WithID("first"), WithHandler(handler))
+	first.SetHandlerName("first")
+	second := NewStep(WithID("second"), WithHandler(handler))
+	second.SetHandlerName("second")
+	dag := NewDag(WithID("d"), WithRunnables(first, second), WithDependencyIf(second, func(ctx context.Context, data map[string]any) bool {
+		return true
+	}, first))
+	if _, err := ExportDefinition(dag); err == nil || !strings.Contains(err.Error(), "conditional dependencies") {
+		t.Errorf("Expected the conditional dependency to be rejected, got %v", err)
+	}
+
+	// A DAG that is not a *Dag is not exported as a pipeline
+	wrapped := struct{ DagInterface }{NewDag(WithID("w"), WithRunnables(first, second), WithDependency(second, first))}
+	if _, err := ExportDefinition(wrapped); err == nil || !strings.Contains(err.Error(), "cannot export a DAG") {
+		t.Errorf("Expected the wrapped DAG to be rejected, got %v", err)
+	}
+
+	// Setting a new handler forgets the name of the previous one
+	first.SetHandler(handler)
+	if first.GetHandlerName() != "" {
+		t.Errorf("Expected the handler name to be cleared, got %q", first.GetHandlerName())
+	}
+
+	if _, err := LoadDefinition(nil, nil); err == nil {
+		t.Error("Expected an error without definition")
+	}
+}
@@ -0,0 +1,126 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrSkip can be returned by a StepHandler to signal that the step should be skipped.
+// The step is marked as skipped, instead of failed, and no error is returned from Run.
+// Inside a DAG, the nodes depending on a skipped node are skipped too,
+// unless they were added with RunOnSkipAdd (or WithRunOnSkip).
+//
+// Example:
+//   step := NewStep(WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+//       if data["email"] == "" {
+//           return ctx, data, ErrSkip // nothing to send
+//       }
+//       ...
+//   }))
+var ErrSkip = errors.New("step skipped")
+
+// ErrPaused is returned by Run, when the workflow was paused before it finished.
+// The nodes running when Pause was called are allowed to finish, no new nodes
+// are started, and the state is saved, so the run can be resumed later.
+//
+// A StepHandler can also return ErrPaused, e.g. to wait for user input.
+// The step, and the workflow containing it, are paused, and the step runs
+// again when the workflow is resumed.
+var ErrPaused = errors.New("workflow paused")
+
+// ErrTimeout is returned when a step, pipeline or DAG runs longer than its timeout
+// (see WithTimeout). Use errors.Is(err, ErrTimeout) to detect it.
+var ErrTimeout = errors.New("timeout")
+
+// StepError is returned by a Pipeline or Dag, when one of its nodes fails.
+// It tells which node failed, and where it is in the workflow. The error of
+// the node is its cause, so errors.Is and errors.As also match the cause.
+//
+// Example:
+//   var stepErr *StepError
+//   if errors.As(err, &stepErr) {
+//       log.Printf("%s failed after %d attempts: %v", stepErr.NodeName, stepErr.Attempt, stepErr.Err)
+//   }
+type StepError struct {
+	// NodeID is the ID of the failed node
+	NodeID string
+
+	// NodeName is the name of the failed node
+	NodeName string
+
+	// Path holds the IDs of the nodes from the root of the run down to the failed node
+	Path []string
+
+	// Attempt is the attempt of the step that failed. It is zero for
+	// Pipelines and Dags, and for nodes implemented outside this package.
+	Attempt int
+
+	// Err is the error of the node
+	Err error
+}
+
+// Error returns the path of the failed node, followed by its error
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", strings.Join(e.Path, " > "), e.Err)
+}
+
+// Unwrap returns the error of the node
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// stepError wraps the error of a failed node in a StepError. Errors already
+// wrapped by a nested node, and ErrPaused, are returned unchanged.
+func stepError(err error, node RunnableInterface, state StateInterface, path []string) error {
+	var stepErr *StepError
+	if err == nil || errors.Is(err, ErrPaused) || errors.As(err, &stepErr) {
+		return err
+	}
+
+	stepErr = &StepError{
+		NodeID:   node.GetID(),
+		NodeName: node.GetName(),
+		Path:     slices.Clone(path),
+		Err:      err,
+	}
+
+	stepErr.Attempt = stateAttempts(state)
+
+	return stepErr
+}
+
+// timeoutCause returns the error used as the cause of an expired timeout
+func timeoutCause(timeout time.Duration) error {
+	return fmt.Errorf("%w after %s", ErrTimeout, timeout)
+}
+
+// timeoutError makes sure an error caused by an expired timeout matches ErrTimeout,
+// e.g. when a handler returns context.DeadlineExceeded after respecting the context
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ErrTimeout) {
+		return err
+	}
+
+	cause := context.Cause(ctx)
+	if !errors.Is(cause, ErrTimeout) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", cause, err)
+}
+
+// errorReason classifies an error, to be recorded in the state of a failed workflow
+func errorReason(err error) StateErrorReason {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return StateErrorReasonTimeout
+	case errors.Is(err, context.Canceled):
+		return StateErrorReasonCanceled
+	default:
+		return StateErrorReasonError
+	}
+}
@@ -0,0 +1,68 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_StepError_Nested(t *testing.T) {
+	errDeclined := errors.New("card declined")
+
+	charge := NewStep(
+		WithID("charge"),
+		WithName("Charge"),
+		WithRetry(RetryPolicy{MaxAttempts: 2}),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			return ctx, data, errDeclined
+		}),
+	)
+	payment := NewPipeline(WithID("payment"), WithRunnables(charge))
+	dag := NewDag(WithID("order"), WithRunnables(payment))
+
+	_, _, err := dag.Run(context.Background(), map[string]any{})
+	if !errors.Is(err, errDeclined) {
+		t.Fatalf("Expected the error of the step to be the cause, got %v", err)
+	}
+
+	// The error is wrapped once, by the node that failed
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Expected a StepError, got %T", err)
+	}
+	if stepErr.NodeID != "charge" || stepErr.NodeName != "Charge" {
+		t.Errorf("Expected the charge step to fail, got %s (%s)", stepErr.NodeID, stepErr.NodeName)
+	}
+	if !slices.Equal(stepErr.Path, []string{"order", "payment", "charge"}) {
+		t.Errorf("Expected the path [order payment charge], got %v", stepErr.Path)
+	}
+	if stepErr.Attempt != 2 {
+		t.Errorf("Expected the second attempt to fail, got %d", stepErr.Attempt)
+	}
+	if stepErr.Err != errDeclined {
+		t.Errorf("Expected the handler error, got %v", stepErr.Err)
+	}
+	if message := err.Error(); message != "order > payment > charge: card declined" {
+		t.Errorf("Unexpected error message: %s", message)
+	}
+}
+
+func Test_StepError_Timeout(t *testing.T) {
+	step := NewStep(WithID("slow"), WithTimeout(time.Millisecond), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		<-ctx.Done()
+		return ctx, data, ctx.Err()
+	}))
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(step))
+
+	_, _, err := pipeline.Run(context.Background(), map[string]any{})
+
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.NodeID != "slow" {
+		t.Fatalf("Expected a StepError of the slow step, got %v", err)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected the error to match ErrTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package wf
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// EventType is the type of an event emitted while a workflow runs
+type EventType string
+
+// Event types
+const (
+	// EventWorkflowStarted is emitted when the root of a run starts
+	EventWorkflowStarted EventType = "workflow_started"
+
+	// EventResumed is emitted instead of EventWorkflowStarted, when a paused run is resumed
+	EventResumed EventType = "resumed"
+
+	// EventWorkflowCompleted is emitted when the root of a run completes, or skips itself
+	EventWorkflowCompleted EventType = "workflow_completed"
+
+	// EventWorkflowFailed is emitted when the root of a run fails
+	EventWorkflowFailed EventType = "workflow_failed"
+
+	// EventPaused is emitted when the root of a run, or a nested node, is paused
+	EventPaused EventType = "paused"
+
+	// EventNodeStarted is emitted when a node of a Pipeline or Dag starts
+	EventNodeStarted EventType = "node_started"
+
+	// EventNodeCompleted is emitted when a node of a Pipeline or Dag completes
+	EventNodeCompleted EventType = "node_completed"
+
+	// EventNodeFailed is emitted when a node of a Pipeline or Dag fails
+	EventNodeFailed EventType = "node_failed"
+
+	// EventNodeSkipped is emitted when a node of a Pipeline or Dag is skipped,
+	// either by itself or by the Dag (e.g. its condition was not met)
+	EventNodeSkipped EventType = "node_skipped"
+)
+
+// Event describes something that happened while a workflow runs
+type Event struct {
+	// Type of the event
+	Type EventType
+
+	// RunID is the ID of the run
+	RunID string
+
+	// NodeID is the ID of the node the event is about. For the workflow
+	// events, it is the ID of the root of the run.
+	NodeID string
+
+	// NodeName is the name of the node the event is about
+	NodeName string
+
+	// Path holds the IDs of the nodes from the root of the run down to the
+	// node the event is about, e.g. [dag pipeline step]
+	Path []string
+
+	// Time is when the event happened
+	Time time.Time
+
+	// StartedAt is when the node started. It is zero for a node skipped without running.
+	StartedAt time.Time
+
+	// Duration is the time the node ran, until the event happened
+	Duration time.Duration
+
+	// Err is the error of the node, if there is one
+	Err error
+
+	// Attempts is the number of times the handler of a step was attempted,
+	// set when the step ends. It is zero for Pipelines and Dags.
+	Attempts int
+}
+
+// EventListener receives the events of a workflow run.
+//
+// Listeners are called synchronously, from the goroutine running the node,
+// and one at a time, also for the nodes of a Dag running in parallel.
+// A slow listener slows down the run.
+type EventListener func(event Event)
+
+// listenable is implemented by the runnables that have event listeners
+type listenable interface {
+	GetListeners() []EventListener
+}
+
+// emit sends an event about the node at the end of the path to the listeners.
+// The state of the node is nil, when it is not known.
+func (ex *execution) emit(eventType EventType, node RunnableInterface, state StateInterface, startedAt time.Time, err error) {
+	if len(ex.listeners) == 0 && ex.logger == nil {
+		return
+	}
+
+	event := Event{
+		Type:      eventType,
+		RunID:     ex.runID,
+		NodeID:    node.GetID(),
+		NodeName:  node.GetName(),
+		Path:      slices.Clone(ex.path),
+		Time:      time.Now(),
+		StartedAt: startedAt,
+		Err:       err,
+	}
+
+	if !startedAt.IsZero() {
+		event.Duration = event.Time.Sub(startedAt)
+	}
+
+	event.Attempts = stateAttempts(state)
+
+	ex.emitMu.Lock()
+	defer ex.emitMu.Unlock()
+
+	ex.log(event)
+
+	for _, listener := range ex.listeners {
+		listener(event)
+	}
+}
+
+// nodeEventType returns the type of the event emitted when a node ends
+func nodeEventType(err error, skipped bool) EventType {
+	switch {
+	case errors.Is(err, ErrPaused):
+		return EventPaused
+	case err != nil:
+		return EventNodeFailed
+	case skipped:
+		return EventNodeSkipped
+	default:
+		return EventNodeCompleted
+	}
+}
+
+// workflowEventType returns the type of the event emitted when the root of a run ends
+func workflowEventType(err error) EventType {
+	switch {
+	case errors.Is(err, ErrPaused):
+		return EventPaused
+	case err != nil:
+		return EventWorkflowFailed
+	default:
+		return EventWorkflowCompleted
+	}
+}
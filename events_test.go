@@ -0,0 +1,190 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eventRecorder records the events it receives
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) listen(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) summary() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	summary := []string{}
+	for _, event := range r.events {
+		summary = append(summary, string(event.Type)+" "+strings.Join(event.Path, "/"))
+	}
+	return summary
+}
+
+func Test_Events_Nested(t *testing.T) {
+	root := &eventRecorder{}
+	nested := &eventRecorder{}
+
+	step1 := NewStep(WithID("step1"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	step2 := NewStep(WithID("step2"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, ErrSkip
+	}))
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(step1, step2), WithListener(nested.listen))
+	dag := NewDag(WithID("dag"), WithRunnables(pipeline), WithListener(root.listen))
+
+	run := dag.NewRun(context.Background(), map[string]any{})
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The events of the nested nodes bubble up to the root listener
+	expected := []string{
+		"workflow_started dag",
+		"node_started dag/pipeline",
+		"node_started dag/pipeline/step1",
+		"node_completed dag/pipeline/step1",
+		"node_started dag/pipeline/step2",
+		"node_skipped dag/pipeline/step2",
+		"node_completed dag/pipeline",
+		"workflow_completed dag",
+	}
+	if summary := root.summary(); !slices.Equal(summary, expected) {
+		t.Errorf("Unexpected root events:\n%s", strings.Join(summary, "\n"))
+	}
+
+	// The listener of the pipeline only receives the events of the pipeline
+	if summary := nested.summary(); !slices.Equal(summary, expected[1:7]) {
+		t.Errorf("Unexpected pipeline events:\n%s", strings.Join(summary, "\n"))
+	}
+
+	for _, event := range root.events {
+		if event.RunID != run.GetID() {
+			t.Errorf("Expected run ID %s, got %s", run.GetID(), event.RunID)
+		}
+		if event.NodeID != event.Path[len(event.Path)-1] {
+			t.Errorf("Expected node ID %s at the end of the path %v", event.NodeID, event.Path)
+		}
+		if event.StartedAt.IsZero() || event.Time.Before(event.StartedAt) || event.Duration < 0 {
+			t.Errorf("Unexpected timestamps for %s: started %v, time %v, duration %v", event.Type, event.StartedAt, event.Time, event.Duration)
+		}
+	}
+}
+
+func Test_Events_Failure(t *testing.T) {
+	recorder := &eventRecorder{}
+	stepErr := errors.New("step failed")
+
+	failing := NewStep(WithID("failing"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, stepErr
+	}))
+	dependent := NewStep(WithID("dependent"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(failing, dependent), WithListener(recorder.listen))
+
+	if _, _, err := pipeline.Run(context.Background(), map[string]any{}); !errors.Is(err, stepErr) {
+		t.Fatalf("Expected the step error, got %v", err)
+	}
+
+	expected := []string{
+		"workflow_started pipeline",
+		"node_started pipeline/failing",
+		"node_failed pipeline/failing",
+		"workflow_failed pipeline",
+	}
+	if summary := recorder.summary(); !slices.Equal(summary, expected) {
+		t.Fatalf("Unexpected events:\n%s", strings.Join(summary, "\n"))
+	}
+
+	for _, event := range recorder.events[2:] {
+		if !errors.Is(event.Err, stepErr) {
+			t.Errorf("Expected the step error in %s, got %v", event.Type, event.Err)
+		}
+	}
+
+	if attempts := recorder.events[2].Attempts; attempts != 1 {
+		t.Errorf("Expected 1 attempt of the failing step, got %d", attempts)
+	}
+}
+
+func Test_Events_PauseResume(t *testing.T) {
+	recorder := &eventRecorder{}
+
+	step := NewStep(WithID("step"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		if _, ok := data["input"]; !ok {
+			return ctx, data, ErrPaused
+		}
+		return ctx, data, nil
+	}))
+	dag := NewDag(WithID("dag"), WithRunnables(step), WithListener(recorder.listen))
+
+	_, data, err := dag.Run(context.Background(), map[string]any{})
+	if !errors.Is(err, ErrPaused) {
+		t.Fatalf("Expected ErrPaused, got %v", err)
+	}
+
+	data["input"] = true
+	if _, _, err := dag.Resume(context.Background(), data); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	expected := []string{
+		"workflow_started dag",
+		"node_started dag/step",
+		"paused dag/step",
+		"paused dag",
+		"resumed dag",
+		"node_started dag/step",
+		"node_completed dag/step",
+		"workflow_completed dag",
+	}
+	if summary := recorder.summary(); !slices.Equal(summary, expected) {
+		t.Errorf("Unexpected events:\n%s", strings.Join(summary, "\n"))
+	}
+}
+
+func Test_Events_DagSkipped(t *testing.T) {
+	recorder := &eventRecorder{}
+
+	step1 := NewStep(WithID("step1"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	step2 := NewStep(WithID("step2"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	never := func(ctx context.Context, data map[string]any) bool {
+		return false
+	}
+	dag := NewDag(WithID("dag"), WithRunnables(step1, step2), WithDependencyIf(step2, never, step1), WithListener(recorder.listen))
+
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// A node skipped by the DAG does not start
+	var skipped *Event
+	for _, event := range recorder.events {
+		if event.Type == EventNodeSkipped {
+			skipped = &event
+		}
+	}
+	if skipped == nil || skipped.NodeID != "step2" {
+		t.Fatalf("Expected step2 to be skipped, got:\n%s", strings.Join(recorder.summary(), "\n"))
+	}
+	if !skipped.StartedAt.IsZero() || skipped.Duration != 0 {
+		t.Errorf("Expected no start time for a node that did not run, got %v", skipped.StartedAt)
+	}
+}
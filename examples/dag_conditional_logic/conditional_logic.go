@@ -196,3 +196,58 @@ func RunConditionalExampleWithPipelines(orderType string, totalAmount float64) (
 
 	return data, nil
 }
+
+// NewConditionalDagWithConditionalDependencies creates a single DAG that handles
+// every order type, using a conditional dependency
+//
+// The AddShipping step depends on ApplyDiscount only for physical orders.
+// For other order types the condition does not hold and AddShipping is skipped.
+// CalculateTax is marked to run on skip, so it runs after a skipped AddShipping.
+// The same DAG can be reused for any order type, the decision is taken
+// at run time, based on the "orderType" value in the data
+//
+// Returns:
+// - dag: The DAG with conditional dependencies
+func NewConditionalDagWithConditionalDependencies() wf.DagInterface {
+	processOrder := NewStepProcessOrder()
+	applyDiscount := NewStepApplyDiscount()
+	addShipping := NewStepAddShipping()
+	calculateTax := NewStepCalculateTax()
+
+	isPhysicalOrder := func(ctx context.Context, data map[string]any) bool {
+		return data["orderType"] == "physical"
+	}
+
+	return wf.NewDag(
+		wf.WithName("Conditional Order Processing With Conditional Dependencies"),
+		wf.WithRunnables(processOrder, applyDiscount, addShipping, calculateTax),
+		wf.WithDependency(applyDiscount, processOrder),
+		wf.WithDependencyIf(addShipping, isPhysicalOrder, applyDiscount),
+		wf.WithDependency(calculateTax, applyDiscount, addShipping),
+		wf.WithRunOnSkip(calculateTax), // still runs when AddShipping is skipped
+	)
+}
+
+// RunConditionalExampleWithConditionalDependencies runs the conditional logic
+// example using a single DAG with conditional dependencies
+func RunConditionalExampleWithConditionalDependencies(orderType string, totalAmount float64) (map[string]any, error) {
+	if orderType != "digital" && orderType != "physical" && orderType != "subscription" {
+		return nil, errors.New("invalid order type")
+	}
+
+	dag := NewConditionalDagWithConditionalDependencies()
+
+	ctx := context.Background()
+	data := map[string]any{
+		"orderType":     orderType,
+		"totalAmount":   totalAmount,
+		"stepsExecuted": []string{},
+	}
+	_, data, err := dag.Run(ctx, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
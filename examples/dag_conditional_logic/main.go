@@ -49,4 +49,24 @@ func main() {
 	fmt.Printf("Using pipelines:\n")
 	fmt.Printf("Total amount: %.2f\n", data["totalAmount"].(float64))
 	fmt.Println("Steps executed:", data["stepsExecuted"].([]string))
+
+	// Run a single DAG with conditional dependencies, for every order type
+	dag = NewConditionalDagWithConditionalDependencies()
+
+	fmt.Printf("Using conditional dependencies:\n")
+	for _, orderType := range []string{"digital", "physical", "subscription"} {
+		_, data, err = dag.Run(context.Background(), map[string]any{
+			"orderType":     orderType,
+			"totalAmount":   100.0,
+			"stepsExecuted": []string{},
+		})
+		if err != nil {
+			fmt.Printf("Error running DAG with conditional dependencies: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Order type: %s\n", orderType)
+		fmt.Printf("Total amount: %.2f\n", data["totalAmount"].(float64))
+		fmt.Println("Steps executed:", data["stepsExecuted"].([]string))
+	}
 }
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"testing"
+
+	"github.com/dracory/wf"
 )
 
 func TestErrorHandling(t *testing.T) {
@@ -15,9 +18,13 @@ func TestErrorHandling(t *testing.T) {
 		return
 	}
 
-	// Verify the error message
-	if err.Error() != "intentional error" {
-		t.Errorf("Expected error 'intentional error', got '%v'", err)
+	// Verify the failed step and its error
+	var stepErr *wf.StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Expected a StepError, got %T", err)
+	}
+	if stepErr.NodeName != "Intentional Error" || stepErr.Err.Error() != "intentional error" {
+		t.Errorf("Expected error 'intentional error' from step 'Intentional Error', got '%v' from '%s'", stepErr.Err, stepErr.NodeName)
 	}
 
 	// Verify the value was still processed
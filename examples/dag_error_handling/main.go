@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+
+	"github.com/dracory/wf"
 )
 
 func main() {
@@ -11,6 +14,12 @@ func main() {
 
 	_, data, err := dag.Run(context.Background(), map[string]any{})
 	if err != nil {
+		// The error tells which step failed
+		var stepErr *wf.StepError
+		if errors.As(err, &stepErr) {
+			fmt.Printf("Error in step %q: %v\n", stepErr.NodeName, stepErr.Err)
+			return
+		}
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
@@ -2,16 +2,12 @@ package email_verification
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/dracory/wf"
 )
 
-// contextKey is a custom type for context keys
-type contextKey string
-
-const dagKey contextKey = "dag"
-
 // NewSendEmailStep creates a step that sends a verification email
 func NewSendEmailStep() wf.StepInterface {
 	step := wf.NewStep()
@@ -58,16 +54,10 @@ func NewVerifyCodeStep() wf.StepInterface {
 		// Check if we have an entered code
 		enteredCode, ok := data["enteredCode"].(string)
 		if !ok {
-			// If no code entered yet, pause the workflow
-			if dag, ok := ctx.Value(dagKey).(wf.DagInterface); ok {
-				if dag.IsRunning() {
-					if err := dag.Pause(); err != nil {
-						return ctx, data, fmt.Errorf("failed to pause workflow: %v", err)
-					}
-					fmt.Println("Workflow paused waiting for verification code")
-				}
-			}
-			return ctx, data, nil
+			// If no code entered yet, pause the workflow.
+			// The step runs again, when the workflow is resumed.
+			fmt.Println("Workflow paused waiting for verification code")
+			return ctx, data, wf.ErrPaused
 		}
 
 		expectedCode := data["verificationCode"].(string)
@@ -128,15 +118,16 @@ func RunEmailVerificationExample() error {
 
 	// Initialize data
 	ctx := context.Background()
-	// Add the DAG to the context so the SendEmailStep can access it
-	ctx = context.WithValue(ctx, dagKey, dag)
 	data := map[string]any{
 		"email": "user@example.com",
 	}
 
-	// Start workflow
+	// Start workflow, it pauses waiting for the verification code
 	ctx, data, err := dag.Run(ctx, data)
-	if err != nil {
+	if err == nil {
+		return fmt.Errorf("workflow completed without waiting for the verification code")
+	}
+	if !errors.Is(err, wf.ErrPaused) {
 		return fmt.Errorf("workflow failed: %v", err)
 	}
 
@@ -158,7 +149,10 @@ func RunEmailVerificationExample() error {
 	}
 	newDag.SetState(newState)
 
-	// Resume workflow with the updated context
+	// The user entered the verification code
+	data["enteredCode"] = "123456"
+
+	// Resume workflow with the entered code
 	_, _, err = newDag.Resume(ctx, data)
 	if err != nil {
 		return fmt.Errorf("workflow resume failed: %v", err)
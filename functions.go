@@ -1,8 +1,10 @@
 package wf
 
 import (
+	"context"
 	"errors"
 	"sort"
+	"time"
 )
 
 // visitNode performs a depth-first search to detect cycles and build the topological order
@@ -140,3 +142,132 @@ func buildDependencyGraph(runnables map[string]RunnableInterface, dependencies m
 
 	return graph
 }
+
+// dependenciesSkipped checks whether any of the given dependencies is in the skipped set
+func dependenciesSkipped(dependencies []RunnableInterface, skipped map[string]bool) bool {
+	for _, dep := range dependencies {
+		if skipped[dep.GetID()] {
+			return true
+		}
+	}
+	return false
+}
+
+// markSkipped records the state of a node, which will not run, as skipped.
+// For a definition's Run, the state is also set on nodes that expose their state.
+func markSkipped(parent StateInterface, node RunnableInterface, ex *execution) {
+	state := NewState()
+	state.SetStatus(StateStatusSkipped)
+	setStateChild(parent, node.GetID(), state)
+	ex.nested(node).emit(EventNodeSkipped, node, nil, time.Time{}, nil)
+
+	if !ex.bindStates {
+		return
+	}
+
+	stateful, ok := node.(interface{ SetState(state StateInterface) })
+	if !ok {
+		return
+	}
+
+	stateful.SetState(state)
+}
+
+// pause marks a running state as paused. It is safe to call while the workflow
+// runs, from another goroutine: Pipelines and Dags stop starting nodes once they
+// see the paused state, and return ErrPaused after the running nodes finish.
+func pause(state StateInterface) error {
+	if state.GetStatus() != StateStatusRunning {
+		return errors.New("workflow is not running")
+	}
+	state.SetStatus(StateStatusPaused)
+
+	// The workflow finished, before it could be paused
+	if state.GetStatus() != StateStatusPaused {
+		return errors.New("workflow is not running")
+	}
+
+	return nil
+}
+
+// recordPause marks the state of a Pipeline or Dag as paused, and saves a checkpoint.
+// It returns ErrPaused, or the error of the checkpoint.
+func recordPause(ctx context.Context, data map[string]any, state StateInterface, ex *execution) error {
+	state.SetWorkflowData(data)
+	state.SetStatus(StateStatusPaused)
+
+	if err := ex.checkpoint(ctx); err != nil {
+		return errors.Join(ErrPaused, err)
+	}
+
+	return ErrPaused
+}
+
+// recordCompletion marks the state of a Pipeline or Dag, whose nodes are all done, as completed.
+// A pause requested after the last node was started has nothing left to pause.
+func recordCompletion(state StateInterface) {
+	if state.GetStatus() == StateStatusPaused {
+		state.SetStatus(StateStatusRunning)
+	}
+	state.SetStatus(StateStatusComplete)
+}
+
+// recordFailure marks the state as failed, recording the error and the reason of the failure
+func recordFailure(state StateInterface, err error) {
+	setStateLastError(state, err.Error())
+	setStateErrorReason(state, errorReason(err))
+
+	// A failure while pausing still fails the workflow
+	if state.GetStatus() == StateStatusPaused {
+		state.SetStatus(StateStatusRunning)
+	}
+	state.SetStatus(StateStatusFailed)
+}
+
+// timeoutKey is the context key marking the contexts with the timeout of a node
+type timeoutKey struct{}
+
+// withTimeout returns a context that expires after the timeout, with ErrTimeout as its cause.
+// A timeout of zero or less returns the context unchanged.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, timeoutCause(timeout))
+	return context.WithValue(ctx, timeoutKey{}, true), cancel
+}
+
+// hasTimeout checks whether the context has the timeout of a node (see withTimeout)
+func hasTimeout(ctx context.Context) bool {
+	return ctx.Value(timeoutKey{}) != nil
+}
+
+// detachTimeout returns the context to pass on, after a node ran with a timeout context.
+// The values added to the result context are kept, but its deadline and cancellation
+// are replaced by the ones of the parent context, so the timeout of a node does not
+// affect the nodes that run after it.
+func detachTimeout(parent, timeoutCtx, result context.Context) context.Context {
+	if timeoutCtx == parent || result == nil || result == parent {
+		return result
+	}
+	if result == timeoutCtx {
+		return parent
+	}
+	return valuesContext{Context: parent, values: result}
+}
+
+// valuesContext is a context with the cancellation of the embedded context
+// and the values of another context
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+// Value returns the value associated with the key in the values context.
+// Whether there is a timeout is taken from the embedded context.
+func (c valuesContext) Value(key any) any {
+	if _, ok := key.(timeoutKey); ok {
+		return c.Context.Value(key)
+	}
+	return c.values.Value(key)
+}
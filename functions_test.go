@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func Test_VisitNode(t *testing.T) {
@@ -499,3 +500,38 @@ func Test_BuildDependencyGraph_CircularDependencies(t *testing.T) {
 		t.Errorf("Expected step2 to depend on step1")
 	}
 }
+
+func Test_DetachTimeout(t *testing.T) {
+	type ctxKey string
+
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	timeoutCtx, timeoutCancel := withTimeout(parent, time.Hour)
+	defer timeoutCancel()
+
+	// Without a timeout, the result is returned unchanged
+	if got := detachTimeout(parent, parent, timeoutCtx); got != timeoutCtx {
+		t.Error("Expected the result context to be returned unchanged, when there is no timeout")
+	}
+
+	// The timeout context itself is replaced by the parent
+	if got := detachTimeout(parent, timeoutCtx, timeoutCtx); got != parent {
+		t.Error("Expected the parent context, when the result is the timeout context")
+	}
+
+	// A derived context keeps its values, with the parent's deadline and cancellation
+	result := context.WithValue(timeoutCtx, ctxKey("key"), "value")
+	detached := detachTimeout(parent, timeoutCtx, result)
+	if detached.Value(ctxKey("key")) != "value" {
+		t.Error("Expected the detached context to keep the values of the result")
+	}
+	if _, ok := detached.Deadline(); ok {
+		t.Error("Expected the detached context to have no deadline")
+	}
+
+	cancel()
+	if detached.Err() == nil {
+		t.Error("Expected the detached context to be canceled with its parent")
+	}
+}
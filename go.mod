@@ -1,15 +1,16 @@
 module github.com/dracory/wf
 
-go 1.25
+go 1.25.0
 
 require (
 	github.com/dracory/arr v0.2.0
 	github.com/dracory/uid v1.8.0
 	github.com/google/uuid v1.6.0
+	go.yaml.in/yaml/v3 v3.0.5
 )
 
 require (
 	github.com/samber/lo v1.52.0 // indirect
 	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546 // indirect
-	golang.org/x/text v0.30.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
 )
@@ -2,6 +2,8 @@ package wf
 
 import (
 	"context"
+	"log/slog"
+	"time"
 )
 
 type StepHandler func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error)
@@ -36,6 +38,7 @@ type RunnableInterface interface {
 // its dependencies and execution order.
 type StepInterface interface {
 	RunnableInterface
+	Compensator
 
 	// GetHandler returns the function that implements the step's execution logic.
 	GetHandler() StepHandler
@@ -43,6 +46,101 @@ type StepInterface interface {
 	// SetHandler allows setting or modifying the step's execution logic.
 	SetHandler(handler StepHandler)
 
+	// GetCompensation returns the function that undoes the work of the step.
+	GetCompensation() StepHandler
+
+	// SetCompensation sets the function that undoes the work of the step.
+	// It runs when a Pipeline or Dag containing the completed step fails.
+	SetCompensation(handler StepHandler)
+
+	// GetHandlerName returns the name the handler is registered under
+	// (see HandlerRegistryInterface), empty if it is not known.
+	GetHandlerName() string
+
+	// SetHandlerName sets the name the handler is registered under,
+	// used when the step is exported to a Definition. A step without
+	// handler function runs the handler registered under the name in
+	// its handler registry (see WithHandlerName).
+	SetHandlerName(name string)
+
+	// GetCompensationName returns the name the compensation handler is
+	// registered under, empty if it is not known.
+	GetCompensationName() string
+
+	// SetCompensationName sets the name the compensation handler is registered under.
+	// A step without compensation function uses the handler registered under
+	// the name in its handler registry (see WithCompensationName).
+	SetCompensationName(name string)
+
+	// GetHandlerRegistry returns the registry the handlers without function
+	// are looked up in by name, nil for DefaultHandlerRegistry.
+	GetHandlerRegistry() HandlerRegistryInterface
+
+	// SetHandlerRegistry sets the registry the handlers without function are
+	// looked up in by name. Nil uses DefaultHandlerRegistry.
+	SetHandlerRegistry(registry HandlerRegistryInterface)
+
+	// GetRetryPolicy returns the policy used to retry the handler, nil if the step is not retried.
+	GetRetryPolicy() *RetryPolicy
+
+	// SetRetryPolicy sets the policy used to retry the handler when it returns an error.
+	SetRetryPolicy(policy *RetryPolicy)
+
+	// GetTimeout returns the maximum duration of a run, zero if there is none.
+	GetTimeout() time.Duration
+
+	// SetTimeout sets the maximum duration of a run. Zero or less disables the timeout.
+	// When the timeout expires, Run fails with an error matching ErrTimeout.
+	SetTimeout(timeout time.Duration)
+
+	// NewRun creates a run, with its own ID and state, without modifying the step.
+	// Unlike Run, it can be used to run the same step many times concurrently.
+	NewRun(ctx context.Context, data map[string]any) RunInterface
+
+	// GetStateStore returns the store the state of a run is saved to, nil if there is none.
+	GetStateStore() StateStore
+
+	// SetStateStore sets the store the state of a run is saved to. The state is saved when the run ends.
+	SetStateStore(store StateStore)
+
+	// GetListeners returns the listeners of the events of the step
+	GetListeners() []EventListener
+
+	// AddListener adds a listener of the events of the step
+	AddListener(listener EventListener)
+
+	// GetMiddlewares returns the middlewares wrapping the runs of the step
+	GetMiddlewares() []Middleware
+
+	// AddMiddleware adds a middleware wrapping the runs of the step
+	AddMiddleware(middleware Middleware)
+
+	// GetLogger returns the logger of the step, nil if there is none
+	GetLogger() *slog.Logger
+
+	// SetLogger sets the logger of the step
+	SetLogger(logger *slog.Logger)
+
+	// ResumeRun creates a run from the state saved in the state store, with the given ID.
+	// A run interrupted while running, e.g. by a crash, is resumed like a paused run.
+	ResumeRun(ctx context.Context, runID string, data map[string]any) (RunInterface, error)
+
+	// IsSkipped checks whether the last run was skipped (see ErrSkip)
+	IsSkipped() bool
+
+	// VisualizeMermaid returns a Mermaid flowchart representation of the workflow component
+	VisualizeMermaid() string
+
+	// VisualizeExpanded returns a DOT graph representation of the workflow component,
+	// with its nested pipelines and DAGs drawn as clusters of their own nodes
+	VisualizeExpanded() string
+
+	// VisualizeSVG returns an SVG image of the workflow component, laid out without Graphviz
+	VisualizeSVG() string
+
+	// VisualizeText returns a text representation of the workflow component, for terminals and logs
+	VisualizeText() string
+
 	// Pause pauses the workflow execution
 	Pause() error
 
@@ -60,6 +158,7 @@ type StepInterface interface {
 // It manages the dependencies between steps and ensures they are executed in the correct sequence.
 type DagInterface interface {
 	RunnableInterface
+	Compensator
 
 	// RunnableAdd adds a single node to the DAG.
 	// Runnable nodes can be added in any order, as their execution order will be determined by their dependencies.
@@ -78,11 +177,98 @@ type DagInterface interface {
 	// The dependent node will only execute after the dependency node has completed successfully.
 	DependencyAdd(dependent RunnableInterface, dependency ...RunnableInterface)
 
-	// DependencyList returns all dependencies for a given node.
-	// The actual dependencies may vary based on the context and any conditional dependencies.
+	// DependencyAddIf adds a conditional dependency between nodes.
+	// The dependent node waits for the dependency nodes, then the condition is evaluated
+	// against the data at run time. If the condition returns false, the dependent node is skipped.
+	DependencyAddIf(dependent RunnableInterface, condition DependencyCondition, dependency ...RunnableInterface)
+
+	// DependencyList returns all dependencies for a given node, the conditional ones
+	// included: the node waits for them, and is skipped if their condition returns false.
 	DependencyList(ctx context.Context, node RunnableInterface, data map[string]any) []RunnableInterface
 
-	// Pause pauses the workflow execution
+	// RunOnSkipAdd marks nodes that should still run when one of their dependencies was skipped.
+	// By default, a node depending on a skipped node is skipped too.
+	RunOnSkipAdd(node ...RunnableInterface)
+
+	// GetMaxConcurrency returns the maximum number of nodes that may run at the same time.
+	GetMaxConcurrency() int
+
+	// SetMaxConcurrency sets the maximum number of nodes that may run at the same time.
+	// Nodes whose dependencies are completed are started concurrently, up to this limit.
+	// The default is 1 (one node at a time). A value less than 1 removes the limit.
+	SetMaxConcurrency(n int)
+
+	// GetFailurePolicy returns what the DAG does when a node fails.
+	GetFailurePolicy() FailurePolicy
+
+	// SetFailurePolicy sets what the DAG does when a node fails:
+	//   - FailurePolicyFailFast (the default) stops starting nodes after the first failure
+	//   - FailurePolicyContinue runs every node not downstream of a failed node
+	//   - FailurePolicyContinueAll runs every node, the failed nodes count as done for their dependents
+	// With the continue policies, Run fails at the end with an error joining
+	// the StepErrors of all the failed nodes (see errors.Join).
+	SetFailurePolicy(policy FailurePolicy)
+
+	// GetTimeout returns the maximum duration of a run, zero if there is none.
+	GetTimeout() time.Duration
+
+	// SetTimeout sets the maximum duration of a run. Zero or less disables the timeout.
+	// When the timeout expires, Run fails with an error matching ErrTimeout.
+	SetTimeout(timeout time.Duration)
+
+	// NewRun creates a run, with its own ID and state, without modifying the DAG.
+	// Unlike Run, it can be used to run the same DAG many times concurrently.
+	NewRun(ctx context.Context, data map[string]any) RunInterface
+
+	// GetStateStore returns the store the state of a run is saved to, nil if there is none.
+	GetStateStore() StateStore
+
+	// SetStateStore sets the store the state of a run is saved to. The state is saved after every completed node, and when the run ends.
+	SetStateStore(store StateStore)
+
+	// GetListeners returns the listeners of the events of the run and its nodes
+	GetListeners() []EventListener
+
+	// AddListener adds a listener of the events of the run and its nodes,
+	// including the events of the nested Pipelines and Dags
+	AddListener(listener EventListener)
+
+	// GetMiddlewares returns the middlewares wrapping the run and its nodes
+	GetMiddlewares() []Middleware
+
+	// AddMiddleware adds a middleware wrapping the run and its nodes,
+	// including the nodes of the nested Pipelines and Dags
+	AddMiddleware(middleware Middleware)
+
+	// GetLogger returns the logger of the run and its nodes, nil if there is none
+	GetLogger() *slog.Logger
+
+	// SetLogger sets the logger of the run and its nodes. Nested Pipelines
+	// and Dags with their own logger use it instead.
+	SetLogger(logger *slog.Logger)
+
+	// ResumeRun creates a run from the state saved in the state store, with the given ID.
+	// A run interrupted while running, e.g. by a crash, is resumed like a paused run.
+	ResumeRun(ctx context.Context, runID string, data map[string]any) (RunInterface, error)
+
+	// IsSkipped checks whether the last run was skipped (see ErrSkip)
+	IsSkipped() bool
+
+	// VisualizeMermaid returns a Mermaid flowchart representation of the workflow component
+	VisualizeMermaid() string
+
+	// VisualizeExpanded returns a DOT graph representation of the workflow component,
+	// with its nested pipelines and DAGs drawn as clusters of their own nodes
+	VisualizeExpanded() string
+
+	// VisualizeSVG returns an SVG image of the workflow component, laid out without Graphviz
+	VisualizeSVG() string
+
+	// VisualizeText returns a text representation of the workflow component, for terminals and logs
+	VisualizeText() string
+
+	// Pause pauses the workflow execution. It can be called while Run is running:
+	// no new nodes are started, and Run returns ErrPaused after the running nodes finish.
 	Pause() error
 
 	// Resume resumes the workflow execution from the last saved state
@@ -98,6 +284,7 @@ type DagInterface interface {
 // PipelineInterface defines the interface for a pipeline
 type PipelineInterface interface {
 	RunnableInterface
+	Compensator
 
 	// RunnableAdd adds a runnable node(s) to the pipeline.
 	RunnableAdd(node ...RunnableInterface)
@@ -109,7 +296,66 @@ type PipelineInterface interface {
 	// The order of nodes in the returned slice is the order they were added.
 	RunnableList() []RunnableInterface
 
-	// Pause pauses the workflow execution
+	// GetTimeout returns the maximum duration of a run, zero if there is none.
+	GetTimeout() time.Duration
+
+	// SetTimeout sets the maximum duration of a run. Zero or less disables the timeout.
+	// When the timeout expires, Run fails with an error matching ErrTimeout.
+	SetTimeout(timeout time.Duration)
+
+	// NewRun creates a run, with its own ID and state, without modifying the pipeline.
+	// Unlike Run, it can be used to run the same pipeline many times concurrently.
+	NewRun(ctx context.Context, data map[string]any) RunInterface
+
+	// GetStateStore returns the store the state of a run is saved to, nil if there is none.
+	GetStateStore() StateStore
+
+	// SetStateStore sets the store the state of a run is saved to. The state is saved after every completed node, and when the run ends.
+	SetStateStore(store StateStore)
+
+	// GetListeners returns the listeners of the events of the run and its nodes
+	GetListeners() []EventListener
+
+	// AddListener adds a listener of the events of the run and its nodes,
+	// including the events of the nested Pipelines and Dags
+	AddListener(listener EventListener)
+
+	// GetMiddlewares returns the middlewares wrapping the run and its nodes
+	GetMiddlewares() []Middleware
+
+	// AddMiddleware adds a middleware wrapping the run and its nodes,
+	// including the nodes of the nested Pipelines and Dags
+	AddMiddleware(middleware Middleware)
+
+	// GetLogger returns the logger of the run and its nodes, nil if there is none
+	GetLogger() *slog.Logger
+
+	// SetLogger sets the logger of the run and its nodes. Nested Pipelines
+	// and Dags with their own logger use it instead.
+	SetLogger(logger *slog.Logger)
+
+	// ResumeRun creates a run from the state saved in the state store, with the given ID.
+	// A run interrupted while running, e.g. by a crash, is resumed like a paused run.
+	ResumeRun(ctx context.Context, runID string, data map[string]any) (RunInterface, error)
+
+	// IsSkipped checks whether the last run was skipped (see ErrSkip)
+	IsSkipped() bool
+
+	// VisualizeMermaid returns a Mermaid flowchart representation of the workflow component
+	VisualizeMermaid() string
+
+	// VisualizeExpanded returns a DOT graph representation of the workflow component,
+	// with its nested pipelines and DAGs drawn as clusters of their own nodes
+	VisualizeExpanded() string
+
+	// VisualizeSVG returns an SVG image of the workflow component, laid out without Graphviz
+	VisualizeSVG() string
+
+	// VisualizeText returns a text representation of the workflow component, for terminals and logs
+	VisualizeText() string
+
+	// Pause pauses the workflow execution. It can be called while Run is running:
+	// no new nodes are started, and Run returns ErrPaused after the running nodes finish.
 	Pause() error
 
 	// Resume resumes the workflow execution from the last saved state
@@ -0,0 +1,145 @@
+package wf
+
+import (
+	"context"
+	"log/slog"
+)
+
+// loggerKey is the context key of the logger of the running node
+type loggerKey struct{}
+
+// LoggerFromContext returns the logger of the node running with the context,
+// set by WithLogger. Its records carry the attributes of the node
+// (workflow_id, run_id, node_id and node_name), so the logs of a handler can
+// be told apart. It returns slog.Default(), when the workflow has no logger.
+//
+// Example:
+//   func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+//       wf.LoggerFromContext(ctx).Info("charging card", "amount", data["amount"])
+//       ...
+//   }
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// loggerHolder is implemented by the runnables that have a logger
+type loggerHolder interface {
+	GetLogger() *slog.Logger
+}
+
+// nodeLogger returns the logger of the node at the end of the path, with the
+// attributes of the node, or nil when the workflow has no logger
+func (ex *execution) nodeLogger(nodeID string, nodeName string) *slog.Logger {
+	if ex.logger == nil {
+		return nil
+	}
+
+	return ex.logger.With(
+		slog.String("workflow_id", ex.path[0]),
+		slog.String("run_id", ex.runID),
+		slog.String("node_id", nodeID),
+		slog.String("node_name", nodeName),
+	)
+}
+
+// withLogger runs the handler of the node with the logger of the node in its context
+func (ex *execution) withLogger(node RunnableInterface, handler StepHandler) StepHandler {
+	logger := ex.nodeLogger(node.GetID(), node.GetName())
+	if logger == nil {
+		return handler
+	}
+
+	return func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		parent, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+
+		resultCtx, data, err := handler(context.WithValue(ctx, loggerKey{}, logger), data)
+		if resultCtx == nil {
+			resultCtx = ctx
+		}
+
+		// The next nodes do not run with the logger of this node
+		return context.WithValue(resultCtx, loggerKey{}, parent), data, err
+	}
+}
+
+// log logs an event, with the status of the node it is about
+func (ex *execution) log(event Event) {
+	logger := ex.nodeLogger(event.NodeID, event.NodeName)
+	if logger == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("status", string(eventStatus(event.Type))),
+	}
+
+	switch event.Type {
+	case EventWorkflowStarted, EventResumed, EventNodeStarted:
+		level = slog.LevelDebug
+	case EventWorkflowFailed, EventNodeFailed:
+		level = slog.LevelError
+	}
+
+	if !event.StartedAt.IsZero() && event.Duration > 0 {
+		attrs = append(attrs, slog.Duration("duration", event.Duration))
+	}
+
+	if event.Attempts > 0 {
+		attrs = append(attrs, slog.Int("attempts", event.Attempts))
+	}
+
+	if event.Err != nil {
+		attrs = append(attrs, slog.String("error", event.Err.Error()))
+	}
+
+	logger.LogAttrs(context.Background(), level, eventMessage(event), attrs...)
+}
+
+// eventStatus returns the status of the node, after the event
+func eventStatus(eventType EventType) StateStatus {
+	switch eventType {
+	case EventWorkflowCompleted, EventNodeCompleted:
+		return StateStatusComplete
+	case EventWorkflowFailed, EventNodeFailed:
+		return StateStatusFailed
+	case EventNodeSkipped:
+		return StateStatusSkipped
+	case EventPaused:
+		return StateStatusPaused
+	default:
+		return StateStatusRunning
+	}
+}
+
+// eventMessage returns the log message of the event, e.g. "node completed"
+func eventMessage(event Event) string {
+	switch event.Type {
+	case EventWorkflowStarted:
+		return "workflow started"
+	case EventResumed:
+		return "workflow resumed"
+	case EventWorkflowCompleted:
+		return "workflow completed"
+	case EventWorkflowFailed:
+		return "workflow failed"
+	case EventPaused:
+		if len(event.Path) == 1 {
+			return "workflow paused"
+		}
+		return "node paused"
+	case EventNodeStarted:
+		return "node started"
+	case EventNodeCompleted:
+		return "node completed"
+	case EventNodeFailed:
+		return "node failed"
+	case EventNodeSkipped:
+		return "node skipped"
+	default:
+		return string(event.Type)
+	}
+}
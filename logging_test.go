@@ -0,0 +1,124 @@
+package wf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logRecords parses the records written by a JSON handler
+func logRecords(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	records := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		record := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// findRecord returns the first record with the given message and node ID
+func findRecord(records []map[string]any, msg string, nodeID string) map[string]any {
+	for _, record := range records {
+		if record["msg"] == msg && record["node_id"] == nodeID {
+			return record
+		}
+	}
+	return nil
+}
+
+func Test_Logger(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	stepErr := errors.New("card declined")
+
+	validate := NewStep(WithID("validate"), WithName("Validate"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		LoggerFromContext(ctx).Info("validating order")
+		return ctx, data, nil
+	}))
+	charge := NewStep(WithID("charge"), WithName("Charge"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		LoggerFromContext(ctx).Info("charging card")
+		return ctx, data, stepErr
+	}))
+	pipeline := NewPipeline(WithID("payment"), WithName("Payment"), WithRunnables(validate, charge))
+	dag := NewDag(WithID("order"), WithName("Order"), WithRunnables(pipeline), WithLogger(logger))
+
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); !errors.Is(err, stepErr) {
+		t.Fatalf("Expected the step error, got %v", err)
+	}
+
+	records := logRecords(t, buffer)
+
+	started := findRecord(records, "workflow started", "order")
+	if started == nil || started["level"] != "DEBUG" || started["status"] != "running" || started["workflow_id"] != "order" {
+		t.Errorf("Unexpected workflow started record: %v", started)
+	}
+
+	completed := findRecord(records, "node completed", "validate")
+	if completed == nil || completed["node_name"] != "Validate" || completed["status"] != "complete" || completed["workflow_id"] != "order" {
+		t.Errorf("Unexpected node completed record: %v", completed)
+	}
+	if _, ok := completed["duration"]; !ok {
+		t.Errorf("Expected the duration of the node, got %v", completed)
+	}
+
+	failed := findRecord(records, "node failed", "charge")
+	if failed == nil || failed["level"] != "ERROR" || failed["status"] != "failed" || !strings.HasSuffix(failed["error"].(string), stepErr.Error()) {
+		t.Errorf("Unexpected node failed record: %v", failed)
+	}
+
+	workflowFailed := findRecord(records, "workflow failed", "order")
+	if workflowFailed == nil || !strings.Contains(workflowFailed["error"].(string), stepErr.Error()) {
+		t.Errorf("Unexpected workflow failed record: %v", workflowFailed)
+	}
+
+	// The logs of the handlers carry the attributes of their step
+	if record := findRecord(records, "validating order", "validate"); record == nil || record["workflow_id"] != "order" || record["node_name"] != "Validate" {
+		t.Errorf("Expected the log of the handler to carry the attributes of its step, got %v", record)
+	}
+	if record := findRecord(records, "charging card", "charge"); record == nil {
+		t.Error("Expected the next step to log with its own attributes")
+	}
+}
+
+func Test_Logger_NestedOverride(t *testing.T) {
+	outer := &bytes.Buffer{}
+	inner := &bytes.Buffer{}
+
+	step := NewStep(WithID("step"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		LoggerFromContext(ctx).Info("working")
+		return ctx, data, nil
+	}))
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(step), WithLogger(slog.New(slog.NewJSONHandler(inner, nil))))
+	dag := NewDag(WithID("dag"), WithRunnables(pipeline), WithLogger(slog.New(slog.NewJSONHandler(outer, nil))))
+
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The nodes of the pipeline log with the logger of the pipeline
+	if record := findRecord(logRecords(t, inner), "working", "step"); record == nil || record["workflow_id"] != "dag" {
+		t.Errorf("Expected the step to log with the logger of the pipeline, got %v", record)
+	}
+	if record := findRecord(logRecords(t, outer), "workflow completed", "dag"); record == nil {
+		t.Error("Expected the DAG to log with its own logger")
+	}
+	if strings.Contains(outer.String(), "working") {
+		t.Error("Expected the step not to log with the logger of the DAG")
+	}
+}
+
+func Test_LoggerFromContext_Default(t *testing.T) {
+	if LoggerFromContext(context.Background()) != slog.Default() {
+		t.Error("Expected the default logger, when the workflow has no logger")
+	}
+}
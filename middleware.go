@@ -0,0 +1,56 @@
+package wf
+
+import "slices"
+
+// NodeInfo describes the node run through a Middleware
+type NodeInfo struct {
+	// RunID is the ID of the run
+	RunID string
+
+	// Node is the Step, Pipeline or Dag being run
+	Node RunnableInterface
+
+	// Path holds the IDs of the nodes from the root of the run down to the node
+	Path []string
+
+	// State of the node for this run. It is nil for nodes implemented
+	// outside this package, which manage their own state.
+	State StateInterface
+}
+
+// Middleware wraps the run of the root of a run, and of every node of its
+// Pipelines and Dags, e.g. to trace or time it. The returned handler must
+// call next, and the context it passes to next is the context the node runs with.
+//
+// Example:
+//   func timing(node NodeInfo, next StepHandler) StepHandler {
+//       return func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+//           start := time.Now()
+//           ctx, data, err := next(ctx, data)
+//           log.Printf("%s took %s", node.Node.GetName(), time.Since(start))
+//           return ctx, data, err
+//       }
+//   }
+type Middleware func(node NodeInfo, next StepHandler) StepHandler
+
+// middlewareHolder is implemented by the runnables that have middlewares
+type middlewareHolder interface {
+	GetMiddlewares() []Middleware
+}
+
+// wrap wraps the handler running the given node with the middlewares of the
+// execution. The first middleware added is the outermost one.
+func (ex *execution) wrap(node RunnableInterface, state StateInterface, handler StepHandler) StepHandler {
+	info := NodeInfo{
+		RunID: ex.runID,
+		Node:  node,
+		Path:  slices.Clone(ex.path),
+		State: state,
+	}
+
+	for i := len(ex.middlewares) - 1; i >= 0; i-- {
+		handler = ex.middlewares[i](info, handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,61 @@
+package wf
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type middlewareKey struct{}
+
+func Test_Middleware_Nested(t *testing.T) {
+	var mu sync.Mutex
+	calls := []string{}
+
+	// The middleware records the nodes it wraps, and passes their path to the handlers
+	middleware := func(name string) Middleware {
+		return func(node NodeInfo, next StepHandler) StepHandler {
+			return func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				mu.Lock()
+				calls = append(calls, name+" "+strings.Join(node.Path, "/"))
+				mu.Unlock()
+
+				if node.State == nil {
+					t.Errorf("Expected the state of %s", node.Node.GetID())
+				}
+
+				return next(context.WithValue(ctx, middlewareKey{}, node.Path), data)
+			}
+		}
+	}
+
+	var handlerPath []string
+	step := NewStep(WithID("step"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		handlerPath, _ = ctx.Value(middlewareKey{}).([]string)
+		return ctx, data, nil
+	}))
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(step), WithMiddleware(middleware("inner")))
+	dag := NewDag(WithID("dag"), WithRunnables(pipeline), WithMiddleware(middleware("outer")))
+
+	if err := dag.NewRun(context.Background(), map[string]any{}).Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The middlewares of the root wrap every node, the ones of the pipeline only its nodes
+	expected := []string{
+		"outer dag",
+		"outer dag/pipeline",
+		"inner dag/pipeline",
+		"outer dag/pipeline/step",
+		"inner dag/pipeline/step",
+	}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Unexpected middleware calls:\n%s", strings.Join(calls, "\n"))
+	}
+
+	if !slices.Equal(handlerPath, []string{"dag", "pipeline", "step"}) {
+		t.Errorf("Expected the handler to run with the context of the middleware, got %v", handlerPath)
+	}
+}
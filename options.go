@@ -1,6 +1,10 @@
 package wf
 
-import "context"
+import (
+	"context"
+	"log/slog"
+	"time"
+)
 
 // Nameable is an interface for types that can have a name
 type Nameable interface {
@@ -12,6 +16,31 @@ type Identifiable interface {
 	SetID(id string)
 }
 
+// TimeoutSetter is an interface for types that can have a timeout
+type TimeoutSetter interface {
+	SetTimeout(timeout time.Duration)
+}
+
+// StateStoreSetter is an interface for types that can be saved to a state store
+type StateStoreSetter interface {
+	SetStateStore(store StateStore)
+}
+
+// ListenerAdder is an interface for types that can have event listeners
+type ListenerAdder interface {
+	AddListener(listener EventListener)
+}
+
+// MiddlewareAdder is an interface for types that can have middlewares
+type MiddlewareAdder interface {
+	AddMiddleware(middleware Middleware)
+}
+
+// LoggerSetter is an interface for types that can have a logger
+type LoggerSetter interface {
+	SetLogger(logger *slog.Logger)
+}
+
 // WithName is a generic option that sets the name of any type that implements Nameable
 func WithName(name string) func(Nameable) {
 	return func(n Nameable) {
@@ -26,6 +55,68 @@ func WithID(id string) func(Identifiable) {
 	}
 }
 
+// WithTimeout is a generic option that sets the maximum duration of a Step, Pipeline or Dag.
+// The handlers receive a context with the corresponding deadline. When the timeout expires,
+// the run fails with an error matching ErrTimeout (use errors.Is(err, ErrTimeout)),
+// and a handler that does not return in time is abandoned.
+func WithTimeout(timeout time.Duration) func(TimeoutSetter) {
+	return func(t TimeoutSetter) {
+		t.SetTimeout(timeout)
+	}
+}
+
+// WithStateStore is a generic option that sets the state store of a Step, Pipeline or Dag.
+// The state of a run is saved to the store after every completed node, and when the run ends.
+//
+// Example:
+//   store, err := NewFileStateStore("./runs")
+//   ...
+//   dag := NewDag(
+//       WithName("Order Processing"),
+//       WithStateStore(store),
+//   )
+func WithStateStore(store StateStore) func(StateStoreSetter) {
+	return func(s StateStoreSetter) {
+		s.SetStateStore(store)
+	}
+}
+
+// WithListener is a generic option that adds an event listener to a Step, Pipeline or Dag.
+// The listener receives the events of every run, including the events of
+// the nodes of nested Pipelines and Dags.
+//
+// Example:
+//   dag := NewDag(
+//       WithName("Order Processing"),
+//       WithListener(func(event Event) {
+//           log.Printf("%s %s %v", event.Type, strings.Join(event.Path, "/"), event.Err)
+//       }),
+//   )
+func WithListener(listener EventListener) func(ListenerAdder) {
+	return func(l ListenerAdder) {
+		l.AddListener(listener)
+	}
+}
+
+// WithMiddleware is a generic option that adds a middleware to a Step, Pipeline or Dag.
+// The middleware wraps the run of the runnable, and of every node of its
+// Pipelines and Dags, including the nodes of nested Pipelines and Dags.
+func WithMiddleware(middleware Middleware) func(MiddlewareAdder) {
+	return func(m MiddlewareAdder) {
+		m.AddMiddleware(middleware)
+	}
+}
+
+// WithLogger is a generic option that sets the logger of a Step, Pipeline or Dag.
+// The start and end of the run and of every node are logged, with the attributes
+// workflow_id, run_id, node_id, node_name and status, and the duration and error
+// when the node ends. The handlers get the logger of their step with LoggerFromContext.
+func WithLogger(logger *slog.Logger) func(LoggerSetter) {
+	return func(l LoggerSetter) {
+		l.SetLogger(logger)
+	}
+}
+
 // StepOption is a function that configures a Step
 // This is a type alias for backward compatibility
 // Deprecated: Use functional options directly instead
@@ -38,6 +129,80 @@ func WithHandler(handler func(context.Context, map[string]any) (context.Context,
 	}
 }
 
+// WithCompensation sets the compensation handler of a step.
+// When a Pipeline or Dag fails, the compensation handlers of its completed steps
+// run in reverse completion order, to undo their side effects (saga pattern).
+//
+// Example:
+//   reserveStock := NewStep(
+//       WithName("Reserve Stock"),
+//       WithHandler(reserveStockHandler),
+//       WithCompensation(releaseStockHandler),
+//   )
+func WithCompensation(handler StepHandler) func(StepInterface) {
+	return func(s StepInterface) {
+		s.SetCompensation(handler)
+	}
+}
+
+// WithHandlerName sets the handler of a step by name. The handler is looked up
+// in the registry when the step runs, so it may be registered later, and the
+// name is kept when the workflow is exported (see ExportDefinition).
+// A nil registry uses DefaultHandlerRegistry.
+// The step fails if no handler is registered under the name.
+//
+// Example:
+//   registry := NewHandlerRegistry()
+//   registry.Register("orders.charge", chargeCard)
+//
+//   step := NewStep(
+//       WithName("Charge"),
+//       WithHandlerName("orders.charge", registry),
+//   )
+func WithHandlerName(name string, registry HandlerRegistryInterface) func(StepInterface) {
+	return func(s StepInterface) {
+		s.SetHandler(nil)
+		s.SetHandlerName(name)
+		s.SetHandlerRegistry(registry)
+	}
+}
+
+// WithCompensationName sets the compensation handler of a step by name, looked
+// up in the registry like with WithHandlerName. A step has a single registry,
+// which the handler and the compensation are both looked up in.
+func WithCompensationName(name string, registry HandlerRegistryInterface) func(StepInterface) {
+	return func(s StepInterface) {
+		s.SetCompensation(nil)
+		s.SetCompensationName(name)
+		s.SetHandlerRegistry(registry)
+	}
+}
+
+// WithRetry sets the retry policy of a step.
+// When the handler returns a retryable error, it is called again after a backoff,
+// until it succeeds or MaxAttempts is reached.
+//
+// Example:
+//   step := NewStep(
+//       WithName("Fetch Orders"),
+//       WithHandler(fetchOrders),
+//       WithRetry(RetryPolicy{
+//           MaxAttempts:    5,
+//           InitialBackoff: 100 * time.Millisecond,
+//           MaxBackoff:     5 * time.Second,
+//           Multiplier:     2,
+//           Jitter:         0.2,
+//           Retryable: func(err error) bool {
+//               return errors.Is(err, ErrServiceUnavailable)
+//           },
+//       }),
+//   )
+func WithRetry(policy RetryPolicy) func(StepInterface) {
+	return func(s StepInterface) {
+		s.SetRetryPolicy(&policy)
+	}
+}
+
 // RunnableAdder is an interface that defines the RunnableAdd method
 // RunnableAdder is an interface for types that can add runnable nodes
 type RunnableAdder interface {
@@ -49,6 +214,11 @@ type DependencyAdder interface {
 	DependencyAdd(dependent RunnableInterface, dependency ...RunnableInterface)
 }
 
+// ConditionalDependencyAdder is an interface for types that can add conditional dependencies between nodes
+type ConditionalDependencyAdder interface {
+	DependencyAddIf(dependent RunnableInterface, condition DependencyCondition, dependency ...RunnableInterface)
+}
+
 // WithRunnables adds multiple runnable nodes to a Pipeline or Dag.
 // Nil nodes are filtered out and not added.
 func WithRunnables(nodes ...RunnableInterface) func(RunnableAdder) {
@@ -92,3 +262,78 @@ func WithDependency(dependent RunnableInterface, dependencies ...RunnableInterfa
 		}
 	}
 }
+
+// WithDependencyIf adds a conditional dependency between nodes in a DAG.
+// The dependent node waits for the dependency nodes, then the condition is evaluated
+// against the data at run time. If the condition returns false, the dependent node is skipped.
+//
+// Example:
+//   dag := NewDag(
+//       WithRunnables(processOrder, addShipping),
+//       WithDependencyIf(addShipping, func(ctx context.Context, data map[string]any) bool {
+//           return data["orderType"] == "physical"
+//       }, processOrder), // addShipping only runs for physical orders
+//   )
+func WithDependencyIf(dependent RunnableInterface, condition DependencyCondition, dependencies ...RunnableInterface) func(ConditionalDependencyAdder) {
+	return func(da ConditionalDependencyAdder) {
+		if dependent == nil || condition == nil {
+			return
+		}
+		var validDeps []RunnableInterface
+		for _, dep := range dependencies {
+			if dep != nil {
+				validDeps = append(validDeps, dep)
+			}
+		}
+		da.DependencyAddIf(dependent, condition, validDeps...)
+	}
+}
+
+// WithRunOnSkip marks DAG nodes that should still run when one of their dependencies was skipped.
+// By default, a node depending on a skipped node is skipped too.
+//
+// Example:
+//   dag := NewDag(
+//       WithRunnables(applyDiscount, addShipping, calculateTax),
+//       WithDependencyIf(addShipping, isPhysicalOrder, applyDiscount),
+//       WithDependency(calculateTax, applyDiscount, addShipping),
+//       WithRunOnSkip(calculateTax), // runs even when addShipping is skipped
+//   )
+func WithRunOnSkip(nodes ...RunnableInterface) func(DagInterface) {
+	return func(d DagInterface) {
+		d.RunOnSkipAdd(nodes...)
+	}
+}
+
+// WithMaxConcurrency sets the maximum number of nodes a DAG runs at the same time.
+// Nodes whose dependencies are completed are started concurrently, up to this limit.
+// The default is 1 (one node at a time). A value less than 1 removes the limit.
+//
+// Example:
+//   dag := NewDag(
+//       WithName("ETL"),
+//       WithRunnables(fetchUsers, fetchOrders, merge),
+//       WithDependency(merge, fetchUsers, fetchOrders),
+//       WithMaxConcurrency(4), // fetchUsers and fetchOrders run in parallel
+//   )
+func WithMaxConcurrency(n int) func(DagInterface) {
+	return func(d DagInterface) {
+		d.SetMaxConcurrency(n)
+	}
+}
+
+// WithFailurePolicy sets what a DAG does when one of its nodes fails.
+// By default the DAG stops at the first failure (FailurePolicyFailFast).
+//
+// Example:
+//   dag := NewDag(
+//       WithName("Nightly Reports"),
+//       WithRunnables(salesReport, stockReport, sendReports),
+//       WithDependency(sendReports, salesReport, stockReport),
+//       WithFailurePolicy(FailurePolicyContinue), // stockReport runs even if salesReport fails
+//   )
+func WithFailurePolicy(policy FailurePolicy) func(DagInterface) {
+	return func(d DagInterface) {
+		d.SetFailurePolicy(policy)
+	}
+}
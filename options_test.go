@@ -2,6 +2,7 @@ package wf
 
 import (
 	"context"
+	"log/slog"
 	"testing"
 )
 
@@ -225,3 +226,82 @@ func Test_WithDependency_CombinedWithOtherOptions(t *testing.T) {
 		t.Error("Dependency was not set correctly")
 	}
 }
+
+func Test_WithMaxConcurrency(t *testing.T) {
+	dag := NewDag(
+		WithName("Test DAG"),
+		WithMaxConcurrency(4),
+	)
+
+	if got := dag.GetMaxConcurrency(); got != 4 {
+		t.Errorf("Expected max concurrency 4, got %d", got)
+	}
+
+	if got := NewDag().GetMaxConcurrency(); got != 1 {
+		t.Errorf("Expected default max concurrency 1, got %d", got)
+	}
+}
+
+func Test_WithStateStore(t *testing.T) {
+	store := NewMemoryStateStore()
+
+	if NewDag(WithStateStore(store)).GetStateStore() != store {
+		t.Error("Expected the DAG to use the state store")
+	}
+	if NewPipeline(WithStateStore(store)).GetStateStore() != store {
+		t.Error("Expected the pipeline to use the state store")
+	}
+	if NewStep(WithStateStore(store)).GetStateStore() != store {
+		t.Error("Expected the step to use the state store")
+	}
+	if NewDag().GetStateStore() != nil {
+		t.Error("Expected no state store by default")
+	}
+}
+
+func Test_WithListener(t *testing.T) {
+	listener := func(event Event) {}
+
+	if len(NewDag(WithListener(listener)).GetListeners()) != 1 {
+		t.Error("Expected the DAG to have the listener")
+	}
+	if len(NewPipeline(WithListener(listener), WithListener(listener)).GetListeners()) != 2 {
+		t.Error("Expected the pipeline to have both listeners")
+	}
+	if len(NewStep(WithListener(listener)).GetListeners()) != 1 {
+		t.Error("Expected the step to have the listener")
+	}
+}
+
+func Test_WithMiddleware(t *testing.T) {
+	middleware := func(node NodeInfo, next StepHandler) StepHandler {
+		return next
+	}
+
+	if len(NewDag(WithMiddleware(middleware)).GetMiddlewares()) != 1 {
+		t.Error("Expected the DAG to have the middleware")
+	}
+	if len(NewPipeline(WithMiddleware(middleware)).GetMiddlewares()) != 1 {
+		t.Error("Expected the pipeline to have the middleware")
+	}
+	if len(NewStep(WithMiddleware(middleware)).GetMiddlewares()) != 1 {
+		t.Error("Expected the step to have the middleware")
+	}
+}
+
+func Test_WithLogger(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+
+	if NewDag(WithLogger(logger)).GetLogger() != logger {
+		t.Error("Expected the DAG to use the logger")
+	}
+	if NewPipeline(WithLogger(logger)).GetLogger() != logger {
+		t.Error("Expected the pipeline to use the logger")
+	}
+	if NewStep(WithLogger(logger)).GetLogger() != logger {
+		t.Error("Expected the step to use the logger")
+	}
+	if NewDag().GetLogger() != nil {
+		t.Error("Expected no logger by default")
+	}
+}
@@ -0,0 +1,127 @@
+// Package otelwf traces workflow runs with OpenTelemetry.
+//
+// The middleware returned by NewMiddleware starts a span for the run of a
+// Step, Pipeline or Dag, and for every node of its Pipelines and Dags, so the
+// spans mirror the nesting of the workflow. The span of a node is in the
+// context passed to its handler, so the spans started by a handler are
+// children of the span of its step.
+//
+// Example:
+//   dag := wf.NewDag(
+//       wf.WithName("Order Processing"),
+//       wf.WithMiddleware(otelwf.NewMiddleware()),
+//   )
+package otelwf
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/dracory/wf"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer
+const instrumentationName = "github.com/dracory/wf/otelwf"
+
+// Attribute keys of the spans
+const (
+	AttributeRunID    = attribute.Key("wf.run_id")
+	AttributeNodeID   = attribute.Key("wf.node_id")
+	AttributeNodeName = attribute.Key("wf.node_name")
+	AttributePath     = attribute.Key("wf.path")
+	AttributeStatus   = attribute.Key("wf.status")
+	AttributeAttempt  = attribute.Key("wf.attempt")
+)
+
+// MiddlewareOption configures the middleware
+type MiddlewareOption func(*middleware)
+
+// WithTracerProvider sets the tracer provider the spans are created with.
+// By default the global tracer provider is used.
+func WithTracerProvider(provider trace.TracerProvider) MiddlewareOption {
+	return func(m *middleware) {
+		m.provider = provider
+	}
+}
+
+type middleware struct {
+	provider trace.TracerProvider
+}
+
+// NewMiddleware creates a middleware tracing the runs of a Step, Pipeline or
+// Dag, and of all their nodes. Add it with wf.WithMiddleware.
+func NewMiddleware(opts ...MiddlewareOption) wf.Middleware {
+	m := &middleware{
+		provider: otel.GetTracerProvider(),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	tracer := m.provider.Tracer(instrumentationName)
+
+	return func(node wf.NodeInfo, next wf.StepHandler) wf.StepHandler {
+		return func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			parent := trace.SpanFromContext(ctx)
+
+			spanCtx, span := tracer.Start(ctx, spanName(node.Node),
+				trace.WithSpanKind(trace.SpanKindInternal),
+				trace.WithAttributes(
+					AttributeRunID.String(node.RunID),
+					AttributeNodeID.String(node.Node.GetID()),
+					AttributeNodeName.String(node.Node.GetName()),
+					AttributePath.String(strings.Join(node.Path, "/")),
+				),
+			)
+			defer span.End()
+
+			resultCtx, data, err := next(spanCtx, data)
+
+			span.SetAttributes(AttributeStatus.String(string(status(node, err))))
+			if state, ok := node.State.(wf.AttemptsState); ok && state.GetAttempts() > 0 {
+				span.SetAttributes(AttributeAttempt.Int(state.GetAttempts()))
+			}
+
+			// A paused node did not fail
+			if err != nil && !errors.Is(err, wf.ErrPaused) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			if resultCtx == nil {
+				resultCtx = ctx
+			}
+
+			// The next nodes are siblings of this node, not its children
+			return trace.ContextWithSpan(resultCtx, parent), data, err
+		}
+	}
+}
+
+// spanName returns the name of the span of the node, its name or else its ID
+func spanName(node wf.RunnableInterface) string {
+	if node.GetName() != "" {
+		return node.GetName()
+	}
+	return node.GetID()
+}
+
+// status returns the status of the node after its run
+func status(node wf.NodeInfo, err error) wf.StateStatus {
+	switch {
+	case node.State != nil:
+		return node.State.GetStatus()
+	case errors.Is(err, wf.ErrPaused):
+		return wf.StateStatusPaused
+	case err != nil:
+		return wf.StateStatusFailed
+	default:
+		return wf.StateStatusComplete
+	}
+}
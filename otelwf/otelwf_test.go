@@ -0,0 +1,147 @@
+package otelwf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dracory/wf"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	return provider, exporter
+}
+
+// spansByName returns the ended spans, by name
+func spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+// attributeValue returns the value of the attribute of the span, nil if it is not set
+func attributeValue(span tracetest.SpanStub, key attribute.Key) any {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+func Test_Middleware_SpanHierarchy(t *testing.T) {
+	provider, exporter := newTestProvider(t)
+
+	attempts := 0
+	charge := wf.NewStep(
+		wf.WithID("charge"),
+		wf.WithName("Charge"),
+		wf.WithRetry(wf.RetryPolicy{MaxAttempts: 2}),
+		wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			attempts++
+			if attempts == 1 {
+				return ctx, data, errors.New("temporary failure")
+			}
+
+			// The handler traces its own work, under the span of the step
+			_, span := provider.Tracer("test").Start(ctx, "Payment API")
+			span.End()
+
+			return ctx, data, nil
+		}),
+	)
+	receipt := wf.NewStep(wf.WithID("receipt"), wf.WithName("Receipt"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+
+	payment := wf.NewPipeline(wf.WithID("payment"), wf.WithName("Payment"), wf.WithRunnables(charge, receipt))
+	dag := wf.NewDag(
+		wf.WithID("order"),
+		wf.WithName("Order"),
+		wf.WithRunnables(payment),
+		wf.WithMiddleware(NewMiddleware(WithTracerProvider(provider))),
+	)
+
+	run := dag.NewRun(context.Background(), map[string]any{})
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	spans := spansByName(exporter)
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 spans, got %d", len(spans))
+	}
+
+	parents := map[string]string{
+		"Payment":     "Order",
+		"Charge":      "Payment",
+		"Receipt":     "Payment",
+		"Payment API": "Charge",
+	}
+	for name, parentName := range parents {
+		if spans[name].Parent.SpanID() != spans[parentName].SpanContext.SpanID() {
+			t.Errorf("Expected the span %s to be a child of %s", name, parentName)
+		}
+	}
+	if spans["Order"].Parent.IsValid() {
+		t.Error("Expected the span of the root to have no parent")
+	}
+
+	order := spans["Order"]
+	if attributeValue(order, AttributeRunID) != run.GetID() || attributeValue(order, AttributeNodeID) != "order" {
+		t.Errorf("Unexpected attributes of the root span: %v", order.Attributes)
+	}
+
+	chargeSpan := spans["Charge"]
+	if attributeValue(chargeSpan, AttributePath) != "order/payment/charge" {
+		t.Errorf("Expected the path order/payment/charge, got %v", attributeValue(chargeSpan, AttributePath))
+	}
+	if attributeValue(chargeSpan, AttributeStatus) != string(wf.StateStatusComplete) {
+		t.Errorf("Expected the status complete, got %v", attributeValue(chargeSpan, AttributeStatus))
+	}
+	if attributeValue(chargeSpan, AttributeAttempt) != int64(2) {
+		t.Errorf("Expected attempt 2, got %v", attributeValue(chargeSpan, AttributeAttempt))
+	}
+}
+
+func Test_Middleware_Error(t *testing.T) {
+	provider, exporter := newTestProvider(t)
+
+	stepErr := errors.New("card declined")
+	step := wf.NewStep(wf.WithName("Charge"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, stepErr
+	}))
+	pipeline := wf.NewPipeline(
+		wf.WithName("Payment"),
+		wf.WithRunnables(step),
+		wf.WithMiddleware(NewMiddleware(WithTracerProvider(provider))),
+	)
+
+	if _, _, err := pipeline.Run(context.Background(), map[string]any{}); !errors.Is(err, stepErr) {
+		t.Fatalf("Expected the step error, got %v", err)
+	}
+
+	for name, span := range spansByName(exporter) {
+		if span.Status.Code != codes.Error {
+			t.Errorf("Expected the span %s to have an error status, got %v", name, span.Status.Code)
+		}
+		if attributeValue(span, AttributeStatus) != string(wf.StateStatusFailed) {
+			t.Errorf("Expected the span %s to have the status failed, got %v", name, attributeValue(span, AttributeStatus))
+		}
+		if len(span.Events) == 0 || span.Events[0].Name != "exception" {
+			t.Errorf("Expected the error to be recorded on the span %s", name)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package wf
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"slices"
+	"time"
 
 	"github.com/dracory/arr"
 	"github.com/google/uuid"
@@ -14,6 +17,21 @@ type pipelineImplementation struct {
 	name  string
 	nodes []RunnableInterface
 	state StateInterface
+
+	// maximum duration of a run, zero means no timeout
+	timeout time.Duration
+
+	// store the state of a run is saved to, nil if it is not saved
+	store StateStore
+
+	// listeners of the events of a run
+	listeners []EventListener
+
+	// middlewares wrapping the runs
+	middlewares []Middleware
+
+	// logger of the runs, nil if they are not logged
+	logger *slog.Logger
 }
 
 // NewPipeline creates a new pipeline with the given options
@@ -33,6 +51,16 @@ func NewPipeline(opts ...interface{}) PipelineInterface {
 			o(p) // Handles WithID
 		case func(RunnableAdder):
 			o(p) // Handles WithRunnables
+		case func(StateStoreSetter):
+			o(p) // Handles WithStateStore
+		case func(TimeoutSetter):
+			o(p) // Handles WithTimeout
+		case func(ListenerAdder):
+			o(p) // Handles WithListener
+		case func(MiddlewareAdder):
+			o(p) // Handles WithMiddleware
+		case func(LoggerSetter):
+			o(p) // Handles WithLogger
 		}
 	}
 
@@ -57,50 +85,80 @@ func (p *pipelineImplementation) SetName(name string) {
 	p.name = name
 }
 
-func (p *pipelineImplementation) Run(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
-	// If we have a saved state, use it
-	if p.state.GetStatus() == StateStatusPaused {
-		return p.resumeFromState(ctx, data)
-	}
+// GetTimeout returns the maximum duration of the pipeline, zero if there is none
+func (p *pipelineImplementation) GetTimeout() time.Duration {
+	return p.timeout
+}
 
-	// Initialize new state
-	p.state = NewState()
-	p.state.SetStatus(StateStatusRunning)
-	p.state.SetWorkflowData(data)
+// SetTimeout sets the maximum duration of the pipeline. Zero or less disables the timeout.
+func (p *pipelineImplementation) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
 
-	// Execute steps in order
-	for _, node := range p.nodes {
-		// Skip completed steps
-		if slices.Contains(p.state.GetCompletedSteps(), node.GetID()) {
-			continue
-		}
+// GetStateStore returns the store the state of a run is saved to, nil if there is none
+func (p *pipelineImplementation) GetStateStore() StateStore {
+	return p.store
+}
 
-		// Update current step
-		p.state.SetCurrentStepID(node.GetID())
+// SetStateStore sets the store the state of a run is saved to, after every completed node
+func (p *pipelineImplementation) SetStateStore(store StateStore) {
+	p.store = store
+}
 
-		// Execute step
-		ctx, data, err := node.Run(ctx, data)
-		if err != nil {
-			p.state.SetStatus(StateStatusFailed)
-			return ctx, data, err
-		}
+// GetListeners returns the listeners of the events of the pipeline and its nodes
+func (p *pipelineImplementation) GetListeners() []EventListener {
+	return p.listeners
+}
+
+// AddListener adds a listener of the events of the pipeline and its nodes
+func (p *pipelineImplementation) AddListener(listener EventListener) {
+	p.listeners = append(p.listeners, listener)
+}
+
+// GetMiddlewares returns the middlewares wrapping the runs of the pipeline and its nodes
+func (p *pipelineImplementation) GetMiddlewares() []Middleware {
+	return p.middlewares
+}
+
+// AddMiddleware adds a middleware wrapping the runs of the pipeline and its nodes
+func (p *pipelineImplementation) AddMiddleware(middleware Middleware) {
+	p.middlewares = append(p.middlewares, middleware)
+}
 
-		// Mark step as completed
-		p.state.AddCompletedStep(node.GetID())
-		p.state.SetWorkflowData(data)
+// GetLogger returns the logger of the pipeline and its nodes, nil if there is none
+func (p *pipelineImplementation) GetLogger() *slog.Logger {
+	return p.logger
+}
+
+// SetLogger sets the logger of the pipeline and its nodes
+func (p *pipelineImplementation) SetLogger(logger *slog.Logger) {
+	p.logger = logger
+}
+
+func (p *pipelineImplementation) Run(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+	// Initialize new state, unless a saved state is resumed
+	if p.state.GetStatus() != StateStatusPaused {
+		p.state = NewState()
 	}
 
-	p.state.SetStatus(StateStatusComplete)
-	return ctx, data, nil
+	return runDefinition(ctx, data, p, p.state)
+}
+
+// NewRun creates a run of the pipeline, with its own ID and state.
+// The pipeline and its nodes are not modified by the run.
+func (p *pipelineImplementation) NewRun(ctx context.Context, data map[string]any) RunInterface {
+	return newRun(p, ctx, data)
+}
+
+// ResumeRun creates a run from the state saved in the state store, with the given ID.
+// The nodes completed before the last checkpoint are not run again.
+func (p *pipelineImplementation) ResumeRun(ctx context.Context, runID string, data map[string]any) (RunInterface, error) {
+	return resumeRun(ctx, p, runID, data)
 }
 
 // Pause pauses the workflow execution
 func (p *pipelineImplementation) Pause() error {
-	if p.state.GetStatus() != StateStatusRunning {
-		return fmt.Errorf("workflow is not running")
-	}
-	p.state.SetStatus(StateStatusPaused)
-	return nil
+	return pause(p.state)
 }
 
 // Resume resumes the workflow execution from the last saved state
@@ -108,54 +166,118 @@ func (p *pipelineImplementation) Resume(ctx context.Context, data map[string]any
 	if p.state.GetStatus() != StateStatusPaused {
 		return ctx, data, fmt.Errorf("workflow is not paused")
 	}
-	return p.resumeFromState(ctx, data)
+	return runDefinition(ctx, data, p, p.state)
 }
 
-// resumeFromState resumes the workflow from the saved state
-func (p *pipelineImplementation) resumeFromState(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
-	// Update data with saved state
-	savedData := p.state.GetWorkflowData()
-	for k, v := range savedData {
-		data[k] = v
-	}
-
-	// Find the current step
-	currentStepID := p.state.GetCurrentStepID()
-	var currentStepIndex int
-	for i, node := range p.nodes {
-		if node.GetID() == currentStepID {
-			currentStepIndex = i
-			break
+// runWithState runs the pipeline with the given state. A paused state is resumed.
+func (p *pipelineImplementation) runWithState(ctx context.Context, data map[string]any, state StateInterface, ex *execution) (context.Context, map[string]any, error) {
+	if state.GetStatus() == StateStatusPaused {
+		// Update data with saved state
+		savedData := state.GetWorkflowData()
+		for k, v := range savedData {
+			data[k] = v
 		}
+		state.SetStatus(StateStatusRunning)
+	} else {
+		state.SetStatus(StateStatusRunning)
+		state.SetWorkflowData(data)
 	}
 
 	// Execute remaining steps
-	p.state.SetStatus(StateStatusRunning)
-	for i := currentStepIndex; i < len(p.nodes); i++ {
-		node := p.nodes[i]
+	return p.runNodes(ctx, data, state, ex)
+}
+
+// runNodes executes, in order, the nodes that are not completed or skipped yet.
+// The pipeline stops at the first failing node, or when its timeout expires.
+// When the workflow is paused, the running node finishes, and ErrPaused is returned.
+func (p *pipelineImplementation) runNodes(ctx context.Context, data map[string]any, state StateInterface, ex *execution) (context.Context, map[string]any, error) {
+	runCtx, cancel := withTimeout(ctx, p.timeout)
+	defer cancel()
+
+	parentCtx := ctx
+	ctx = runCtx
 
-		// Skip completed steps
-		if slices.Contains(p.state.GetCompletedSteps(), node.GetID()) {
+	for _, node := range p.nodes {
+		// Skip completed and skipped steps
+		if slices.Contains(state.GetCompletedSteps(), node.GetID()) || slices.Contains(skippedSteps(state), node.GetID()) {
 			continue
 		}
 
+		// Stop, if the timeout expired or the context was canceled
+		if ctx.Err() != nil {
+			return p.fail(detachTimeout(parentCtx, runCtx, ctx), data, state, context.Cause(ctx))
+		}
+
+		// Stop, if the workflow was paused
+		if ex.pauseRequested(state) {
+			ctx = detachTimeout(parentCtx, runCtx, ctx)
+			return ctx, data, recordPause(ctx, data, state, ex)
+		}
+
 		// Update current step
-		p.state.SetCurrentStepID(node.GetID())
+		state.SetCurrentStepID(node.GetID())
 
 		// Execute step
-		ctx, data, err := node.Run(ctx, data)
+		var skipped bool
+		var err error
+		ctx, data, skipped, err = runNode(ctx, data, node, nodeState(state, node, ex), ex)
+
+		// The node was paused, so is the pipeline
+		if errors.Is(err, ErrPaused) {
+			ctx = detachTimeout(parentCtx, runCtx, ctx)
+			return ctx, data, recordPause(ctx, data, state, ex)
+		}
+
 		if err != nil {
-			p.state.SetStatus(StateStatusFailed)
-			return ctx, data, err
+			return p.fail(detachTimeout(parentCtx, runCtx, ctx), data, state, timeoutError(runCtx, err))
+		}
+
+		// A skipped step does not stop the pipeline
+		if skipped {
+			addSkippedStep(state, node.GetID())
+		} else {
+			state.AddCompletedStep(node.GetID())
+		}
+		state.SetWorkflowData(data)
+
+		if err := ex.checkpoint(ctx); err != nil {
+			return p.fail(detachTimeout(parentCtx, runCtx, ctx), data, state, err)
 		}
+	}
+
+	recordCompletion(state)
+	return detachTimeout(parentCtx, runCtx, ctx), data, nil
+}
+
+// fail compensates the completed nodes and marks the pipeline as failed
+func (p *pipelineImplementation) fail(ctx context.Context, data map[string]any, state StateInterface, err error) (context.Context, map[string]any, error) {
+	data, err = compensateFailure(ctx, data, state, p.findNode, err)
+	recordFailure(state, err)
+	return ctx, data, err
+}
 
-		// Mark step as completed
-		p.state.AddCompletedStep(node.GetID())
-		p.state.SetWorkflowData(data)
+// Compensate undoes the work of a completed pipeline, by compensating
+// its completed nodes in reverse completion order
+func (p *pipelineImplementation) Compensate(ctx context.Context, data map[string]any) (context.Context, map[string]any, []CompensationResult) {
+	return p.compensateWithState(ctx, data, p.state)
+}
+
+// compensateWithState compensates the completed nodes, if the given state is completed
+func (p *pipelineImplementation) compensateWithState(ctx context.Context, data map[string]any, state StateInterface) (context.Context, map[string]any, []CompensationResult) {
+	if state.GetStatus() != StateStatusComplete {
+		return ctx, data, nil
 	}
+	return compensateNodes(ctx, data, state, p.findNode)
+}
 
-	p.state.SetStatus(StateStatusComplete)
-	return ctx, data, nil
+// findNode returns the node with the given ID
+func (p *pipelineImplementation) findNode(id string) (RunnableInterface, bool) {
+	for _, node := range p.nodes {
+		if node.GetID() == id {
+			return node, true
+		}
+	}
+	return nil, false
 }
 
 // GetState returns the current workflow state
@@ -215,6 +337,10 @@ func (p *pipelineImplementation) IsFailed() bool {
 	return p.state.GetStatus() == StateStatusFailed
 }
 
+func (p *pipelineImplementation) IsSkipped() bool {
+	return p.state.GetStatus() == StateStatusSkipped
+}
+
 func (p *pipelineImplementation) IsWaiting() bool {
 	return p.state.GetStatus() == "" // Initial state before running
 }
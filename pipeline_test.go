@@ -2,8 +2,12 @@ package wf
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_Pipeline_Basic(t *testing.T) {
@@ -167,10 +171,13 @@ func Test_Pipeline_ErrorPropagation(t *testing.T) {
 	// Test error propagation
 	ctx := context.Background()
 	_, _, err := pipeline.Run(ctx, make(map[string]any))
+	var stepErr *StepError
 	if err == nil {
 		t.Errorf("Expected error from step1, got nil")
-	} else if err.Error() != "step1 failed" {
+	} else if !errors.As(err, &stepErr) || stepErr.Err.Error() != "step1 failed" {
 		t.Errorf("Expected specific error message, got: %v", err)
+	} else if !slices.Equal(stepErr.Path, []string{pipeline.GetID(), step1.GetID()}) {
+		t.Errorf("Expected the path of step1, got %v", stepErr.Path)
 	}
 }
 
@@ -219,3 +226,174 @@ func Test_Pipeline_LargeSequence(t *testing.T) {
 		t.Errorf("Expected counter to be 100, got: %d", counter)
 	}
 }
+
+func Test_Pipeline_Timeout(t *testing.T) {
+	var slowCalls, nextCalls atomic.Int32
+	slow := NewStep(
+		WithName("Slow"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			slowCalls.Add(1)
+			<-ctx.Done()
+			return ctx, data, ctx.Err()
+		}),
+	)
+	next := NewStep(
+		WithName("Next"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			nextCalls.Add(1)
+			return ctx, data, nil
+		}),
+	)
+
+	pipeline := NewPipeline(
+		WithRunnables(slow, next),
+		WithTimeout(20*time.Millisecond),
+	)
+
+	_, _, err := pipeline.Run(context.Background(), map[string]any{})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+
+	if slowCalls.Load() != 1 || nextCalls.Load() != 0 {
+		t.Errorf("Expected only the slow step to run, got slow=%d next=%d", slowCalls.Load(), nextCalls.Load())
+	}
+	if !pipeline.IsFailed() {
+		t.Errorf("Expected pipeline to be failed, got %s", pipeline.GetState().GetStatus())
+	}
+	if reason := pipeline.GetState().(ErrorReasonState).GetErrorReason(); reason != StateErrorReasonTimeout {
+		t.Errorf("Expected error reason %q, got %q", StateErrorReasonTimeout, reason)
+	}
+}
+
+func Test_Pipeline_Pause(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var step1Calls, step2Calls atomic.Int32
+	step1 := NewStep(WithID("step1"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		step1Calls.Add(1)
+		close(started)
+		<-release
+		data["step1"] = true
+		return ctx, data, nil
+	}))
+	step2 := NewStep(WithID("step2"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		step2Calls.Add(1)
+		data["step2"] = true
+		return ctx, data, nil
+	}))
+
+	pipeline := NewPipeline(WithRunnables(step1, step2))
+
+	// Pause from another goroutine, while step1 is running
+	go func() {
+		<-started
+		if err := pipeline.Pause(); err != nil {
+			t.Errorf("Pause failed: %v", err)
+		}
+		close(release)
+	}()
+
+	ctx, data, err := pipeline.Run(context.Background(), map[string]any{})
+	if !errors.Is(err, ErrPaused) {
+		t.Fatalf("Expected ErrPaused, got %v", err)
+	}
+
+	// The running step finishes, the next one is not started
+	if step1Calls.Load() != 1 || step2Calls.Load() != 0 {
+		t.Errorf("Expected only step1 to run, got step1=%d step2=%d", step1Calls.Load(), step2Calls.Load())
+	}
+	if !pipeline.IsPaused() {
+		t.Fatalf("Expected pipeline to be paused, got %s", pipeline.GetState().GetStatus())
+	}
+
+	_, data, err = pipeline.Resume(ctx, data)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if step1Calls.Load() != 1 || step2Calls.Load() != 1 {
+		t.Errorf("Expected only step2 to run when resumed, got step1=%d step2=%d", step1Calls.Load(), step2Calls.Load())
+	}
+	if data["step1"] != true || data["step2"] != true {
+		t.Errorf("Expected the data of both steps, got %v", data)
+	}
+	if !pipeline.IsCompleted() {
+		t.Errorf("Expected pipeline to be completed, got %s", pipeline.GetState().GetStatus())
+	}
+
+	if err := pipeline.Pause(); err == nil {
+		t.Error("Expected an error when pausing a completed pipeline")
+	}
+}
+
+func Test_Pipeline_Pause_Nested(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore()
+
+	var run RunInterface
+	var calls atomic.Int32
+
+	step1 := NewStep(WithID("step1"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		calls.Add(1)
+		if run.IsRunning() {
+			if err := run.Pause(); err != nil {
+				return ctx, data, err
+			}
+		}
+		return ctx, data, nil
+	}))
+	step2 := NewStep(WithID("step2"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		calls.Add(1)
+		return ctx, data, nil
+	}))
+	step3 := NewStep(WithID("step3"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		calls.Add(1)
+		return ctx, data, nil
+	}))
+
+	inner := NewPipeline(WithID("inner"), WithRunnables(step1, step2))
+	outer := NewPipeline(WithRunnables(inner, step3), WithStateStore(store))
+
+	// Pausing the run stops the nested pipeline too
+	run = outer.NewRun(ctx, map[string]any{})
+	run.SetID("run-1")
+	if err := run.Execute(); !errors.Is(err, ErrPaused) {
+		t.Fatalf("Expected ErrPaused, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected only step1 to run, got %d calls", calls.Load())
+	}
+
+	saved, err := store.Load(ctx, "run-1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if saved.GetStatus() != StateStatusPaused {
+		t.Errorf("Expected the saved run to be paused, got %s", saved.GetStatus())
+	}
+	innerState := saved.(ParentState).GetChildState("inner")
+	if innerState == nil || innerState.GetStatus() != StateStatusPaused {
+		t.Fatal("Expected the saved state of the nested pipeline to be paused")
+	}
+	if !slices.Equal(innerState.GetCompletedSteps(), []string{"step1"}) {
+		t.Errorf("Expected step1 to be completed, got %v", innerState.GetCompletedSteps())
+	}
+
+	resumed, err := outer.ResumeRun(ctx, "run-1", nil)
+	if err != nil {
+		t.Fatalf("ResumeRun failed: %v", err)
+	}
+	run = resumed
+	if err := resumed.Execute(); err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("Expected step2 and step3 to run when resumed, got %d calls", calls.Load())
+	}
+	if !resumed.IsCompleted() {
+		t.Errorf("Expected the resumed run to be completed, got %s", resumed.GetState().GetStatus())
+	}
+}
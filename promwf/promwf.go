@@ -0,0 +1,219 @@
+// Package promwf collects Prometheus metrics of workflow runs.
+//
+// The collector is fed by the events of the runs (see wf.EventListener), and is
+// registered like any other prometheus.Collector. Its labels use the names of the
+// workflows and nodes, not their IDs, so the number of series stays bounded.
+//
+// Example:
+//   collector := promwf.NewCollector()
+//   prometheus.MustRegister(collector)
+//
+//   dag := wf.NewDag(
+//       wf.WithName("Order Processing"),
+//       wf.WithListener(collector.Listen),
+//   )
+package promwf
+
+import (
+	"sync"
+
+	"github.com/dracory/wf"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace is the namespace of the metrics
+const DefaultNamespace = "wf"
+
+// CollectorInterface is a prometheus.Collector of the metrics of workflow runs
+type CollectorInterface interface {
+	prometheus.Collector
+
+	// Listen records the metrics of an event. Add it to the root of the
+	// workflow with wf.WithListener, it receives the events of all the nodes.
+	Listen(event wf.Event)
+}
+
+// CollectorOption configures a collector
+type CollectorOption func(*collectorImplementation)
+
+// WithNamespace sets the namespace of the metrics, "wf" by default
+func WithNamespace(namespace string) CollectorOption {
+	return func(c *collectorImplementation) {
+		c.namespace = namespace
+	}
+}
+
+// WithBuckets sets the buckets of the duration histograms, in seconds.
+// By default prometheus.DefBuckets is used.
+func WithBuckets(buckets []float64) CollectorOption {
+	return func(c *collectorImplementation) {
+		c.buckets = buckets
+	}
+}
+
+type collectorImplementation struct {
+	namespace string
+	buckets   []float64
+
+	runsStarted   *prometheus.CounterVec
+	runsCompleted *prometheus.CounterVec
+	runsFailed    *prometheus.CounterVec
+	runsPaused    *prometheus.CounterVec
+	runDuration   *prometheus.HistogramVec
+	nodeDuration  *prometheus.HistogramVec
+	nodeRetries   *prometheus.CounterVec
+
+	// workflows holds the name of the workflow of the runs in progress, by run ID,
+	// as the events of the nodes only carry the name of their node
+	workflowsMu sync.Mutex
+	workflows   map[string]string
+}
+
+var _ CollectorInterface = (*collectorImplementation)(nil)
+
+// NewCollector creates a collector of the metrics of workflow runs:
+//   - wf_runs_started_total, wf_runs_completed_total, wf_runs_failed_total and
+//     wf_runs_paused_total, by workflow
+//   - wf_run_duration_seconds, by workflow and status
+//   - wf_node_duration_seconds, by workflow, node and status
+//   - wf_node_retries_total, by workflow and node
+func NewCollector(opts ...CollectorOption) CollectorInterface {
+	c := &collectorImplementation{
+		namespace: DefaultNamespace,
+		buckets:   prometheus.DefBuckets,
+		workflows: make(map[string]string),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+
+	histogram := func(name, help string, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.namespace,
+			Name:      name,
+			Help:      help,
+			Buckets:   c.buckets,
+		}, labels)
+	}
+
+	c.runsStarted = counter("runs_started_total", "Number of workflow runs started.", "workflow")
+	c.runsCompleted = counter("runs_completed_total", "Number of workflow runs completed.", "workflow")
+	c.runsFailed = counter("runs_failed_total", "Number of workflow runs failed.", "workflow")
+	c.runsPaused = counter("runs_paused_total", "Number of workflow runs paused.", "workflow")
+	c.runDuration = histogram("run_duration_seconds", "Duration of the workflow runs, until they ended or paused.", "workflow", "status")
+	c.nodeDuration = histogram("node_duration_seconds", "Duration of the runs of the workflow nodes.", "workflow", "node", "status")
+	c.nodeRetries = counter("node_retries_total", "Number of retried attempts of the workflow steps.", "workflow", "node")
+
+	return c
+}
+
+// Describe sends the descriptors of the metrics
+func (c *collectorImplementation) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect sends the metrics
+func (c *collectorImplementation) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// Listen records the metrics of an event
+func (c *collectorImplementation) Listen(event wf.Event) {
+	root := len(event.Path) == 1
+
+	switch event.Type {
+	case wf.EventWorkflowStarted:
+		c.setWorkflow(event.RunID, event.NodeName)
+		c.runsStarted.WithLabelValues(event.NodeName).Inc()
+
+	case wf.EventResumed:
+		c.setWorkflow(event.RunID, event.NodeName)
+
+	case wf.EventWorkflowCompleted:
+		c.endRun(event, c.runsCompleted, wf.StateStatusComplete)
+
+	case wf.EventWorkflowFailed:
+		c.endRun(event, c.runsFailed, wf.StateStatusFailed)
+
+	case wf.EventPaused:
+		if root {
+			c.endRun(event, c.runsPaused, wf.StateStatusPaused)
+		} else {
+			c.endNode(event, wf.StateStatusPaused)
+		}
+
+	case wf.EventNodeCompleted:
+		c.endNode(event, wf.StateStatusComplete)
+
+	case wf.EventNodeFailed:
+		c.endNode(event, wf.StateStatusFailed)
+
+	case wf.EventNodeSkipped:
+		c.endNode(event, wf.StateStatusSkipped)
+	}
+}
+
+// endRun records the end of a run, or its pause
+func (c *collectorImplementation) endRun(event wf.Event, counter *prometheus.CounterVec, status wf.StateStatus) {
+	c.workflowsMu.Lock()
+	delete(c.workflows, event.RunID)
+	c.workflowsMu.Unlock()
+
+	counter.WithLabelValues(event.NodeName).Inc()
+	c.runDuration.WithLabelValues(event.NodeName, string(status)).Observe(event.Duration.Seconds())
+}
+
+// endNode records the end of a node. Nodes skipped without running have no duration.
+func (c *collectorImplementation) endNode(event wf.Event, status wf.StateStatus) {
+	workflow := c.workflow(event.RunID)
+
+	if !event.StartedAt.IsZero() {
+		c.nodeDuration.WithLabelValues(workflow, event.NodeName, string(status)).Observe(event.Duration.Seconds())
+	}
+
+	if event.Attempts > 1 {
+		c.nodeRetries.WithLabelValues(workflow, event.NodeName).Add(float64(event.Attempts - 1))
+	}
+}
+
+// setWorkflow remembers the name of the workflow of a run in progress
+func (c *collectorImplementation) setWorkflow(runID string, name string) {
+	c.workflowsMu.Lock()
+	defer c.workflowsMu.Unlock()
+
+	c.workflows[runID] = name
+}
+
+// workflow returns the name of the workflow of a run in progress, empty if it is not known
+func (c *collectorImplementation) workflow(runID string) string {
+	c.workflowsMu.Lock()
+	defer c.workflowsMu.Unlock()
+
+	return c.workflows[runID]
+}
+
+// collectors returns the metrics of the collector
+func (c *collectorImplementation) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.runsStarted,
+		c.runsCompleted,
+		c.runsFailed,
+		c.runsPaused,
+		c.runDuration,
+		c.nodeDuration,
+		c.nodeRetries,
+	}
+}
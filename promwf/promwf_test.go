@@ -0,0 +1,114 @@
+package promwf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dracory/wf"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestDag(collector CollectorInterface, chargeErr error) wf.DagInterface {
+	attempts := 0
+	charge := wf.NewStep(
+		wf.WithName("Charge"),
+		wf.WithRetry(wf.RetryPolicy{MaxAttempts: 3}),
+		wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			attempts++
+			if chargeErr != nil {
+				return ctx, data, chargeErr
+			}
+			if attempts == 1 {
+				return ctx, data, errors.New("temporary failure")
+			}
+			return ctx, data, nil
+		}),
+	)
+	receipt := wf.NewStep(wf.WithName("Receipt"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		if _, ok := data["email"]; !ok {
+			return ctx, data, wf.ErrPaused
+		}
+		return ctx, data, nil
+	}))
+
+	payment := wf.NewPipeline(wf.WithName("Payment"), wf.WithRunnables(charge, receipt))
+
+	return wf.NewDag(
+		wf.WithName("Orders"),
+		wf.WithRunnables(payment),
+		wf.WithListener(collector.Listen),
+	)
+}
+
+func Test_Collector(t *testing.T) {
+	ctx := context.Background()
+	collector := NewCollector()
+	c := collector.(*collectorImplementation)
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// A completed run, with a retried step
+	if err := newTestDag(collector, nil).NewRun(ctx, map[string]any{"email": "user@example.com"}).Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// A failed run
+	if err := newTestDag(collector, errors.New("card declined")).NewRun(ctx, map[string]any{}).Execute(); err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+
+	// A paused run
+	if err := newTestDag(collector, nil).NewRun(ctx, map[string]any{}).Execute(); !errors.Is(err, wf.ErrPaused) {
+		t.Fatalf("Expected ErrPaused, got %v", err)
+	}
+
+	counters := []struct {
+		name     string
+		counter  prometheus.Collector
+		expected float64
+	}{
+		{"started", c.runsStarted.WithLabelValues("Orders"), 3},
+		{"completed", c.runsCompleted.WithLabelValues("Orders"), 1},
+		{"failed", c.runsFailed.WithLabelValues("Orders"), 1},
+		{"paused", c.runsPaused.WithLabelValues("Orders"), 1},
+		{"retries", c.nodeRetries.WithLabelValues("Orders", "Charge"), 1 + 2 + 1},
+	}
+	for _, counter := range counters {
+		if value := testutil.ToFloat64(counter.counter); value != counter.expected {
+			t.Errorf("Expected %v %s, got %v", counter.expected, counter.name, value)
+		}
+	}
+
+	// One series per workflow, node and status: 3 statuses of the pipeline,
+	// 2 of the charge step (complete and failed), and 2 of the receipt step
+	if count := testutil.CollectAndCount(c.nodeDuration); count != 7 {
+		t.Errorf("Expected 7 node duration series, got %d", count)
+	}
+
+	// The durations are labeled by the names of the workflow and of the nodes
+	durations := [][]string{
+		{"Orders", "Payment", "complete"},
+		{"Orders", "Payment", "failed"},
+		{"Orders", "Payment", "paused"},
+		{"Orders", "Charge", "complete"},
+		{"Orders", "Receipt", "paused"},
+	}
+	for _, labels := range durations {
+		histogram, err := c.nodeDuration.GetMetricWithLabelValues(labels...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := testutil.CollectAndCount(histogram.(prometheus.Histogram)); count != 1 {
+			t.Errorf("Expected the duration of %v to be collected", labels)
+		}
+	}
+
+	if len(c.workflows) != 0 {
+		t.Errorf("Expected the ended runs to be forgotten, got %v", c.workflows)
+	}
+}
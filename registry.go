@@ -0,0 +1,82 @@
+package wf
+
+import (
+	"slices"
+	"sync"
+)
+
+// HandlerRegistryInterface holds step handlers by name, so workflows can refer
+// to their handlers by name, e.g. in a Definition loaded from a file.
+type HandlerRegistryInterface interface {
+	// Register adds a handler under the given name, replacing any handler
+	// registered under the same name.
+	Register(name string, handler StepHandler)
+
+	// Get returns the handler registered under the given name,
+	// and false if there is none.
+	Get(name string) (StepHandler, bool)
+
+	// List returns the names of the registered handlers, sorted
+	List() []string
+}
+
+// DefaultHandlerRegistry is the registry the steps created with WithHandlerName
+// resolve their handlers from, when they are given no registry. Register the
+// handlers at startup (e.g. in an init function), so every process can rebuild
+// the workflows from their names.
+//
+// Example:
+//   func init() {
+//       wf.DefaultHandlerRegistry.Register("orders.validate", validateOrder)
+//   }
+var DefaultHandlerRegistry = NewHandlerRegistry()
+
+// HandlerRegistry is a HandlerRegistryInterface safe for concurrent use
+type HandlerRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]StepHandler
+}
+
+var _ HandlerRegistryInterface = (*HandlerRegistry)(nil)
+
+// NewHandlerRegistry creates an empty handler registry
+//
+// Example:
+//   registry := NewHandlerRegistry()
+//   registry.Register("orders.validate", validateOrder)
+//   registry.Register("orders.charge", chargeCard)
+func NewHandlerRegistry() HandlerRegistryInterface {
+	return &HandlerRegistry{
+		handlers: make(map[string]StepHandler),
+	}
+}
+
+// Register adds a handler under the given name
+func (r *HandlerRegistry) Register(name string, handler StepHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[name] = handler
+}
+
+// Get returns the handler registered under the given name
+func (r *HandlerRegistry) Get(name string) (StepHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.handlers[name]
+	return handler, ok
+}
+
+// List returns the names of the registered handlers, sorted
+func (r *HandlerRegistry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,141 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func Test_HandlerRegistry(t *testing.T) {
+	registry := NewHandlerRegistry()
+
+	if _, ok := registry.Get("orders.validate"); ok {
+		t.Error("Expected no handler in an empty registry")
+	}
+
+	registry.Register("orders.validate", func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["validated"] = true
+		return ctx, data, nil
+	})
+
+	handler, ok := registry.Get("orders.validate")
+	if !ok {
+		t.Fatal("Expected the registered handler")
+	}
+
+	_, data, err := handler(context.Background(), map[string]any{})
+	if err != nil || data["validated"] != true {
+		t.Errorf("Expected the registered handler to run, got %v, %v", data, err)
+	}
+}
+
+func Test_HandlerRegistry_List(t *testing.T) {
+	registry := NewHandlerRegistry()
+	handler := func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}
+
+	registry.Register("orders.ship", handler)
+	registry.Register("orders.charge", handler)
+	registry.Register("orders.charge", handler)
+
+	if names := registry.List(); !slices.Equal(names, []string{"orders.charge", "orders.ship"}) {
+		t.Errorf("Expected the sorted names, got %v", names)
+	}
+}
+
+func Test_WithHandlerName(t *testing.T) {
+	registry := NewHandlerRegistry()
+	step := NewStep(WithID("charge"), WithHandlerName("orders.charge", registry), WithCompensationName("orders.refund", registry))
+
+	// The handler is not registered yet
+	if _, _, err := step.Run(context.Background(), map[string]any{}); err == nil || err.Error() != `handler "orders.charge" is not registered` {
+		t.Fatalf("Expected the handler not to be registered, got %v", err)
+	}
+
+	registry.Register("orders.charge", func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["charged"] = true
+		return ctx, data, nil
+	})
+	registry.Register("orders.refund", func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["refunded"] = true
+		return ctx, data, nil
+	})
+
+	if _, data, err := step.Run(context.Background(), map[string]any{}); err != nil || data["charged"] != true {
+		t.Fatalf("Expected the registered handler to run, got %v, %v", data, err)
+	}
+	if _, data, _ := step.Compensate(context.Background(), map[string]any{}); data["refunded"] != true {
+		t.Errorf("Expected the registered compensation to run, got %v", data)
+	}
+
+	// The names are exported
+	definition, err := ExportDefinition(step)
+	if err != nil {
+		t.Fatalf("ExportDefinition failed: %v", err)
+	}
+	if definition.Handler != "orders.charge" || definition.Compensation != "orders.refund" {
+		t.Errorf("Expected the handler names to be exported, got %q and %q", definition.Handler, definition.Compensation)
+	}
+}
+
+// The workflow and its paused run are rebuilt from their serialized forms,
+// as in a new process, without access to the original closures
+func Test_WithHandlerName_ResumeInNewProcess(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore()
+
+	registry := NewHandlerRegistry()
+	registry.Register("approval.request", func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["requested"] = true
+		return ctx, data, nil
+	})
+	registry.Register("approval.approve", func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		if data["approved"] != true {
+			return ctx, data, ErrPaused
+		}
+		return ctx, data, nil
+	})
+
+	request := NewStep(WithID("request"), WithHandlerName("approval.request", registry))
+	approve := NewStep(WithID("approve"), WithHandlerName("approval.approve", registry))
+	dag := NewDag(WithID("approval"), WithRunnables(request, approve), WithDependency(approve, request), WithStateStore(store))
+
+	run := dag.NewRun(ctx, map[string]any{})
+	run.SetID("run-1")
+	if err := run.Execute(); !errors.Is(err, ErrPaused) {
+		t.Fatalf("Expected the run to pause, got %v", err)
+	}
+
+	definition, err := ExportDefinition(dag)
+	if err != nil {
+		t.Fatalf("ExportDefinition failed: %v", err)
+	}
+	jsonData, err := definition.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+
+	// In the new process
+	loaded := &Definition{}
+	if err := loaded.FromJSON(jsonData); err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	rebuilt, err := LoadDag(loaded, registry)
+	if err != nil {
+		t.Fatalf("LoadDag failed: %v", err)
+	}
+	rebuilt.SetStateStore(store)
+
+	resumed, err := rebuilt.ResumeRun(ctx, "run-1", map[string]any{"approved": true})
+	if err != nil {
+		t.Fatalf("ResumeRun failed: %v", err)
+	}
+	if err := resumed.Execute(); err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+	if !resumed.IsCompleted() || resumed.GetData()["requested"] != true {
+		t.Errorf("Expected the resumed run to complete with the data of the first run, got %s %v", resumed.GetState().GetStatus(), resumed.GetData())
+	}
+}
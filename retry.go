@@ -0,0 +1,98 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy configures how a step retries its handler after a failure.
+// Between attempts the step waits with an exponential backoff:
+// InitialBackoff, InitialBackoff*Multiplier, InitialBackoff*Multiplier^2, ...
+// capped at MaxBackoff, and randomized by Jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values less than 1 are treated as 1 (no retry).
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the second attempt
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the wait after each attempt.
+	// Values less than 1 are treated as 1 (constant backoff).
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to this fraction of it,
+	// e.g. 0.2 waits between 80% and 120% of the computed backoff.
+	// Values are clamped between 0 and 1.
+	Jitter float64
+
+	// Retryable decides whether an error should be retried.
+	// When nil, every error is retried.
+	Retryable func(err error) bool
+}
+
+// attempts returns the total number of attempts allowed by the policy
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// isRetryable checks whether the error should be retried.
+// Skipping or pausing a step is never retried.
+func (p *RetryPolicy) isRetryable(err error) bool {
+	if errors.Is(err, ErrSkip) || errors.Is(err, ErrPaused) {
+		return false
+	}
+	if p == nil || p.Retryable == nil {
+		return true
+	}
+	return p.Retryable(err)
+}
+
+// backoff returns the wait after the given failed attempt (starting at 1)
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if p == nil || p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	multiplier := max(p.Multiplier, 1)
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+
+	jitter := min(max(p.Jitter, 0), 1)
+	if jitter > 0 {
+		backoff *= 1 + jitter*(2*rand.Float64()-1)
+	}
+
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+
+	// Without MaxBackoff, the multiplied backoff can overflow a duration
+	if backoff >= float64(math.MaxInt64) {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(backoff)
+}
+
+// waitBackoff waits for the backoff of the given failed attempt.
+// It returns early with the cause, if the context is done in the meantime.
+func (p *RetryPolicy) waitBackoff(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
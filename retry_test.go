@@ -0,0 +1,189 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_RetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		300 * time.Millisecond, // capped
+		300 * time.Millisecond, // capped
+	}
+
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("Attempt %d: expected backoff %v, got %v", i+1, want, got)
+		}
+	}
+
+	// Multiplier less than 1 keeps a constant backoff
+	constant := &RetryPolicy{InitialBackoff: 50 * time.Millisecond}
+	if got := constant.backoff(3); got != 50*time.Millisecond {
+		t.Errorf("Expected constant backoff of 50ms, got %v", got)
+	}
+
+	// A nil policy does not wait and allows a single attempt
+	var none *RetryPolicy
+	if got := none.backoff(1); got != 0 {
+		t.Errorf("Expected no backoff for nil policy, got %v", got)
+	}
+	if got := none.attempts(); got != 1 {
+		t.Errorf("Expected 1 attempt for nil policy, got %d", got)
+	}
+
+	// Without MaxBackoff, the backoff of a late attempt does not overflow
+	unbounded := &RetryPolicy{InitialBackoff: time.Second, Multiplier: 10}
+	if got := unbounded.backoff(100); got != time.Duration(math.MaxInt64) {
+		t.Errorf("Expected the longest backoff, got %v", got)
+	}
+}
+
+func Test_RetryPolicy_Jitter(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		Jitter:         0.5,
+	}
+
+	for range 100 {
+		got := policy.backoff(1)
+		if got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("Expected backoff between 50ms and 150ms, got %v", got)
+		}
+	}
+}
+
+func Test_Step_Retry_Succeeds(t *testing.T) {
+	calls := 0
+	step := NewStep(
+		WithName("Flaky"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			calls++
+			if calls < 3 {
+				return ctx, data, errors.New("503 service unavailable")
+			}
+			data["fetched"] = true
+			return ctx, data, nil
+		}),
+		WithRetry(RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: time.Millisecond,
+			Multiplier:     2,
+		}),
+	)
+
+	_, data, err := step.Run(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("Expected step to succeed after retries, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+	if data["fetched"] != true {
+		t.Error("Expected data from the successful attempt")
+	}
+	if !step.IsCompleted() {
+		t.Errorf("Expected step to be completed, got %s", step.GetState().GetStatus())
+	}
+	if attempts := step.GetState().(AttemptsState).GetAttempts(); attempts != 3 {
+		t.Errorf("Expected 3 attempts in state, got %d", attempts)
+	}
+	if lastError := step.GetState().(AttemptsState).GetLastError(); lastError != "503 service unavailable" {
+		t.Errorf("Expected last error to be recorded, got %q", lastError)
+	}
+}
+
+func Test_Step_Retry_Exhausted(t *testing.T) {
+	calls := 0
+	step := NewStep(
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			calls++
+			return ctx, data, errors.New("still failing")
+		}),
+		WithRetry(RetryPolicy{MaxAttempts: 3}),
+	)
+
+	_, _, err := step.Run(context.Background(), map[string]any{})
+	if err == nil || err.Error() != "still failing" {
+		t.Fatalf("Expected last handler error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+	if !step.IsFailed() {
+		t.Errorf("Expected step to be failed, got %s", step.GetState().GetStatus())
+	}
+	if attempts := step.GetState().(AttemptsState).GetAttempts(); attempts != 3 {
+		t.Errorf("Expected 3 attempts in state, got %d", attempts)
+	}
+}
+
+func Test_Step_Retry_NotRetryable(t *testing.T) {
+	errPermanent := errors.New("400 bad request")
+	calls := 0
+	step := NewStep(
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			calls++
+			return ctx, data, errPermanent
+		}),
+		WithRetry(RetryPolicy{
+			MaxAttempts: 5,
+			Retryable: func(err error) bool {
+				return !errors.Is(err, errPermanent)
+			},
+		}),
+	)
+
+	_, _, err := step.Run(context.Background(), map[string]any{})
+	if !errors.Is(err, errPermanent) {
+		t.Fatalf("Expected permanent error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single call for a non retryable error, got %d", calls)
+	}
+}
+
+func Test_Step_Retry_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	step := NewStep(
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			calls++
+			cancel()
+			return ctx, data, errors.New("unavailable")
+		}),
+		WithRetry(RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: time.Hour,
+		}),
+	)
+
+	start := time.Now()
+	_, _, err := step.Run(ctx, map[string]any{})
+	if time.Since(start) > time.Second {
+		t.Fatal("Expected the backoff to be interrupted by the context")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context canceled error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single call, got %d", calls)
+	}
+	if !step.IsFailed() {
+		t.Errorf("Expected step to be failed, got %s", step.GetState().GetStatus())
+	}
+}
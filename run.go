@@ -0,0 +1,537 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dracory/uid"
+)
+
+// RunInterface represents a single run of a Step, Pipeline or Dag.
+//
+// A run has its own ID, its own state and its own result. The definition it
+// was created from is not modified, so the same definition can be built once
+// and run many times, even at the same time from different goroutines.
+//
+// Example:
+//   run := dag.NewRun(ctx, data)
+//   if err := run.Execute(); err != nil {
+//       return err
+//   }
+//   result := run.GetData()
+type RunInterface interface {
+	// GetID returns the ID of the run
+	GetID() string
+
+	// SetID sets the ID of the run
+	SetID(id string)
+
+	// GetRunnable returns the Step, Pipeline or Dag the run executes
+	GetRunnable() RunnableInterface
+
+	// Execute runs the workflow, or resumes it if the run is paused.
+	// It blocks until the run is finished, and returns the error of the run.
+	Execute() error
+
+	// Pause pauses the run. It can be called while Execute is running:
+	// no new nodes are started, and Execute returns ErrPaused after the
+	// running nodes finish. The run can then be resumed with ResumeRun.
+	Pause() error
+
+	// GetContext returns the context returned by the workflow
+	GetContext() context.Context
+
+	// GetData returns the data returned by the workflow
+	GetData() map[string]any
+
+	// GetError returns the error returned by the workflow, nil if it did not fail
+	GetError() error
+
+	// GetState returns the state of the run. The states of the nodes
+	// are available with GetChildState, using the node IDs.
+	GetState() StateInterface
+
+	// SetState sets the state of the run, e.g. to resume a saved run
+	SetState(state StateInterface)
+
+	// State helper methods
+	IsRunning() bool
+	IsPaused() bool
+	IsCompleted() bool
+	IsFailed() bool
+	IsSkipped() bool
+}
+
+// runner is implemented by the Step, Pipeline and Dag of this package.
+// It executes the runnable with the given state, instead of the state of the
+// definition, which is what allows running the same definition many times.
+type runner interface {
+	RunnableInterface
+
+	// runWithState runs the runnable with the given state. A paused state is resumed.
+	runWithState(ctx context.Context, data map[string]any, state StateInterface, ex *execution) (context.Context, map[string]any, error)
+
+	// compensateWithState compensates the runnable, if its state is completed
+	compensateWithState(ctx context.Context, data map[string]any, state StateInterface) (context.Context, map[string]any, []CompensationResult)
+
+	// GetStateStore returns the store the run is saved to, nil if there is none
+	GetStateStore() StateStore
+
+	// GetListeners returns the listeners of the events of the runnable and its nodes
+	GetListeners() []EventListener
+
+	// GetMiddlewares returns the middlewares wrapping the runnable and its nodes
+	GetMiddlewares() []Middleware
+
+	// GetLogger returns the logger of the runnable and its nodes, nil if there is none
+	GetLogger() *slog.Logger
+}
+
+// execution holds the settings shared by all the nodes of a run
+type execution struct {
+	// id of the run
+	runID string
+
+	// state of the root of the run
+	root StateInterface
+
+	// store the root state is saved to, nil if the run is not saved
+	store StateStore
+
+	// bindStates also sets the state of every node on its definition,
+	// as done by Run. Runs created with NewRun leave the definitions untouched.
+	bindStates bool
+
+	// path holds the IDs of the nodes from the root of the run down to the
+	// Pipeline or Dag running with this execution (see nested)
+	path []string
+
+	// listeners of the root and of the nested nodes down to the end of the path
+	listeners []EventListener
+
+	// middlewares of the root and of the nested nodes down to the end of the path
+	middlewares []Middleware
+
+	// logger of the nearest node on the path with a logger, nil if there is none
+	logger *slog.Logger
+
+	// checkpointMu makes the checkpoints of nodes running in parallel save one after another
+	checkpointMu *sync.Mutex
+
+	// emitMu makes the listeners receive the events of nodes running in parallel one after another
+	emitMu *sync.Mutex
+}
+
+// newExecution creates the execution of a run of the given runnable, with the given root state
+func newExecution(runID string, runnable runner, root StateInterface) *execution {
+	return &execution{
+		runID:        runID,
+		root:         root,
+		store:        runnable.GetStateStore(),
+		path:         []string{runnable.GetID()},
+		listeners:    runnable.GetListeners(),
+		middlewares:  runnable.GetMiddlewares(),
+		logger:       runnable.GetLogger(),
+		checkpointMu: &sync.Mutex{},
+		emitMu:       &sync.Mutex{},
+	}
+}
+
+// run runs the root of the run, and saves its final state
+func (ex *execution) run(ctx context.Context, data map[string]any, runnable runner) (context.Context, map[string]any, error) {
+	startedAt := time.Now()
+	if ex.root.GetStatus() == StateStatusPaused {
+		ex.emit(EventResumed, runnable, nil, startedAt, nil)
+	} else {
+		ex.emit(EventWorkflowStarted, runnable, nil, startedAt, nil)
+	}
+
+	startState(ex.root, startedAt)
+
+	run := ex.withLogger(runnable, ex.wrap(runnable, ex.root, func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return runnable.runWithState(ctx, data, ex.root, ex)
+	}))
+
+	ctx, data, err := run(ctx, data)
+	endState(ex.root, time.Now())
+
+	// A failed checkpoint of a node already stopped the run with the same error
+	var failedCheckpoint *checkpointError
+	if checkpointErr := ex.checkpoint(ctx); checkpointErr != nil && !errors.As(err, &failedCheckpoint) {
+		err = errors.Join(err, checkpointErr)
+	}
+
+	ex.emit(workflowEventType(err), runnable, ex.root, startedAt, err)
+	return ctx, data, err
+}
+
+// nested returns the execution of the nodes of the given node: the node is added
+// to the path, its listeners and middlewares are added to the ones of its parents,
+// and its logger, if it has one, replaces the one of its parents.
+func (ex *execution) nested(node RunnableInterface) *execution {
+	nested := *ex
+	nested.path = append(slices.Clone(ex.path), node.GetID())
+
+	if l, ok := node.(listenable); ok {
+		nested.listeners = slices.Concat(ex.listeners, l.GetListeners())
+	}
+
+	if m, ok := node.(middlewareHolder); ok {
+		nested.middlewares = slices.Concat(ex.middlewares, m.GetMiddlewares())
+	}
+
+	if l, ok := node.(loggerHolder); ok && l.GetLogger() != nil {
+		nested.logger = l.GetLogger()
+	}
+
+	return &nested
+}
+
+// checkpoint saves the root state of the run, with the states of all its nodes, to the store.
+// It is called after every completed node, at any level of nesting.
+func (ex *execution) checkpoint(ctx context.Context) error {
+	if ex.store == nil {
+		return nil
+	}
+
+	ex.checkpointMu.Lock()
+	defer ex.checkpointMu.Unlock()
+
+	if err := ex.store.Save(context.WithoutCancel(ctx), ex.runID, ex.root); err != nil {
+		return &checkpointError{err: err}
+	}
+
+	return nil
+}
+
+// checkpointError is returned when a checkpoint cannot be saved
+type checkpointError struct {
+	err error
+}
+
+func (e *checkpointError) Error() string {
+	return "checkpoint failed: " + e.err.Error()
+}
+
+func (e *checkpointError) Unwrap() error {
+	return e.err
+}
+
+// replaceSaved makes a new root state replace the state saved with the run ID,
+// by giving it the version of the saved state. Definitions use their ID as the
+// run ID, so every Run of a definition saves over the previous one.
+func (ex *execution) replaceSaved(ctx context.Context) error {
+	versioned, ok := ex.root.(VersionedState)
+	if ex.store == nil || !ok || versioned.GetVersion() != 0 {
+		return nil
+	}
+
+	saved, err := ex.store.Load(ctx, ex.runID)
+	if errors.Is(err, ErrStateNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if savedVersioned, ok := saved.(VersionedState); ok {
+		versioned.SetVersion(savedVersioned.GetVersion())
+	}
+
+	return nil
+}
+
+// pauseRequested checks whether the run, or the Pipeline or Dag with the given state, was paused
+func (ex *execution) pauseRequested(state StateInterface) bool {
+	return ex.root.GetStatus() == StateStatusPaused || state.GetStatus() == StateStatusPaused
+}
+
+// runDefinition runs a definition with its own state, as done by its Run and
+// Resume methods. The ID of the definition is used as the run ID, so a new
+// state replaces the one saved by the previous run of the definition.
+func runDefinition(ctx context.Context, data map[string]any, runnable runner, state StateInterface) (context.Context, map[string]any, error) {
+	ex := newExecution(runnable.GetID(), runnable, state)
+	ex.bindStates = true
+	if err := ex.replaceSaved(ctx); err != nil {
+		return ctx, data, err
+	}
+	return ex.run(ctx, data, runnable)
+}
+
+// resumeRun creates a run from the state saved in the store of the runnable.
+// A run that was interrupted while running is resumed like a paused run,
+// including its nested Pipelines and Dags.
+// It fails with ErrStateConflict, when the store detects that the run was resumed by someone else.
+func resumeRun(ctx context.Context, runnable runner, runID string, data map[string]any) (RunInterface, error) {
+	store := runnable.GetStateStore()
+	if store == nil {
+		return nil, errors.New("no state store")
+	}
+
+	state, err := store.Load(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch state.GetStatus() {
+	case StateStatusComplete, StateStatusFailed, StateStatusSkipped:
+		return nil, fmt.Errorf("run %s is already finished", runID)
+	}
+
+	// Save the state right away, so with a store using optimistic
+	// locking only one process can resume the run
+	if err := store.Save(ctx, runID, state); err != nil {
+		return nil, err
+	}
+	pauseInterrupted(state)
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+
+	run := newRun(runnable, ctx, data)
+	run.SetID(runID)
+	run.SetState(state)
+
+	return run, nil
+}
+
+type runImplementation struct {
+	id       string
+	runnable runner
+	ctx      context.Context
+	data     map[string]any
+	err      error
+	state    StateInterface
+
+	// mu makes Execute calls on the same run wait for each other
+	mu sync.Mutex
+}
+
+var _ RunInterface = (*runImplementation)(nil)
+
+// newRun creates a run of the given runnable
+func newRun(runnable runner, ctx context.Context, data map[string]any) RunInterface {
+	return &runImplementation{
+		id:       uid.HumanUid(),
+		runnable: runnable,
+		ctx:      ctx,
+		data:     data,
+		state:    NewState(),
+	}
+}
+
+func (r *runImplementation) GetID() string {
+	return r.id
+}
+
+func (r *runImplementation) SetID(id string) {
+	r.id = id
+}
+
+func (r *runImplementation) GetRunnable() RunnableInterface {
+	return r.runnable
+}
+
+// Execute runs the workflow, or resumes it if the run is paused
+func (r *runImplementation) Execute() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state.GetStatus() {
+	case StateStatusComplete, StateStatusFailed, StateStatusSkipped:
+		return errors.New("run is already finished")
+	}
+
+	ex := newExecution(r.id, r.runnable, r.state)
+	r.ctx, r.data, r.err = ex.run(r.ctx, r.data, r.runnable)
+	return r.err
+}
+
+// Pause pauses the run
+func (r *runImplementation) Pause() error {
+	return pause(r.state)
+}
+
+func (r *runImplementation) GetContext() context.Context {
+	return r.ctx
+}
+
+func (r *runImplementation) GetData() map[string]any {
+	return r.data
+}
+
+func (r *runImplementation) GetError() error {
+	return r.err
+}
+
+// GetState returns the state of the run
+func (r *runImplementation) GetState() StateInterface {
+	return r.state
+}
+
+// SetState sets the state of the run
+func (r *runImplementation) SetState(state StateInterface) {
+	r.state = state
+}
+
+// State helper methods
+func (r *runImplementation) IsRunning() bool {
+	return r.state.GetStatus() == StateStatusRunning
+}
+
+func (r *runImplementation) IsPaused() bool {
+	return r.state.GetStatus() == StateStatusPaused
+}
+
+func (r *runImplementation) IsCompleted() bool {
+	return r.state.GetStatus() == StateStatusComplete
+}
+
+func (r *runImplementation) IsFailed() bool {
+	return r.state.GetStatus() == StateStatusFailed
+}
+
+func (r *runImplementation) IsSkipped() bool {
+	return r.state.GetStatus() == StateStatusSkipped
+}
+
+// pauseInterrupted sets the states left running by an interrupted run, and
+// the ones of its nodes, to paused, so they are resumed instead of restarted
+func pauseInterrupted(state StateInterface) {
+	if state.GetStatus() == StateStatusRunning {
+		state.SetStatus(StateStatusPaused)
+	}
+	for _, child := range stateChildren(state) {
+		pauseInterrupted(child)
+	}
+}
+
+// RetryFailed prepares the state of a failed run to be resumed with ResumeRun.
+// The run is paused again, with the Pipelines and Dags containing the failed
+// nodes, so the failed nodes are run again, with the nodes that did not run
+// because of them. The completed nodes are not run again, and the
+// compensations that already ran are not undone.
+//
+// Example:
+//   state, err := store.Load(ctx, runID)
+//   ...
+//   if err := wf.RetryFailed(state); err != nil {
+//       return err
+//   }
+//   if err := store.Save(ctx, runID, state); err != nil {
+//       return err
+//   }
+//   run, err := dag.ResumeRun(ctx, runID, nil)
+func RetryFailed(state StateInterface) error {
+	if state.GetStatus() != StateStatusFailed {
+		return fmt.Errorf("run is %s, not failed", state.GetStatus())
+	}
+
+	if _, ok := state.(interface{ retry() }); !ok {
+		return errors.New("state does not support retries")
+	}
+
+	retryFailed(state, true)
+	return nil
+}
+
+// retryFailed pauses the failed state, and the failed states of its nodes that
+// have nodes of their own. The failed states of the steps are left failed,
+// so the steps start again with a new state.
+func retryFailed(state StateInterface, root bool) {
+	children := stateChildren(state)
+	if !root && len(children) == 0 {
+		return
+	}
+
+	if retryable, ok := state.(interface{ retry() }); ok {
+		retryable.retry()
+	}
+
+	for _, child := range children {
+		if child.GetStatus() == StateStatusFailed {
+			retryFailed(child, false)
+		}
+	}
+}
+
+// nodeState returns the state a node of a Pipeline or Dag runs with: its
+// paused state when the run is resumed, a new state otherwise. The state is
+// added to the state of the parent, and set on the node for a definition's Run.
+//
+// Nodes implemented outside this package manage their own state, and nil is returned.
+func nodeState(parent StateInterface, node RunnableInterface, ex *execution) StateInterface {
+	stateful, ok := node.(interface {
+		runner
+		GetState() StateInterface
+		SetState(state StateInterface)
+	})
+	if !ok {
+		return nil
+	}
+
+	state := stateChild(parent, node.GetID())
+	if state == nil && ex.bindStates {
+		state = stateful.GetState()
+	}
+
+	if state == nil || state.GetStatus() != StateStatusPaused {
+		state = NewState()
+	}
+
+	setStateChild(parent, node.GetID(), state)
+	if ex.bindStates {
+		stateful.SetState(state)
+	}
+
+	return state
+}
+
+// runNode runs a node of a Pipeline or Dag with the state returned by nodeState.
+// It also returns whether the node skipped itself. The error of a failed node is a StepError.
+func runNode(ctx context.Context, data map[string]any, node RunnableInterface, state StateInterface, ex *execution) (context.Context, map[string]any, bool, error) {
+	ex = ex.nested(node)
+
+	startedAt := time.Now()
+	ex.emit(EventNodeStarted, node, nil, startedAt, nil)
+
+	r, ok := node.(runner)
+	if !ok {
+		state = nil
+	}
+
+	// A resumed node keeps the time it first started
+	if state != nil {
+		startState(state, startedAt)
+	}
+
+	run := ex.withLogger(node, ex.wrap(node, state, func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		if state == nil {
+			return node.Run(ctx, data)
+		}
+		return r.runWithState(ctx, data, state, ex)
+	}))
+
+	ctx, data, err := run(ctx, data)
+	if state != nil {
+		endState(state, time.Now())
+	}
+	err = stepError(err, node, state, ex.path)
+
+	var skipped bool
+	if state == nil {
+		skippable, ok := node.(interface{ IsSkipped() bool })
+		skipped = err == nil && ok && skippable.IsSkipped()
+	} else {
+		skipped = err == nil && state.GetStatus() == StateStatusSkipped
+	}
+
+	ex.emit(nodeEventType(err, skipped), node, state, startedAt, err)
+	return ctx, data, skipped, err
+}
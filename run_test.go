@@ -0,0 +1,287 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_Dag_NewRun_Concurrent(t *testing.T) {
+	double := NewStep(WithID("double"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["double"] = data["n"].(int) * 2
+		return ctx, data, nil
+	}))
+	square := NewStep(WithID("square"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["square"] = data["n"].(int) * data["n"].(int)
+		return ctx, data, nil
+	}))
+	sum := NewStep(WithID("sum"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["sum"] = data["double"].(int) + data["square"].(int)
+		return ctx, data, nil
+	}))
+
+	dag := NewDag(
+		WithRunnables(double, square, sum),
+		WithDependency(sum, double, square),
+		WithMaxConcurrency(2),
+	)
+
+	const runs = 20
+	var wg sync.WaitGroup
+	results := make([]RunInterface, runs)
+	for i := range runs {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			run := dag.NewRun(context.Background(), map[string]any{"n": n})
+			if err := run.Execute(); err != nil {
+				t.Errorf("Run %d failed: %v", n, err)
+			}
+			results[n] = run
+		}(i)
+	}
+	wg.Wait()
+
+	ids := map[string]bool{}
+	for n, run := range results {
+		if run.GetData()["sum"] != n*2+n*n {
+			t.Errorf("Run %d: expected sum %d, got %v", n, n*2+n*n, run.GetData()["sum"])
+		}
+		if !run.IsCompleted() {
+			t.Errorf("Run %d: expected completed, got %s", n, run.GetState().GetStatus())
+		}
+		if len(run.GetState().GetCompletedSteps()) != 3 {
+			t.Errorf("Run %d: expected 3 completed steps, got %v", n, run.GetState().GetCompletedSteps())
+		}
+		if state := run.GetState().(ParentState).GetChildState("sum"); state == nil || state.GetStatus() != StateStatusComplete {
+			t.Errorf("Run %d: expected the state of the sum step to be completed", n)
+		}
+		ids[run.GetID()] = true
+	}
+
+	if len(ids) != runs {
+		t.Errorf("Expected %d distinct run IDs, got %d", runs, len(ids))
+	}
+
+	// The definitions are not modified by the runs
+	if len(dag.GetState().GetCompletedSteps()) != 0 {
+		t.Errorf("Expected the DAG state to be untouched, got %v", dag.GetState().GetCompletedSteps())
+	}
+	if sum.IsCompleted() {
+		t.Error("Expected the step state to be untouched")
+	}
+}
+
+func Test_Pipeline_NewRun_Nested(t *testing.T) {
+	step1 := NewStep(WithID("step1"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		data["step1"] = true
+		return ctx, data, nil
+	}))
+	step2 := NewStep(WithID("step2"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, ErrSkip
+	}))
+
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(step1, step2))
+	dag := NewDag(WithRunnables(pipeline))
+
+	run := dag.NewRun(context.Background(), map[string]any{})
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if run.GetData()["step1"] != true {
+		t.Error("Expected step1 to set its data")
+	}
+
+	pipelineState := run.GetState().(ParentState).GetChildState("pipeline")
+	if pipelineState == nil || pipelineState.GetStatus() != StateStatusComplete {
+		t.Fatal("Expected the pipeline state to be completed")
+	}
+	if state := pipelineState.(ParentState).GetChildState("step1"); state == nil || state.GetStatus() != StateStatusComplete {
+		t.Error("Expected the state of step1 to be completed")
+	}
+	if state := pipelineState.(ParentState).GetChildState("step2"); state == nil || state.GetStatus() != StateStatusSkipped {
+		t.Error("Expected the state of step2 to be skipped")
+	}
+
+	if step1.IsCompleted() || step2.IsSkipped() || pipeline.IsCompleted() {
+		t.Error("Expected the definitions to be untouched")
+	}
+}
+
+func Test_Step_NewRun(t *testing.T) {
+	calls := 0
+	step := NewStep(WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		calls++
+		return ctx, data, nil
+	}))
+
+	run := step.NewRun(context.Background(), map[string]any{})
+	if run.GetRunnable() != step {
+		t.Error("Expected the run to reference the step")
+	}
+
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !run.IsCompleted() {
+		t.Errorf("Expected the run to be completed, got %s", run.GetState().GetStatus())
+	}
+
+	// A finished run is not executed again
+	if err := run.Execute(); err == nil {
+		t.Error("Expected an error when executing a finished run")
+	}
+	if calls != 1 {
+		t.Errorf("Expected the handler to be called once, got %d", calls)
+	}
+}
+
+func Test_Run_Resume(t *testing.T) {
+	var calls atomic.Int32
+	newStep := func(id string) StepInterface {
+		return NewStep(WithID(id), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			calls.Add(1)
+			data[id] = true
+			return ctx, data, nil
+		}))
+	}
+
+	pipeline := NewPipeline(WithRunnables(newStep("step1"), newStep("step2")))
+
+	// A paused run, where step1 is already completed
+	state := NewState()
+	state.AddCompletedStep("step1")
+	state.SetWorkflowData(map[string]any{"step1": true})
+	state.SetStatus(StateStatusPaused)
+
+	run := pipeline.NewRun(context.Background(), map[string]any{})
+	run.SetState(state)
+
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected only step2 to run, got %d calls", calls.Load())
+	}
+	if run.GetData()["step1"] != true || run.GetData()["step2"] != true {
+		t.Errorf("Expected the data of both steps, got %v", run.GetData())
+	}
+}
+
+func Test_Run_Compensation(t *testing.T) {
+	compensated := map[string]int{}
+	var mu sync.Mutex
+
+	newStep := func(id string, fail bool) StepInterface {
+		return NewStep(
+			WithID(id),
+			WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				if fail && data["fail"] == true {
+					return ctx, data, fmt.Errorf("%s failed", id)
+				}
+				return ctx, data, nil
+			}),
+			WithCompensation(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				compensated[id]++
+				return ctx, data, nil
+			}),
+		)
+	}
+
+	pipeline := NewPipeline(WithRunnables(newStep("step1", false), newStep("step2", true)))
+
+	ok := pipeline.NewRun(context.Background(), map[string]any{})
+	failing := pipeline.NewRun(context.Background(), map[string]any{"fail": true})
+
+	if err := ok.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	err := failing.Execute()
+	var compensationErr *CompensationError
+	if !errors.As(err, &compensationErr) {
+		t.Fatalf("Expected a compensation error, got %v", err)
+	}
+
+	// Only the completed step of the failing run is compensated
+	if compensated["step1"] != 1 || compensated["step2"] != 0 {
+		t.Errorf("Expected only step1 to be compensated once, got %v", compensated)
+	}
+	if !failing.IsFailed() || !ok.IsCompleted() {
+		t.Error("Expected the runs to keep their own status")
+	}
+}
+
+func Test_RetryFailed(t *testing.T) {
+	ctx := context.Background()
+	calls := map[string]int{}
+	var mu sync.Mutex
+	newStep := func(id string, failures int) StepInterface {
+		return NewStep(WithID(id), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[id]++
+			if calls[id] <= failures {
+				return ctx, data, errors.New("temporary failure")
+			}
+			data[id] = true
+			return ctx, data, nil
+		}))
+	}
+
+	validate := newStep("validate", 0)
+	payment := NewPipeline(WithID("payment"), WithRunnables(newStep("charge", 0), newStep("receipt", 1)))
+	ship := newStep("ship", 0)
+
+	store := NewMemoryStateStore()
+	dag := NewDag(WithRunnables(validate, payment, ship), WithDependency(payment, validate), WithDependency(ship, payment), WithStateStore(store))
+
+	run := dag.NewRun(ctx, map[string]any{})
+	if err := run.Execute(); err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+
+	state, err := store.Load(ctx, run.GetID())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := RetryFailed(state); err != nil {
+		t.Fatalf("RetryFailed failed: %v", err)
+	}
+	if state.GetStatus() != StateStatusPaused || state.(AttemptsState).GetLastError() != "" || state.(ParentState).GetChildState("payment").GetStatus() != StateStatusPaused {
+		t.Errorf("Expected the run and the payment to be paused, got %s and %s", state.GetStatus(), state.(ParentState).GetChildState("payment").GetStatus())
+	}
+	if err := store.Save(ctx, run.GetID(), state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	resumed, err := dag.ResumeRun(ctx, run.GetID(), nil)
+	if err != nil {
+		t.Fatalf("ResumeRun failed: %v", err)
+	}
+	if err := resumed.Execute(); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+
+	// Only the failed step, and the step waiting for it, ran again
+	expected := map[string]int{"validate": 1, "charge": 1, "receipt": 2, "ship": 1}
+	for id, count := range expected {
+		if calls[id] != count {
+			t.Errorf("Expected %s to run %d times, got %d", id, count, calls[id])
+		}
+	}
+	if !resumed.IsCompleted() || resumed.GetData()["receipt"] != true {
+		t.Errorf("Expected the retried run to complete with all the data, got %v", resumed.GetData())
+	}
+
+	if err := RetryFailed(resumed.GetState()); err == nil {
+		t.Error("Expected an error retrying a completed run")
+	}
+}
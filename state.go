@@ -2,11 +2,16 @@ package wf
 
 import (
 	"encoding/json"
+	"maps"
 	"slices"
+	"sync"
 	"time"
 )
 
-// StateInterface defines the interface for workflow state management
+// StateInterface defines the interface for workflow state management.
+// The features added since are on optional interfaces, like SkippedStepsState,
+// so the existing implementations keep working: the workflows use them when
+// the state implements them, as State does.
 type StateInterface interface {
 	GetStatus() StateStatus
 	SetStatus(status StateStatus)
@@ -30,13 +35,234 @@ type StateInterface interface {
 	SetLastUpdated(t time.Time)
 }
 
+// SkippedStepsState is implemented by the states recording the skipped nodes of a Pipeline or Dag
+type SkippedStepsState interface {
+	GetSkippedSteps() []string
+	AddSkippedStep(id string)
+}
+
+// skippedSteps returns the skipped nodes recorded in the state, nil if it does not record them
+func skippedSteps(state StateInterface) []string {
+	if s, ok := state.(SkippedStepsState); ok {
+		return s.GetSkippedSteps()
+	}
+	return nil
+}
+
+// addSkippedStep records a skipped node in the state, if it records them
+func addSkippedStep(state StateInterface, id string) {
+	if s, ok := state.(SkippedStepsState); ok {
+		s.AddSkippedStep(id)
+	}
+}
+
+// FailedStepsState is implemented by the states recording the failed nodes of a
+// Dag, which may run other nodes after a failure
+type FailedStepsState interface {
+	GetFailedSteps() []string
+	AddFailedStep(id string)
+	SetFailedSteps(ids []string)
+}
+
+// failedSteps returns the failed nodes recorded in the state, nil if it does not record them
+func failedSteps(state StateInterface) []string {
+	if s, ok := state.(FailedStepsState); ok {
+		return s.GetFailedSteps()
+	}
+	return nil
+}
+
+// addFailedStep records a failed node in the state, if it records them
+func addFailedStep(state StateInterface, id string) {
+	if s, ok := state.(FailedStepsState); ok {
+		s.AddFailedStep(id)
+	}
+}
+
+// AttemptsState is implemented by the states recording the attempts of a step,
+// and the last error of a node
+type AttemptsState interface {
+	GetAttempts() int
+	SetAttempts(attempts int)
+
+	GetLastError() string
+	SetLastError(message string)
+}
+
+// stateAttempts returns the attempts recorded in the state, 0 if it does not record them
+func stateAttempts(state StateInterface) int {
+	if s, ok := state.(AttemptsState); ok {
+		return s.GetAttempts()
+	}
+	return 0
+}
+
+// setStateAttempts records the attempts in the state, if it records them
+func setStateAttempts(state StateInterface, attempts int) {
+	if s, ok := state.(AttemptsState); ok {
+		s.SetAttempts(attempts)
+	}
+}
+
+// stateLastError returns the last error recorded in the state, empty if it does not record it
+func stateLastError(state StateInterface) string {
+	if s, ok := state.(AttemptsState); ok {
+		return s.GetLastError()
+	}
+	return ""
+}
+
+// setStateLastError records the last error in the state, if it records it
+func setStateLastError(state StateInterface, message string) {
+	if s, ok := state.(AttemptsState); ok {
+		s.SetLastError(message)
+	}
+}
+
+// ErrorReasonState is implemented by the states recording why a node failed
+type ErrorReasonState interface {
+	GetErrorReason() StateErrorReason
+	SetErrorReason(reason StateErrorReason)
+}
+
+// setStateErrorReason records why a node failed in the state, if it records it
+func setStateErrorReason(state StateInterface, reason StateErrorReason) {
+	if s, ok := state.(ErrorReasonState); ok {
+		s.SetErrorReason(reason)
+	}
+}
+
+// TimedState is implemented by the states recording when a node first started,
+// and when it last ended, used by timelines
+type TimedState interface {
+	GetStartedAt() time.Time
+	SetStartedAt(t time.Time)
+
+	GetEndedAt() time.Time
+	SetEndedAt(t time.Time)
+}
+
+// stateStartedAt returns when the node of the state first started,
+// zero if it did not start or the state does not record it
+func stateStartedAt(state StateInterface) time.Time {
+	if s, ok := state.(TimedState); ok {
+		return s.GetStartedAt()
+	}
+	return time.Time{}
+}
+
+// stateEndedAt returns when the node of the state last ended,
+// zero if it did not end or the state does not record it
+func stateEndedAt(state StateInterface) time.Time {
+	if s, ok := state.(TimedState); ok {
+		return s.GetEndedAt()
+	}
+	return time.Time{}
+}
+
+// startState records when the node of the state first started, if it records it
+func startState(state StateInterface, t time.Time) {
+	if s, ok := state.(TimedState); ok && s.GetStartedAt().IsZero() {
+		s.SetStartedAt(t)
+	}
+}
+
+// endState records when the node of the state ended, if it records it
+func endState(state StateInterface, t time.Time) {
+	if s, ok := state.(TimedState); ok {
+		s.SetEndedAt(t)
+	}
+}
+
+// VersionedState is implemented by the states holding the version they were
+// saved with, used by state stores for optimistic locking
+type VersionedState interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+// ParentState is implemented by the states holding the states of the nodes of
+// a Pipeline or Dag, so nested nodes resume where they paused. Without it, the
+// nodes start with a new state on every run.
+type ParentState interface {
+	GetChildState(id string) StateInterface
+	SetChildState(id string, state StateInterface)
+	GetChildStates() map[string]StateInterface
+}
+
+// stateChild returns the state of a node held by the state of its parent, nil if there is none
+func stateChild(parent StateInterface, id string) StateInterface {
+	if s, ok := parent.(ParentState); ok {
+		return s.GetChildState(id)
+	}
+	return nil
+}
+
+// setStateChild adds the state of a node to the state of its parent, if it holds them
+func setStateChild(parent StateInterface, id string, state StateInterface) {
+	if s, ok := parent.(ParentState); ok {
+		s.SetChildState(id, state)
+	}
+}
+
+// stateChildren returns the states of the nodes held by the state, by node ID
+func stateChildren(state StateInterface) map[string]StateInterface {
+	if s, ok := state.(ParentState); ok {
+		return s.GetChildStates()
+	}
+	return nil
+}
+
+// StateCompensation records the compensation of a completed step
+type StateCompensation struct {
+	StepID string
+	Error  string // empty if the compensation succeeded
+}
+
+// CompensationsState is implemented by the states recording the compensations
+// executed after a failure
+type CompensationsState interface {
+	GetCompensations() []StateCompensation
+	AddCompensation(compensation StateCompensation)
+}
+
+// addStateCompensation records a compensation in the state, if it records them
+func addStateCompensation(state StateInterface, compensation StateCompensation) {
+	if s, ok := state.(CompensationsState); ok {
+		s.AddCompensation(compensation)
+	}
+}
+
 // State represents the current state of a workflow
 type State struct {
 	Status         StateStatus
 	Data           map[string]any
 	CurrentStepID  string
 	CompletedSteps []string
+	SkippedSteps   []string
+	FailedSteps    []string
+	Compensations  []StateCompensation
+	Attempts       int
+	LastError      string
+	ErrorReason    StateErrorReason
 	LastUpdated    time.Time
+
+	// When the node first started, and when it last ended (completed,
+	// failed, skipped or paused). They are zero if it did not run.
+	StartedAt time.Time
+	EndedAt   time.Time
+
+	// Version of the saved state, used by state stores with optimistic locking
+	Version int64
+
+	// states of the nodes of a Pipeline or Dag (ID, StateInterface),
+	// serialized as "Children"
+	children map[string]StateInterface
+
+	// mu guards the state, which is read and saved while the nodes run.
+	// It is a pointer so State values can be copied; it is nil for the
+	// states not created with NewState, which are not locked.
+	mu *sync.RWMutex
 }
 
 // NewState creates a new workflow state
@@ -45,24 +271,50 @@ func NewState() StateInterface {
 		Status:         StateStatusRunning,
 		Data:           make(map[string]any),
 		CompletedSteps: make([]string, 0),
+		SkippedSteps:   make([]string, 0),
+		FailedSteps:    make([]string, 0),
 		LastUpdated:    time.Now(),
+		mu:             &sync.RWMutex{},
+	}
+}
+
+// lock locks the state for writing, and returns the function unlocking it
+func (s *State) lock() func() {
+	if s.mu == nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// rlock locks the state for reading, and returns the function unlocking it
+func (s *State) rlock() func() {
+	if s.mu == nil {
+		return func() {}
 	}
+	s.mu.RLock()
+	return s.mu.RUnlock
 }
 
 // GetStatus returns the current status of the workflow
 func (s *State) GetStatus() StateStatus {
+	defer s.rlock()()
+
 	return s.Status
 }
 
 // SetStatus sets the current status of the workflow
 func (s *State) SetStatus(status StateStatus) {
+	defer s.lock()()
+
 	// Define valid state transitions
 	validTransitions := map[StateStatus][]StateStatus{
 		"":                  {StateStatusRunning},
-		StateStatusRunning:  {StateStatusPaused, StateStatusComplete, StateStatusFailed},
+		StateStatusRunning:  {StateStatusPaused, StateStatusComplete, StateStatusFailed, StateStatusSkipped},
 		StateStatusPaused:   {StateStatusRunning},
 		StateStatusComplete: {}, // No valid transitions from complete
 		StateStatusFailed:   {}, // No valid transitions from failed
+		StateStatusSkipped:  {}, // No valid transitions from skipped
 	}
 
 	// Check if the transition is valid
@@ -93,65 +345,329 @@ func (s *State) SetStatus(status StateStatus) {
 
 // GetData returns the current data of the workflow
 func (s *State) GetData() map[string]any {
+	defer s.rlock()()
+
 	return s.Data
 }
 
-// SetData sets the current data of the workflow
+// SetData sets the current data of the workflow.
+// The state keeps a copy of the map, so later changes to the map are not saved.
 func (s *State) SetData(data map[string]any) {
-	s.Data = data
+	defer s.lock()()
+
+	s.Data = maps.Clone(data)
 	s.LastUpdated = time.Now()
 }
 
-// ToJSON converts the state to JSON
+// ToJSON converts the state to JSON, including the states of its nodes
 func (s *State) ToJSON() ([]byte, error) {
-	s.LastUpdated = time.Now()
+	s.SetLastUpdated(time.Now())
 	return json.Marshal(s)
 }
 
-// FromJSON loads the state from JSON
+// FromJSON loads the state from JSON, including the states of its nodes
 func (s *State) FromJSON(data []byte) error {
 	return json.Unmarshal(data, s)
 }
 
+// stateFields has the fields of State, without its methods,
+// so it can be marshaled with the default JSON encoding
+type stateFields State
+
+// MarshalJSON encodes the state, with the states of its nodes keyed by node ID
+func (s *State) MarshalJSON() ([]byte, error) {
+	defer s.rlock()()
+
+	var children map[string]json.RawMessage
+	if len(s.children) > 0 {
+		children = make(map[string]json.RawMessage, len(s.children))
+		for id, child := range s.children {
+			childJSON, err := json.Marshal(child)
+			if err != nil {
+				return nil, err
+			}
+			children[id] = childJSON
+		}
+	}
+
+	return json.Marshal(struct {
+		*stateFields
+		Children map[string]json.RawMessage `json:",omitempty"`
+	}{
+		stateFields: (*stateFields)(s),
+		Children:    children,
+	})
+}
+
+// UnmarshalJSON decodes the state, with the states of its nodes
+func (s *State) UnmarshalJSON(data []byte) error {
+	defer s.lock()()
+
+	decoded := struct {
+		*stateFields
+		Children map[string]*State
+	}{
+		stateFields: (*stateFields)(s),
+	}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	s.children = nil
+	for id, child := range decoded.Children {
+		if child == nil {
+			continue
+		}
+		if s.children == nil {
+			s.children = make(map[string]StateInterface, len(decoded.Children))
+		}
+		child.mu = &sync.RWMutex{}
+		s.children[id] = child
+	}
+
+	return nil
+}
+
 // GetCurrentStepID returns the ID of the current step
 func (s *State) GetCurrentStepID() string {
+	defer s.rlock()()
+
 	return s.CurrentStepID
 }
 
 // SetCurrentStepID sets the ID of the current step
 func (s *State) SetCurrentStepID(id string) {
+	defer s.lock()()
+
 	s.CurrentStepID = id
 	s.LastUpdated = time.Now()
 }
 
 // GetCompletedSteps returns the list of completed step IDs
 func (s *State) GetCompletedSteps() []string {
+	defer s.rlock()()
+
 	return s.CompletedSteps
 }
 
 // AddCompletedStep adds a step ID to the completed steps list
 func (s *State) AddCompletedStep(id string) {
+	defer s.lock()()
+
 	s.CompletedSteps = append(s.CompletedSteps, id)
 	s.LastUpdated = time.Now()
 }
 
+// GetSkippedSteps returns the list of skipped step IDs
+func (s *State) GetSkippedSteps() []string {
+	defer s.rlock()()
+
+	return s.SkippedSteps
+}
+
+// AddSkippedStep adds a step ID to the skipped steps list
+func (s *State) AddSkippedStep(id string) {
+	defer s.lock()()
+
+	s.SkippedSteps = append(s.SkippedSteps, id)
+	s.LastUpdated = time.Now()
+}
+
+// GetFailedSteps returns the list of failed step IDs
+func (s *State) GetFailedSteps() []string {
+	defer s.rlock()()
+
+	return s.FailedSteps
+}
+
+// AddFailedStep adds a step ID to the failed steps list
+func (s *State) AddFailedStep(id string) {
+	defer s.lock()()
+
+	s.FailedSteps = append(s.FailedSteps, id)
+	s.LastUpdated = time.Now()
+}
+
+// SetFailedSteps replaces the failed steps list, e.g. to clear it
+// when the failed steps run again
+func (s *State) SetFailedSteps(ids []string) {
+	defer s.lock()()
+
+	s.FailedSteps = ids
+	s.LastUpdated = time.Now()
+}
+
 // GetWorkflowData returns the workflow data
 func (s *State) GetWorkflowData() map[string]any {
+	defer s.rlock()()
+
 	return s.Data
 }
 
-// SetWorkflowData sets the workflow data
+// SetWorkflowData sets the workflow data.
+// The state keeps a copy of the map, so later changes to the map are not saved.
 func (s *State) SetWorkflowData(data map[string]any) {
-	s.Data = data
+	defer s.lock()()
+
+	s.Data = maps.Clone(data)
+	s.LastUpdated = time.Now()
+}
+
+// GetCompensations returns the compensations executed after a failure, in the order they ran
+func (s *State) GetCompensations() []StateCompensation {
+	defer s.rlock()()
+
+	return s.Compensations
+}
+
+// AddCompensation adds a compensation to the list of executed compensations
+func (s *State) AddCompensation(compensation StateCompensation) {
+	defer s.lock()()
+
+	s.Compensations = append(s.Compensations, compensation)
+	s.LastUpdated = time.Now()
+}
+
+// GetAttempts returns the number of times the handler was attempted
+func (s *State) GetAttempts() int {
+	defer s.rlock()()
+
+	return s.Attempts
+}
+
+// SetAttempts sets the number of times the handler was attempted
+func (s *State) SetAttempts(attempts int) {
+	defer s.lock()()
+
+	s.Attempts = attempts
+	s.LastUpdated = time.Now()
+}
+
+// GetStartedAt returns when the node first started, zero if it did not run
+func (s *State) GetStartedAt() time.Time {
+	defer s.rlock()()
+
+	return s.StartedAt
+}
+
+// SetStartedAt sets when the node first started
+func (s *State) SetStartedAt(t time.Time) {
+	defer s.lock()()
+
+	s.StartedAt = t
+	s.LastUpdated = time.Now()
+}
+
+// GetEndedAt returns when the node last ended, zero if it did not end yet
+func (s *State) GetEndedAt() time.Time {
+	defer s.rlock()()
+
+	return s.EndedAt
+}
+
+// SetEndedAt sets when the node last ended
+func (s *State) SetEndedAt(t time.Time) {
+	defer s.lock()()
+
+	s.EndedAt = t
+	s.LastUpdated = time.Now()
+}
+
+// GetLastError returns the message of the last error, empty if there was none
+func (s *State) GetLastError() string {
+	defer s.rlock()()
+
+	return s.LastError
+}
+
+// SetLastError sets the message of the last error
+func (s *State) SetLastError(message string) {
+	defer s.lock()()
+
+	s.LastError = message
+	s.LastUpdated = time.Now()
+}
+
+// GetErrorReason returns the reason the workflow failed, empty if it did not fail
+func (s *State) GetErrorReason() StateErrorReason {
+	defer s.rlock()()
+
+	return s.ErrorReason
+}
+
+// SetErrorReason sets the reason the workflow failed
+func (s *State) SetErrorReason(reason StateErrorReason) {
+	defer s.lock()()
+
+	s.ErrorReason = reason
+	s.LastUpdated = time.Now()
+}
+
+// retry pauses the failed state, so its nodes are run again when the run is resumed
+func (s *State) retry() {
+	defer s.lock()()
+
+	if s.Status != StateStatusFailed {
+		return
+	}
+
+	s.Status = StateStatusPaused
+	s.LastError = ""
+	s.ErrorReason = ""
+	s.LastUpdated = time.Now()
+}
+
+// GetChildState returns the state of the node with the given ID, nil if it has none
+func (s *State) GetChildState(id string) StateInterface {
+	defer s.rlock()()
+
+	return s.children[id]
+}
+
+// SetChildState sets the state of the node with the given ID
+func (s *State) SetChildState(id string, state StateInterface) {
+	defer s.lock()()
+
+	if s.children == nil {
+		s.children = make(map[string]StateInterface)
+	}
+	s.children[id] = state
 	s.LastUpdated = time.Now()
 }
 
+// GetChildStates returns the states of the nodes, by node ID
+func (s *State) GetChildStates() map[string]StateInterface {
+	defer s.rlock()()
+
+	return maps.Clone(s.children)
+}
+
+// GetVersion returns the version of the saved state, zero if it was never saved
+// to a state store that supports optimistic locking
+func (s *State) GetVersion() int64 {
+	defer s.rlock()()
+
+	return s.Version
+}
+
+// SetVersion sets the version of the saved state
+func (s *State) SetVersion(version int64) {
+	defer s.lock()()
+
+	s.Version = version
+}
+
 // GetLastUpdated returns the timestamp of the last update
 func (s *State) GetLastUpdated() time.Time {
+	defer s.rlock()()
+
 	return s.LastUpdated
 }
 
 // SetLastUpdated sets the timestamp of the last update
 func (s *State) SetLastUpdated(t time.Time) {
+	defer s.lock()()
+
 	s.LastUpdated = t
 }
@@ -0,0 +1,110 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrStateNotFound is returned by a StateStore, when there is no state for the given run ID
+var ErrStateNotFound = errors.New("state not found")
+
+// ErrStateConflict is returned by a StateStore with optimistic locking, when the
+// state was saved by someone else since it was loaded (e.g. another worker
+// resumed the same run)
+var ErrStateConflict = errors.New("state was modified concurrently")
+
+// StateStore persists the states of workflow runs, by run ID.
+//
+// A Step, Pipeline or Dag with a state store (see WithStateStore) saves the
+// state of its run after every completed node, and when the run ends, so a
+// run interrupted by a crash can be resumed from its last checkpoint with ResumeRun.
+//
+// Implementations must be safe for concurrent use.
+type StateStore interface {
+	// Save saves the state of the run with the given ID, replacing any previous state
+	Save(ctx context.Context, runID string, state StateInterface) error
+
+	// Load returns the state of the run with the given ID, or ErrStateNotFound
+	Load(ctx context.Context, runID string) (StateInterface, error)
+
+	// List returns the IDs of the saved runs, sorted
+	List(ctx context.Context) ([]string, error)
+
+	// Delete deletes the state of the run with the given ID.
+	// Deleting a run that does not exist is not an error.
+	Delete(ctx context.Context, runID string) error
+}
+
+// memoryStateStore keeps the states in memory, serialized to JSON,
+// so later changes to a saved state do not affect the store
+type memoryStateStore struct {
+	mu     sync.RWMutex
+	states map[string][]byte
+}
+
+var _ StateStore = (*memoryStateStore)(nil)
+
+// NewMemoryStateStore creates a state store that keeps the states in memory.
+// It is useful for tests, and for workflows that do not need to survive a restart.
+func NewMemoryStateStore() StateStore {
+	return &memoryStateStore{
+		states: make(map[string][]byte),
+	}
+}
+
+// Save saves the state of the run with the given ID
+func (s *memoryStateStore) Save(ctx context.Context, runID string, state StateInterface) error {
+	stateJSON, err := state.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[runID] = stateJSON
+	return nil
+}
+
+// Load returns the state of the run with the given ID
+func (s *memoryStateStore) Load(ctx context.Context, runID string) (StateInterface, error) {
+	s.mu.RLock()
+	stateJSON, ok := s.states[runID]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrStateNotFound
+	}
+
+	state := NewState()
+	if err := state.FromJSON(stateJSON); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// List returns the IDs of the saved runs, sorted
+func (s *memoryStateStore) List(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	runIDs := make([]string, 0, len(s.states))
+	for runID := range s.states {
+		runIDs = append(runIDs, runID)
+	}
+	slices.Sort(runIDs)
+
+	return runIDs, nil
+}
+
+// Delete deletes the state of the run with the given ID
+func (s *memoryStateStore) Delete(ctx context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, runID)
+	return nil
+}
@@ -0,0 +1,135 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// stateFileExtension is the extension of the files of the file state store
+const stateFileExtension = ".json"
+
+// fileStateStore keeps the state of every run in a JSON file, named after the run ID
+type fileStateStore struct {
+	dir string
+}
+
+var _ StateStore = (*fileStateStore)(nil)
+
+// NewFileStateStore creates a state store that keeps the state of every run
+// in a JSON file, in the given directory. The directory is created if needed.
+//
+// Files are written to a temporary file first, then renamed, so a crash
+// while saving never leaves a partially written state behind.
+func NewFileStateStore(dir string) (StateStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileStateStore{dir: dir}, nil
+}
+
+// Save saves the state of the run with the given ID
+func (s *fileStateStore) Save(ctx context.Context, runID string, state StateInterface) error {
+	path, err := s.path(runID)
+	if err != nil {
+		return err
+	}
+
+	stateJSON, err := state.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(s.dir, runID+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// Remove the temporary file, unless it was renamed
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(stateJSON); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// Load returns the state of the run with the given ID
+func (s *fileStateStore) Load(ctx context.Context, runID string) (StateInterface, error) {
+	path, err := s.path(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	stateJSON, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := NewState()
+	if err := state.FromJSON(stateJSON); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// List returns the IDs of the saved runs, sorted
+func (s *fileStateStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	runIDs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), stateFileExtension) {
+			continue
+		}
+		runIDs = append(runIDs, strings.TrimSuffix(entry.Name(), stateFileExtension))
+	}
+	slices.Sort(runIDs)
+
+	return runIDs, nil
+}
+
+// Delete deletes the state of the run with the given ID
+func (s *fileStateStore) Delete(ctx context.Context, runID string) error {
+	path, err := s.path(runID)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path returns the path of the file of the run with the given ID.
+// Run IDs that are not valid file names are rejected.
+func (s *fileStateStore) path(runID string) (string, error) {
+	if runID == "" || runID == "." || runID == ".." || strings.ContainsAny(runID, `/\`) {
+		return "", fmt.Errorf("invalid run ID %q", runID)
+	}
+	return filepath.Join(s.dir, runID+stateFileExtension), nil
+}
@@ -0,0 +1,308 @@
+package wf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func testStateStore(t *testing.T, store StateStore) {
+	ctx := context.Background()
+
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound, got %v", err)
+	}
+
+	state := NewState()
+	state.SetWorkflowData(map[string]any{"key": "value"})
+	state.AddCompletedStep("step1")
+	state.SetStatus(StateStatusPaused)
+
+	for _, runID := range []string{"run2", "run1"} {
+		if err := store.Save(ctx, runID, state); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// Later changes do not affect the saved state
+	state.AddCompletedStep("step2")
+
+	loaded, err := store.Load(ctx, "run1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.GetStatus() != StateStatusPaused {
+		t.Errorf("Expected status %s, got %s", StateStatusPaused, loaded.GetStatus())
+	}
+	if !slices.Equal(loaded.GetCompletedSteps(), []string{"step1"}) {
+		t.Errorf("Expected completed steps [step1], got %v", loaded.GetCompletedSteps())
+	}
+	if loaded.GetWorkflowData()["key"] != "value" {
+		t.Errorf("Expected the workflow data to be saved, got %v", loaded.GetWorkflowData())
+	}
+
+	runIDs, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !slices.Equal(runIDs, []string{"run1", "run2"}) {
+		t.Errorf("Expected run IDs [run1 run2], got %v", runIDs)
+	}
+
+	if err := store.Delete(ctx, "run1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, "run1"); err != nil {
+		t.Errorf("Deleting a missing run should not fail, got %v", err)
+	}
+	if _, err := store.Load(ctx, "run1"); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound after Delete, got %v", err)
+	}
+}
+
+func Test_MemoryStateStore(t *testing.T) {
+	testStateStore(t, NewMemoryStateStore())
+}
+
+func Test_FileStateStore(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStateStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+
+	testStateStore(t, store)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "run2.json" {
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only run2.json, got %v", names)
+	}
+
+	if err := store.Save(context.Background(), "../escape", NewState()); err == nil {
+		t.Error("Expected an error for a run ID that is not a file name")
+	}
+}
+
+func Test_Pipeline_StateStore_Checkpoint(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore()
+
+	var checkpoint StateInterface
+	var step1Calls atomic.Int32
+
+	step1 := NewStep(WithID("step1"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		step1Calls.Add(1)
+		data["step1"] = true
+		return ctx, data, nil
+	}))
+	step2 := NewStep(WithID("step2"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		// Capture the checkpoint saved after step1, as a crash here would leave it
+		if checkpoint == nil {
+			state, err := store.Load(ctx, "run-1")
+			if err != nil {
+				return ctx, data, err
+			}
+			checkpoint = state
+		}
+		data["step2"] = true
+		return ctx, data, nil
+	}))
+
+	pipeline := NewPipeline(WithRunnables(step1, step2), WithStateStore(store))
+
+	run := pipeline.NewRun(ctx, map[string]any{})
+	run.SetID("run-1")
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The final state is saved
+	final, err := store.Load(ctx, "run-1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if final.GetStatus() != StateStatusComplete {
+		t.Errorf("Expected the saved state to be completed, got %s", final.GetStatus())
+	}
+
+	if checkpoint == nil {
+		t.Fatal("Expected a checkpoint after step1")
+	}
+	if checkpoint.GetStatus() != StateStatusRunning || !slices.Equal(checkpoint.GetCompletedSteps(), []string{"step1"}) {
+		t.Fatalf("Expected a running checkpoint with step1 completed, got %s %v", checkpoint.GetStatus(), checkpoint.GetCompletedSteps())
+	}
+
+	// Resume from the checkpoint, as a new process would after a crash
+	if err := store.Save(ctx, "run-1", checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := pipeline.ResumeRun(ctx, "run-1", nil)
+	if err != nil {
+		t.Fatalf("ResumeRun failed: %v", err)
+	}
+	if err := resumed.Execute(); err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+
+	if step1Calls.Load() != 1 {
+		t.Errorf("Expected step1 not to run again, got %d calls", step1Calls.Load())
+	}
+	if resumed.GetData()["step1"] != true || resumed.GetData()["step2"] != true {
+		t.Errorf("Expected the data of both steps, got %v", resumed.GetData())
+	}
+	if !resumed.IsCompleted() {
+		t.Errorf("Expected the resumed run to be completed, got %s", resumed.GetState().GetStatus())
+	}
+
+	// A finished run cannot be resumed
+	if _, err := pipeline.ResumeRun(ctx, "run-1", nil); err == nil {
+		t.Error("Expected an error when resuming a finished run")
+	}
+}
+
+func Test_Dag_StateStore_Run(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore()
+
+	step := NewStep(WithID("step"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	dag := NewDag(WithID("dag"), WithRunnables(step), WithStateStore(store))
+
+	if _, _, err := dag.Run(ctx, map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Run saves the state with the ID of the DAG
+	state, err := store.Load(ctx, "dag")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.GetStatus() != StateStatusComplete {
+		t.Errorf("Expected the saved state to be completed, got %s", state.GetStatus())
+	}
+}
+
+func Test_Dag_StateStore_NestedCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore()
+
+	var checkpoint []byte
+	var calls atomic.Int32
+
+	newStep := func(id string) StepInterface {
+		return NewStep(WithID(id), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			calls.Add(1)
+
+			// Capture the checkpoint saved after step2, as a crash in step3 would leave it
+			if id == "step3" && checkpoint == nil {
+				state, err := store.Load(ctx, "run-1")
+				if err != nil {
+					return ctx, data, err
+				}
+				if checkpoint, err = state.ToJSON(); err != nil {
+					return ctx, data, err
+				}
+			}
+
+			data[id] = true
+			return ctx, data, nil
+		}))
+	}
+
+	pipeline := NewPipeline(WithID("pipeline"), WithRunnables(newStep("step1"), newStep("step2"), newStep("step3")))
+	dag := NewDag(WithRunnables(pipeline), WithStateStore(store))
+
+	run := dag.NewRun(ctx, map[string]any{})
+	run.SetID("run-1")
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The checkpoint holds the progress of the nested pipeline
+	state := NewState()
+	if err := state.FromJSON(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	pipelineState := state.(ParentState).GetChildState("pipeline")
+	if pipelineState == nil {
+		t.Fatal("Expected the checkpoint to hold the state of the pipeline")
+	}
+	if !slices.Equal(pipelineState.GetCompletedSteps(), []string{"step1", "step2"}) {
+		t.Fatalf("Expected step1 and step2 to be completed in the checkpoint, got %v", pipelineState.GetCompletedSteps())
+	}
+
+	// Resume from the checkpoint, as a new process would after a crash
+	if err := store.Save(ctx, "run-1", state); err != nil {
+		t.Fatal(err)
+	}
+
+	calls.Store(0)
+	resumed, err := dag.ResumeRun(ctx, "run-1", nil)
+	if err != nil {
+		t.Fatalf("ResumeRun failed: %v", err)
+	}
+	if err := resumed.Execute(); err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected only step3 to run again, got %d calls", calls.Load())
+	}
+	for _, id := range []string{"step1", "step2", "step3"} {
+		if resumed.GetData()[id] != true {
+			t.Errorf("Expected the data of %s, got %v", id, resumed.GetData())
+		}
+	}
+}
+
+func Test_Dag_StateStore_ParallelCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore()
+
+	newPipeline := func(id string) PipelineInterface {
+		steps := []RunnableInterface{}
+		for _, stepID := range []string{id + "-1", id + "-2", id + "-3"} {
+			steps = append(steps, NewStep(WithID(stepID), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+				data[stepID] = true
+				return ctx, data, nil
+			})))
+		}
+		return NewPipeline(WithID(id), WithRunnables(steps...))
+	}
+
+	dag := NewDag(
+		WithRunnables(newPipeline("a"), newPipeline("b"), newPipeline("c")),
+		WithMaxConcurrency(3),
+		WithStateStore(store),
+	)
+
+	run := dag.NewRun(ctx, map[string]any{})
+	if err := run.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	saved, err := store.Load(ctx, run.GetID())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		pipelineState := saved.(ParentState).GetChildState(id)
+		if pipelineState == nil || len(pipelineState.GetCompletedSteps()) != 3 {
+			t.Errorf("Expected the saved state of pipeline %s to have 3 completed steps", id)
+		}
+	}
+}
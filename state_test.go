@@ -1,6 +1,7 @@
 package wf
 
 import (
+	"slices"
 	"testing"
 	"time"
 )
@@ -115,6 +116,57 @@ func TestStateCompletedSteps(t *testing.T) {
 	}
 }
 
+func TestStateSkippedSteps(t *testing.T) {
+	state := NewState().(*State)
+
+	state.AddSkippedStep("step1")
+	state.AddSkippedStep("step2")
+
+	skippedSteps := state.GetSkippedSteps()
+	if len(skippedSteps) != 2 {
+		t.Errorf("Expected 2 skipped steps, got %d", len(skippedSteps))
+	}
+
+	// Skipped is a terminal status
+	state.SetStatus(StateStatusSkipped)
+	if state.GetStatus() != StateStatusSkipped {
+		t.Errorf("Expected status %v, got %v", StateStatusSkipped, state.GetStatus())
+	}
+	state.SetStatus(StateStatusRunning)
+	if state.GetStatus() != StateStatusSkipped {
+		t.Errorf("Expected skipped status to be terminal, got %v", state.GetStatus())
+	}
+}
+
+func TestStateFailedSteps(t *testing.T) {
+	state := NewState().(*State)
+
+	state.AddFailedStep("step1")
+	state.AddFailedStep("step2")
+
+	if failedSteps := state.GetFailedSteps(); len(failedSteps) != 2 {
+		t.Errorf("Expected 2 failed steps, got %d", len(failedSteps))
+	}
+
+	// The failed steps are saved with the state
+	jsonData, err := state.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	loaded := NewState().(*State)
+	if err := loaded.FromJSON(jsonData); err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	if failedSteps := loaded.GetFailedSteps(); len(failedSteps) != 2 || failedSteps[0] != "step1" {
+		t.Errorf("Expected the failed steps to be loaded, got %v", failedSteps)
+	}
+
+	state.SetFailedSteps(nil)
+	if failedSteps := state.GetFailedSteps(); len(failedSteps) != 0 {
+		t.Errorf("Expected the failed steps to be cleared, got %v", failedSteps)
+	}
+}
+
 func TestStateCurrentStep(t *testing.T) {
 	state := NewState()
 
@@ -144,6 +196,34 @@ func TestStateLastUpdated(t *testing.T) {
 	}
 }
 
+func TestStateTimes(t *testing.T) {
+	state := NewState().(*State)
+	if !state.GetStartedAt().IsZero() || !state.GetEndedAt().IsZero() {
+		t.Error("Expected no start and end times for a new state")
+	}
+
+	startedAt := time.Now()
+	endedAt := startedAt.Add(time.Second)
+	state.SetStartedAt(startedAt)
+	state.SetEndedAt(endedAt)
+	if !state.GetStartedAt().Equal(startedAt) || !state.GetEndedAt().Equal(endedAt) {
+		t.Errorf("Expected times %v and %v, got %v and %v", startedAt, endedAt, state.GetStartedAt(), state.GetEndedAt())
+	}
+
+	// The times are saved with the state
+	data, err := state.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	loaded := NewState().(*State)
+	if err := loaded.FromJSON(data); err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	if !loaded.GetStartedAt().Equal(startedAt) || !loaded.GetEndedAt().Equal(endedAt) {
+		t.Errorf("Expected the times to be loaded, got %v and %v", loaded.GetStartedAt(), loaded.GetEndedAt())
+	}
+}
+
 func TestStateJSON(t *testing.T) {
 	state := NewState()
 
@@ -202,8 +282,16 @@ func TestStateJSONError(t *testing.T) {
 }
 
 func TestStateInterface(t *testing.T) {
-	// This test ensures that State implements StateInterface
+	// This test ensures that State implements StateInterface, and the optional interfaces
 	var _ StateInterface = (*State)(nil)
+	var _ SkippedStepsState = (*State)(nil)
+	var _ AttemptsState = (*State)(nil)
+	var _ ErrorReasonState = (*State)(nil)
+	var _ CompensationsState = (*State)(nil)
+	var _ ParentState = (*State)(nil)
+	var _ VersionedState = (*State)(nil)
+	var _ FailedStepsState = (*State)(nil)
+	var _ TimedState = (*State)(nil)
 }
 
 func TestStateStatusTransitions(t *testing.T) {
@@ -242,3 +330,80 @@ func TestStateStatusTransitions(t *testing.T) {
 		}
 	}
 }
+
+func TestStateJSONChildren(t *testing.T) {
+	state := NewState()
+	state.AddCompletedStep("pipeline")
+
+	pipelineState := NewState()
+	pipelineState.AddCompletedStep("step1")
+	pipelineState.SetStatus(StateStatusPaused)
+	state.(ParentState).SetChildState("pipeline", pipelineState)
+
+	stepState := NewState()
+	stepState.SetWorkflowData(map[string]any{"key": "value"})
+	stepState.SetStatus(StateStatusComplete)
+	pipelineState.(ParentState).SetChildState("step1", stepState)
+
+	jsonData, err := state.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+
+	newState := NewState()
+	if err := newState.FromJSON(jsonData); err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+
+	newPipelineState := newState.(ParentState).GetChildState("pipeline")
+	if newPipelineState == nil {
+		t.Fatal("Expected the state of the pipeline to be restored")
+	}
+	if newPipelineState.GetStatus() != StateStatusPaused {
+		t.Errorf("Expected the pipeline to be paused, got %s", newPipelineState.GetStatus())
+	}
+
+	newStepState := newPipelineState.(ParentState).GetChildState("step1")
+	if newStepState == nil {
+		t.Fatal("Expected the state of the nested step to be restored")
+	}
+	if newStepState.GetStatus() != StateStatusComplete {
+		t.Errorf("Expected the step to be completed, got %s", newStepState.GetStatus())
+	}
+	if newStepState.GetWorkflowData()["key"] != "value" {
+		t.Errorf("Expected the data of the step to be restored, got %v", newStepState.GetWorkflowData())
+	}
+
+	if len(newState.(ParentState).GetChildStates()) != 1 {
+		t.Errorf("Expected 1 child state, got %d", len(newState.(ParentState).GetChildStates()))
+	}
+}
+
+func TestStateWorkflowDataCopy(t *testing.T) {
+	state := NewState()
+
+	data := map[string]any{"key": "value"}
+	state.SetWorkflowData(data)
+	data["key"] = "changed"
+
+	if state.GetWorkflowData()["key"] != "value" {
+		t.Error("Expected the state to keep a copy of the data")
+	}
+}
+
+func TestStateCopy(t *testing.T) {
+	state := NewState().(*State)
+	state.AddCompletedStep("step1")
+
+	// States can be copied, e.g. to keep a snapshot, and literals are usable
+	copied := *state
+	if !slices.Equal(copied.GetCompletedSteps(), []string{"step1"}) {
+		t.Errorf("Expected the copy to have the completed steps, got %v", copied.GetCompletedSteps())
+	}
+
+	literal := &State{Status: StateStatusRunning}
+	literal.AddCompletedStep("step1")
+	if !slices.Equal(literal.GetCompletedSteps(), []string{"step1"}) {
+		t.Errorf("Expected the completed steps [step1], got %v", literal.GetCompletedSteps())
+	}
+}
@@ -3,6 +3,10 @@ package wf
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
 
 	"github.com/dracory/uid"
 )
@@ -12,7 +16,33 @@ type stepImplementation struct {
 	name    string
 	data    map[string]any
 	handler StepHandler
+
+	// compensation undoes the work of the handler, when a later step fails
+	compensation StepHandler
+
+	// names the handlers are registered under, empty if they are not known
+	handlerName      string
+	compensationName string
+
+	// registry the handlers without function are looked up in by name,
+	// nil for DefaultHandlerRegistry
+	registry HandlerRegistryInterface
+
+	retry   *RetryPolicy
+	timeout time.Duration
 	state   StateInterface
+
+	// store the state of a run is saved to, nil if it is not saved
+	store StateStore
+
+	// listeners of the events of a run
+	listeners []EventListener
+
+	// middlewares wrapping the runs
+	middlewares []Middleware
+
+	// logger of the runs, nil if they are not logged
+	logger *slog.Logger
 }
 
 // NewStep creates a new step with the given options
@@ -31,6 +61,16 @@ func NewStep(opts ...interface{}) StepInterface {
 			o(step) // Handles WithName
 		case func(Identifiable):
 			o(step) // Handles WithID
+		case func(StateStoreSetter):
+			o(step) // Handles WithStateStore
+		case func(TimeoutSetter):
+			o(step) // Handles WithTimeout
+		case func(ListenerAdder):
+			o(step) // Handles WithListener
+		case func(MiddlewareAdder):
+			o(step) // Handles WithMiddleware
+		case func(LoggerSetter):
+			o(step) // Handles WithLogger
 		case func(StepInterface):
 			o(step) // Handles WithHandler and other Step-specific options
 		}
@@ -55,51 +95,188 @@ func (s *stepImplementation) SetName(name string) {
 	s.name = name
 }
 
-// GetHandler returns the step's execution function
+// GetHandler returns the step's execution function. Without a function, it
+// returns the handler registered under the handler name in the handler registry.
 func (s *stepImplementation) GetHandler() StepHandler {
+	if s.handler == nil && s.handlerName != "" {
+		handler, _ := s.handlerRegistry().Get(s.handlerName)
+		return handler
+	}
 	return s.handler
 }
 
-// SetHandler sets the step's execution function
+// SetHandler sets the step's execution function.
+// The handler name is cleared, as it no longer matches the handler.
 func (s *stepImplementation) SetHandler(fn StepHandler) {
 	s.handler = fn
+	s.handlerName = ""
 }
 
-// Run executes the step's function with the given context
-func (s *stepImplementation) Run(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
-	// If we have a saved state, use it
-	if s.state.GetStatus() == StateStatus(StateStatusPaused) {
-		return s.resumeFromState(ctx, data)
+// GetCompensation returns the step's compensation handler. Without a function, it
+// returns the handler registered under the compensation name in the handler registry.
+func (s *stepImplementation) GetCompensation() StepHandler {
+	if s.compensation == nil && s.compensationName != "" {
+		compensation, _ := s.handlerRegistry().Get(s.compensationName)
+		return compensation
 	}
+	return s.compensation
+}
 
-	// Initialize new state
-	s.state = NewState()
-	s.state.SetStatus(StateStatus(StateStatusRunning))
-	s.state.SetWorkflowData(data)
-	s.state.SetCurrentStepID(s.id)
+// SetCompensation sets the step's compensation handler.
+// The compensation name is cleared, as it no longer matches the handler.
+func (s *stepImplementation) SetCompensation(fn StepHandler) {
+	s.compensation = fn
+	s.compensationName = ""
+}
+
+// GetHandlerRegistry returns the registry the handlers are looked up in by name,
+// nil for DefaultHandlerRegistry
+func (s *stepImplementation) GetHandlerRegistry() HandlerRegistryInterface {
+	return s.registry
+}
+
+// SetHandlerRegistry sets the registry the handlers are looked up in by name
+func (s *stepImplementation) SetHandlerRegistry(registry HandlerRegistryInterface) {
+	s.registry = registry
+}
+
+// handlerRegistry returns the registry the handlers are looked up in by name
+func (s *stepImplementation) handlerRegistry() HandlerRegistryInterface {
+	if s.registry == nil {
+		return DefaultHandlerRegistry
+	}
+	return s.registry
+}
+
+// GetHandlerName returns the name the handler is registered under
+func (s *stepImplementation) GetHandlerName() string {
+	return s.handlerName
+}
+
+// SetHandlerName sets the name the handler is registered under
+func (s *stepImplementation) SetHandlerName(name string) {
+	s.handlerName = name
+}
+
+// GetCompensationName returns the name the compensation handler is registered under
+func (s *stepImplementation) GetCompensationName() string {
+	return s.compensationName
+}
+
+// SetCompensationName sets the name the compensation handler is registered under
+func (s *stepImplementation) SetCompensationName(name string) {
+	s.compensationName = name
+}
+
+// Compensate runs the compensation handler, if the step is completed and has one
+func (s *stepImplementation) Compensate(ctx context.Context, data map[string]any) (context.Context, map[string]any, []CompensationResult) {
+	return s.compensateWithState(ctx, data, s.state)
+}
+
+// compensateWithState runs the compensation handler, if the given state is completed
+func (s *stepImplementation) compensateWithState(ctx context.Context, data map[string]any, state StateInterface) (context.Context, map[string]any, []CompensationResult) {
+	compensation := s.GetCompensation()
+	if compensation == nil || state.GetStatus() != StateStatusComplete {
+		return ctx, data, nil
+	}
+
+	resultCtx, resultData, err := compensation(ctx, data)
+
+	result := CompensationResult{StepID: s.id, StepName: s.name, Err: err}
+	addStateCompensation(state, newStateCompensation(result))
 
-	// Execute step
-	ctx, data, err := s.handler(ctx, data)
 	if err != nil {
-		s.state.SetStatus(StateStatus(StateStatusFailed))
-		return ctx, data, err
+		return ctx, data, []CompensationResult{result}
 	}
 
-	// Mark step as completed
-	s.state.AddCompletedStep(s.id)
-	s.state.SetWorkflowData(data)
-	s.state.SetStatus(StateStatus(StateStatusComplete))
+	return resultCtx, resultData, []CompensationResult{result}
+}
 
-	return ctx, data, nil
+// GetRetryPolicy returns the step's retry policy, nil if the step is not retried
+func (s *stepImplementation) GetRetryPolicy() *RetryPolicy {
+	return s.retry
+}
+
+// SetRetryPolicy sets the step's retry policy, nil disables retries
+func (s *stepImplementation) SetRetryPolicy(policy *RetryPolicy) {
+	s.retry = policy
+}
+
+// GetTimeout returns the maximum duration of the step, zero if there is none
+func (s *stepImplementation) GetTimeout() time.Duration {
+	return s.timeout
+}
+
+// SetTimeout sets the maximum duration of the step, including retries.
+// Zero or less disables the timeout.
+func (s *stepImplementation) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// GetStateStore returns the store the state of a run is saved to, nil if there is none
+func (s *stepImplementation) GetStateStore() StateStore {
+	return s.store
+}
+
+// SetStateStore sets the store the state of a run is saved to, when the run ends
+func (s *stepImplementation) SetStateStore(store StateStore) {
+	s.store = store
+}
+
+// GetListeners returns the listeners of the events of the step
+func (s *stepImplementation) GetListeners() []EventListener {
+	return s.listeners
+}
+
+// AddListener adds a listener of the events of the step
+func (s *stepImplementation) AddListener(listener EventListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// GetMiddlewares returns the middlewares wrapping the runs of the step
+func (s *stepImplementation) GetMiddlewares() []Middleware {
+	return s.middlewares
+}
+
+// AddMiddleware adds a middleware wrapping the runs of the step
+func (s *stepImplementation) AddMiddleware(middleware Middleware) {
+	s.middlewares = append(s.middlewares, middleware)
+}
+
+// GetLogger returns the logger of the step, nil if there is none
+func (s *stepImplementation) GetLogger() *slog.Logger {
+	return s.logger
+}
+
+// SetLogger sets the logger of the step
+func (s *stepImplementation) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Run executes the step's function with the given context
+func (s *stepImplementation) Run(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+	// Initialize new state, unless a saved state is resumed
+	if s.state.GetStatus() != StateStatus(StateStatusPaused) {
+		s.state = NewState()
+	}
+
+	return runDefinition(ctx, data, s, s.state)
+}
+
+// NewRun creates a run of the step, with its own ID and state.
+// The step itself is not modified by the run.
+func (s *stepImplementation) NewRun(ctx context.Context, data map[string]any) RunInterface {
+	return newRun(s, ctx, data)
+}
+
+// ResumeRun creates a run from the state saved in the state store, with the given ID
+func (s *stepImplementation) ResumeRun(ctx context.Context, runID string, data map[string]any) (RunInterface, error) {
+	return resumeRun(ctx, s, runID, data)
 }
 
 // Pause pauses the workflow execution
 func (s *stepImplementation) Pause() error {
-	if s.state.GetStatus() != StateStatus(StateStatusRunning) {
-		return errors.New("workflow is not running")
-	}
-	s.state.SetStatus(StateStatus(StateStatusPaused))
-	return nil
+	return pause(s.state)
 }
 
 // Resume resumes the workflow execution from the last saved state
@@ -107,33 +284,147 @@ func (s *stepImplementation) Resume(ctx context.Context, data map[string]any) (c
 	if s.state.GetStatus() != StateStatus(StateStatusPaused) {
 		return ctx, data, errors.New("workflow is not paused")
 	}
-	return s.resumeFromState(ctx, data)
+	return runDefinition(ctx, data, s, s.state)
 }
 
-// resumeFromState resumes the workflow from the saved state
-func (s *stepImplementation) resumeFromState(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
-	// Update data with saved state
-	savedData := s.state.GetWorkflowData()
-	for k, v := range savedData {
-		data[k] = v
+// runWithState runs the step with the given state. A paused state is resumed.
+func (s *stepImplementation) runWithState(ctx context.Context, data map[string]any, state StateInterface, ex *execution) (context.Context, map[string]any, error) {
+	if state.GetStatus() == StateStatus(StateStatusPaused) {
+		// Update data with saved state
+		savedData := state.GetWorkflowData()
+		for k, v := range savedData {
+			data[k] = v
+		}
+		state.SetStatus(StateStatus(StateStatusRunning))
+	} else {
+		state.SetStatus(StateStatus(StateStatusRunning))
+		state.SetWorkflowData(data)
+		state.SetCurrentStepID(s.id)
 	}
 
 	// Execute step
-	s.state.SetStatus(StateStatus(StateStatusRunning))
-	ctx, data, err := s.handler(ctx, data)
+	return s.execute(ctx, data, state)
+}
+
+// execute runs the step's handler and records the outcome in the state.
+// A handler returning ErrSkip marks the step as skipped, and no error is returned.
+// A handler returning ErrPaused marks the step as paused.
+func (s *stepImplementation) execute(ctx context.Context, data map[string]any, state StateInterface) (context.Context, map[string]any, error) {
+	runCtx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	resultCtx, data, err := s.runHandler(runCtx, data, state)
+	ctx = detachTimeout(ctx, runCtx, resultCtx)
+
+	if errors.Is(err, ErrSkip) {
+		addSkippedStep(state, s.id)
+		state.SetWorkflowData(data)
+		state.SetStatus(StateStatus(StateStatusSkipped))
+		return ctx, data, nil
+	}
+
+	// The handler asked to pause, the step runs again when resumed
+	if errors.Is(err, ErrPaused) {
+		state.SetStatus(StateStatus(StateStatusPaused))
+		return ctx, data, err
+	}
+
 	if err != nil {
-		s.state.SetStatus(StateStatus(StateStatusFailed))
+		recordFailure(state, err)
 		return ctx, data, err
 	}
 
 	// Mark step as completed
-	s.state.AddCompletedStep(s.id)
-	s.state.SetWorkflowData(data)
-	s.state.SetStatus(StateStatus(StateStatusComplete))
+	state.AddCompletedStep(s.id)
+	state.SetWorkflowData(data)
+	state.SetStatus(StateStatus(StateStatusComplete))
 
 	return ctx, data, nil
 }
 
+// runHandler calls the step's handler, retrying it according to the retry policy.
+// The number of attempts and the last error are recorded in the state.
+func (s *stepImplementation) runHandler(ctx context.Context, data map[string]any, state StateInterface) (context.Context, map[string]any, error) {
+	handler := s.GetHandler()
+	if handler == nil && s.handlerName != "" {
+		err := fmt.Errorf("handler %q is not registered", s.handlerName)
+		setStateLastError(state, err.Error())
+		return ctx, data, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		setStateAttempts(state, attempt)
+
+		resultCtx, resultData, err := callHandler(ctx, data, handler)
+		if err == nil || errors.Is(err, ErrSkip) || errors.Is(err, ErrPaused) {
+			return resultCtx, resultData, err
+		}
+
+		err = timeoutError(ctx, err)
+
+		setStateLastError(state, err.Error())
+
+		if attempt >= s.retry.attempts() || !s.retry.isRetryable(err) {
+			return resultCtx, resultData, err
+		}
+
+		// Wait before the next attempt, unless the context is done
+		if cause := s.retry.waitBackoff(ctx, attempt); cause != nil {
+			return resultCtx, resultData, errors.Join(err, cause)
+		}
+	}
+}
+
+// callHandler calls the step's handler once.
+//
+// When the step, or a Pipeline or Dag running it, has a timeout, the handler runs
+// in its own goroutine with a copy of the data, and is abandoned as soon as the
+// context is done, so a handler that ignores the context cannot block the workflow.
+// An abandoned handler keeps running until it returns, and its result is dropped.
+// A panic of the handler is raised again in the calling goroutine, unless the
+// handler was abandoned.
+func callHandler(ctx context.Context, data map[string]any, handler StepHandler) (context.Context, map[string]any, error) {
+	if !hasTimeout(ctx) {
+		return handler(ctx, data)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return ctx, data, context.Cause(ctx)
+	}
+
+	type handlerResult struct {
+		ctx      context.Context
+		data     map[string]any
+		err      error
+		panicked bool
+		panic    any
+	}
+
+	results := make(chan handlerResult, 1)
+	go func(data map[string]any) {
+		result := handlerResult{panicked: true}
+		defer func() {
+			if result.panicked {
+				result.panic = recover()
+			}
+			results <- result
+		}()
+
+		result.ctx, result.data, result.err = handler(ctx, data)
+		result.panicked = false
+	}(maps.Clone(data))
+
+	select {
+	case result := <-results:
+		if result.panicked {
+			panic(result.panic)
+		}
+		return result.ctx, result.data, result.err
+	case <-ctx.Done():
+		return ctx, data, context.Cause(ctx)
+	}
+}
+
 // GetState returns the current workflow state
 func (s *stepImplementation) GetState() StateInterface {
 	return s.state
@@ -161,6 +452,10 @@ func (s *stepImplementation) IsFailed() bool {
 	return s.state.GetStatus() == StateStatusFailed
 }
 
+func (s *stepImplementation) IsSkipped() bool {
+	return s.state.GetStatus() == StateStatusSkipped
+}
+
 func (s *stepImplementation) IsWaiting() bool {
 	return s.state.GetStatus() == "" // Initial state before running
 }
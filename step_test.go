@@ -2,7 +2,9 @@ package wf
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
 func Test_Step_Basic(t *testing.T) {
@@ -83,3 +85,131 @@ func Test_Step_WithOptions(t *testing.T) {
 		t.Error("Expected test data to be true after handler execution")
 	}
 }
+
+func Test_Step_Skip(t *testing.T) {
+	step := NewStep(
+		WithName("Skipping Step"),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			return ctx, data, ErrSkip
+		}),
+	)
+
+	if step.IsSkipped() {
+		t.Error("Step should not be skipped before it runs")
+	}
+
+	_, _, err := step.Run(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("Skipping a step should not return an error, got %v", err)
+	}
+
+	if !step.IsSkipped() {
+		t.Errorf("Expected step to be skipped, got status %q", step.GetState().GetStatus())
+	}
+	if step.IsCompleted() || step.IsFailed() || step.IsWaiting() {
+		t.Error("A skipped step should not be completed, failed or waiting")
+	}
+}
+
+func Test_Step_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	step := NewStep(
+		WithName("Hung Step"),
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			<-release // ignores the context
+			return ctx, data, nil
+		}),
+	)
+
+	start := time.Now()
+	ctx, _, err := step.Run(context.Background(), map[string]any{})
+	if time.Since(start) > time.Second {
+		t.Fatal("Expected the hung handler to be abandoned after the timeout")
+	}
+
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("The returned context should not carry the step's deadline")
+	}
+	if !step.IsFailed() {
+		t.Errorf("Expected step to be failed, got %s", step.GetState().GetStatus())
+	}
+	if reason := step.GetState().(ErrorReasonState).GetErrorReason(); reason != StateErrorReasonTimeout {
+		t.Errorf("Expected error reason %q, got %q", StateErrorReasonTimeout, reason)
+	}
+}
+
+func Test_Step_Timeout_HandlerPanics(t *testing.T) {
+	step := NewStep(
+		WithTimeout(time.Second),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			panic("handler failed")
+		}),
+	)
+
+	defer func() {
+		if r := recover(); r != "handler failed" {
+			t.Errorf("Expected the panic of the handler in the calling goroutine, got %v", r)
+		}
+	}()
+
+	step.Run(context.Background(), map[string]any{})
+	t.Error("Expected Run to panic")
+}
+
+func Test_Step_NoTimeout_CanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Without a timeout, the handler is not abandoned when the context is canceled
+	step := NewStep(
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			cancel()
+			time.Sleep(10 * time.Millisecond)
+			data["done"] = true
+			return ctx, data, nil
+		}),
+	)
+
+	_, data, err := step.Run(ctx, map[string]any{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if data["done"] != true {
+		t.Error("Expected the result of the handler")
+	}
+	if !step.IsCompleted() {
+		t.Errorf("Expected step to be completed, got %s", step.GetState().GetStatus())
+	}
+}
+
+func Test_Step_Timeout_HandlerRespectsContext(t *testing.T) {
+	type ctxKey string
+
+	step := NewStep(
+		WithTimeout(time.Second),
+		WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				return ctx, data, errors.New("expected a deadline")
+			}
+			return context.WithValue(ctx, ctxKey("user"), "alice"), data, nil
+		}),
+	)
+
+	ctx, _, err := step.Run(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("The returned context should not carry the step's deadline")
+	}
+	if ctx.Value(ctxKey("user")) != "alice" {
+		t.Error("The returned context should keep the values added by the handler")
+	}
+}
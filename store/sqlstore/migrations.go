@@ -0,0 +1,74 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrations are the changes to the database schema, in order.
+// Applied migrations are recorded in the migrations table by their
+// index (starting at 1), so existing migrations must never be changed.
+var migrations = []string{
+	// 1. Runs table
+	`CREATE TABLE IF NOT EXISTS {table} (
+		run_id       VARCHAR(255) NOT NULL PRIMARY KEY,
+		status       VARCHAR(20)  NOT NULL,
+		state        TEXT         NOT NULL,
+		version      BIGINT       NOT NULL,
+		created_at   BIGINT       NOT NULL,
+		last_updated BIGINT       NOT NULL
+	)`,
+
+	// 2. Index to find runs by status and last update time
+	`CREATE INDEX IF NOT EXISTS {table}_status_last_updated ON {table} (status, last_updated)`,
+}
+
+// migrate applies the migrations that were not applied yet, each in its own transaction
+func (s *storeImplementation) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.query(`CREATE TABLE IF NOT EXISTS {table}_migrations (
+		version    INTEGER NOT NULL PRIMARY KEY,
+		applied_at BIGINT  NOT NULL
+	)`))
+	if err != nil {
+		return fmt.Errorf("sqlstore: creating migrations table: %w", err)
+	}
+
+	for i, migration := range migrations {
+		if err := s.applyMigration(ctx, i+1, migration); err != nil {
+			return fmt.Errorf("sqlstore: migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies a single migration, unless it was already applied
+func (s *storeImplementation) applyMigration(ctx context.Context, version int, migration string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	err = tx.QueryRowContext(ctx, s.query(`SELECT COUNT(*) FROM {table}_migrations WHERE version = ?`), version).Scan(&applied)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, s.query(migration)); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, s.query(`INSERT INTO {table}_migrations (version, applied_at) VALUES (?, ?)`), version, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,311 @@
+// Package sqlstore provides a wf.StateStore that keeps the states of workflow
+// runs in a SQL database, using database/sql.
+//
+// It is written for SQLite, e.g. with the pure-Go driver modernc.org/sqlite,
+// which needs no cgo, and works with any database using "?" placeholders.
+//
+// Every saved state has a version. Saving a state that was modified in the
+// database since it was loaded fails with wf.ErrStateConflict (optimistic
+// locking), so two workers cannot resume the same run.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dracory/wf"
+)
+
+// DefaultTableName is the name of the table the states are saved to
+const DefaultTableName = "wf_runs"
+
+// tableNamePattern matches the table names that are safe to use in queries
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// StoreInterface is a wf.StateStore backed by a SQL database,
+// which can also find runs by status and last update time
+type StoreInterface interface {
+	wf.StateStore
+
+	// FindRuns returns the runs matching the query, oldest update first
+	FindRuns(ctx context.Context, query RunQuery) ([]RunInfo, error)
+}
+
+// RunQuery filters the runs returned by FindRuns. Empty fields do not filter.
+//
+// Example, to find the runs paused or running for more than an hour:
+//   runs, err := store.FindRuns(ctx, sqlstore.RunQuery{
+//       Statuses:      []wf.StateStatus{wf.StateStatusPaused, wf.StateStatusRunning},
+//       UpdatedBefore: time.Now().Add(-time.Hour),
+//   })
+type RunQuery struct {
+	// Statuses the runs may have
+	Statuses []wf.StateStatus
+
+	// UpdatedBefore returns the runs last updated before the given time
+	UpdatedBefore time.Time
+
+	// UpdatedAfter returns the runs last updated after the given time
+	UpdatedAfter time.Time
+
+	// Limit is the maximum number of runs returned
+	Limit int
+}
+
+// RunInfo describes a saved run
+type RunInfo struct {
+	RunID       string
+	Status      wf.StateStatus
+	Version     int64
+	LastUpdated time.Time
+}
+
+// StoreOption configures a store
+type StoreOption func(*storeImplementation)
+
+// WithTableName sets the name of the table the states are saved to.
+// The migrations table is named after it, with the "_migrations" suffix.
+func WithTableName(name string) StoreOption {
+	return func(s *storeImplementation) {
+		s.tableName = name
+	}
+}
+
+type storeImplementation struct {
+	db        *sql.DB
+	tableName string
+}
+
+var _ StoreInterface = (*storeImplementation)(nil)
+
+// NewStore creates a state store using the given database,
+// and migrates the database schema to the latest version
+func NewStore(ctx context.Context, db *sql.DB, opts ...StoreOption) (StoreInterface, error) {
+	if db == nil {
+		return nil, errors.New("sqlstore: db is nil")
+	}
+
+	store := &storeImplementation{
+		db:        db,
+		tableName: DefaultTableName,
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	if !tableNamePattern.MatchString(store.tableName) {
+		return nil, fmt.Errorf("sqlstore: invalid table name %q", store.tableName)
+	}
+
+	if err := store.migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Save saves the state of the run with the given ID.
+//
+// A state that was never saved (version zero) is inserted, and fails with
+// wf.ErrStateConflict if the run already exists. Otherwise the state is
+// only updated if its version still matches the saved one.
+// The version of the state is incremented on success.
+//
+// A state that does not implement wf.VersionedState is saved without the
+// version check, replacing the saved one.
+func (s *storeImplementation) Save(ctx context.Context, runID string, state wf.StateInterface) error {
+	stateJSON, err := state.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	versioned, ok := state.(wf.VersionedState)
+	if !ok {
+		return s.replace(ctx, runID, state, stateJSON)
+	}
+
+	version := versioned.GetVersion()
+	lastUpdated := state.GetLastUpdated().UnixNano()
+
+	if version == 0 {
+		_, err := s.db.ExecContext(ctx, s.query(`INSERT INTO {table} (run_id, status, state, version, created_at, last_updated) VALUES (?, ?, ?, 1, ?, ?)`),
+			runID, string(state.GetStatus()), string(stateJSON), time.Now().UnixNano(), lastUpdated)
+		if err != nil {
+			if exists, existsErr := s.exists(ctx, runID); existsErr == nil && exists {
+				return fmt.Errorf("%w: run %s already exists", wf.ErrStateConflict, runID)
+			}
+			return err
+		}
+
+		versioned.SetVersion(1)
+		return nil
+	}
+
+	result, err := s.db.ExecContext(ctx, s.query(`UPDATE {table} SET status = ?, state = ?, version = version + 1, last_updated = ? WHERE run_id = ? AND version = ?`),
+		string(state.GetStatus()), string(stateJSON), lastUpdated, runID, version)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return fmt.Errorf("%w: run %s is not at version %d", wf.ErrStateConflict, runID, version)
+	}
+
+	versioned.SetVersion(version + 1)
+	return nil
+}
+
+// replace saves a state without checking its version,
+// inserting the run if it was never saved
+func (s *storeImplementation) replace(ctx context.Context, runID string, state wf.StateInterface, stateJSON []byte) error {
+	lastUpdated := state.GetLastUpdated().UnixNano()
+
+	result, err := s.db.ExecContext(ctx, s.query(`UPDATE {table} SET status = ?, state = ?, version = version + 1, last_updated = ? WHERE run_id = ?`),
+		string(state.GetStatus()), string(stateJSON), lastUpdated, runID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated > 0 {
+		return nil
+	}
+
+	_, err = s.db.ExecContext(ctx, s.query(`INSERT INTO {table} (run_id, status, state, version, created_at, last_updated) VALUES (?, ?, ?, 1, ?, ?)`),
+		runID, string(state.GetStatus()), string(stateJSON), time.Now().UnixNano(), lastUpdated)
+	return err
+}
+
+// Load returns the state of the run with the given ID, with its saved version
+func (s *storeImplementation) Load(ctx context.Context, runID string) (wf.StateInterface, error) {
+	var stateJSON string
+	var version int64
+
+	err := s.db.QueryRowContext(ctx, s.query(`SELECT state, version FROM {table} WHERE run_id = ?`), runID).Scan(&stateJSON, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, wf.ErrStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := wf.NewState()
+	if err := state.FromJSON([]byte(stateJSON)); err != nil {
+		return nil, err
+	}
+	if versioned, ok := state.(wf.VersionedState); ok {
+		versioned.SetVersion(version)
+	}
+
+	return state, nil
+}
+
+// List returns the IDs of the saved runs, sorted
+func (s *storeImplementation) List(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT run_id FROM {table} ORDER BY run_id`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runIDs := []string{}
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+
+	return runIDs, rows.Err()
+}
+
+// Delete deletes the state of the run with the given ID
+func (s *storeImplementation) Delete(ctx context.Context, runID string) error {
+	_, err := s.db.ExecContext(ctx, s.query(`DELETE FROM {table} WHERE run_id = ?`), runID)
+	return err
+}
+
+// FindRuns returns the runs matching the query, oldest update first
+func (s *storeImplementation) FindRuns(ctx context.Context, query RunQuery) ([]RunInfo, error) {
+	conditions := []string{}
+	args := []any{}
+
+	if len(query.Statuses) > 0 {
+		placeholders := make([]string, 0, len(query.Statuses))
+		for _, status := range query.Statuses {
+			placeholders = append(placeholders, "?")
+			args = append(args, string(status))
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	if !query.UpdatedBefore.IsZero() {
+		conditions = append(conditions, "last_updated < ?")
+		args = append(args, query.UpdatedBefore.UnixNano())
+	}
+
+	if !query.UpdatedAfter.IsZero() {
+		conditions = append(conditions, "last_updated > ?")
+		args = append(args, query.UpdatedAfter.UnixNano())
+	}
+
+	sqlQuery := `SELECT run_id, status, version, last_updated FROM {table}`
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY last_updated, run_id"
+
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.query(sqlQuery), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []RunInfo{}
+	for rows.Next() {
+		var run RunInfo
+		var status string
+		var lastUpdated int64
+		if err := rows.Scan(&run.RunID, &status, &run.Version, &lastUpdated); err != nil {
+			return nil, err
+		}
+		run.Status = wf.StateStatus(status)
+		run.LastUpdated = time.Unix(0, lastUpdated)
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// exists checks whether a run with the given ID is saved
+func (s *storeImplementation) exists(ctx context.Context, runID string) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, s.query(`SELECT COUNT(*) FROM {table} WHERE run_id = ?`), runID).Scan(&count)
+	return count > 0, err
+}
+
+// query replaces the {table} placeholder with the name of the table
+func (s *storeImplementation) query(query string) string {
+	return strings.ReplaceAll(query, "{table}", s.tableName)
+}
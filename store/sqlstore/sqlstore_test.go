@@ -0,0 +1,331 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dracory/wf"
+	_ "modernc.org/sqlite"
+)
+
+func newTestStore(t *testing.T) (StoreInterface, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "wf.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewStore(context.Background(), db)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	return store, db
+}
+
+func Test_Store_SaveLoadListDelete(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, wf.ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound, got %v", err)
+	}
+
+	state := wf.NewState()
+	state.SetWorkflowData(map[string]any{"key": "value"})
+	state.AddCompletedStep("step1")
+
+	if err := store.Save(ctx, "run1", state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if state.(wf.VersionedState).GetVersion() != 1 {
+		t.Errorf("Expected version 1 after the first save, got %d", state.(wf.VersionedState).GetVersion())
+	}
+
+	// Checkpoints of the same state update the saved run
+	state.AddCompletedStep("step2")
+	if err := store.Save(ctx, "run1", state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := store.Load(ctx, "run1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.(wf.VersionedState).GetVersion() != 2 {
+		t.Errorf("Expected version 2, got %d", loaded.(wf.VersionedState).GetVersion())
+	}
+	if !slices.Equal(loaded.GetCompletedSteps(), []string{"step1", "step2"}) {
+		t.Errorf("Expected completed steps [step1 step2], got %v", loaded.GetCompletedSteps())
+	}
+	if loaded.GetWorkflowData()["key"] != "value" {
+		t.Errorf("Expected the workflow data to be saved, got %v", loaded.GetWorkflowData())
+	}
+
+	if err := store.Save(ctx, "run0", wf.NewState()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	runIDs, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !slices.Equal(runIDs, []string{"run0", "run1"}) {
+		t.Errorf("Expected run IDs [run0 run1], got %v", runIDs)
+	}
+
+	if err := store.Delete(ctx, "run1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Load(ctx, "run1"); !errors.Is(err, wf.ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound after Delete, got %v", err)
+	}
+}
+
+func Test_Store_OptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	if err := store.Save(ctx, "run", wf.NewState()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new state cannot replace an existing run
+	if err := store.Save(ctx, "run", wf.NewState()); !errors.Is(err, wf.ErrStateConflict) {
+		t.Errorf("Expected ErrStateConflict when inserting an existing run, got %v", err)
+	}
+
+	// Two workers load the same run
+	worker1, err := store.Load(ctx, "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker2, err := store.Load(ctx, "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(ctx, "run", worker1); err != nil {
+		t.Fatalf("The first save should succeed, got %v", err)
+	}
+	if err := store.Save(ctx, "run", worker2); !errors.Is(err, wf.ErrStateConflict) {
+		t.Errorf("Expected ErrStateConflict for the second worker, got %v", err)
+	}
+}
+
+// unversionedState is a state that does not implement wf.VersionedState
+type unversionedState struct {
+	wf.StateInterface
+}
+
+func Test_Store_Save_Unversioned(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	state := unversionedState{wf.NewState()}
+	state.AddCompletedStep("step1")
+	if err := store.Save(ctx, "run", state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Saving again replaces the saved state, without a version check
+	state.AddCompletedStep("step2")
+	if err := store.Save(ctx, "run", state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := store.Load(ctx, "run")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !slices.Equal(loaded.GetCompletedSteps(), []string{"step1", "step2"}) {
+		t.Errorf("Expected completed steps [step1 step2], got %v", loaded.GetCompletedSteps())
+	}
+	if loaded.(wf.VersionedState).GetVersion() != 2 {
+		t.Errorf("Expected version 2, got %d", loaded.(wf.VersionedState).GetVersion())
+	}
+}
+
+func Test_Store_ResumeRun_OnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	var calls atomic.Int32
+	step := wf.NewStep(wf.WithID("step"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		calls.Add(1)
+		return ctx, data, nil
+	}))
+
+	pipeline := wf.NewPipeline(wf.WithRunnables(step), wf.WithStateStore(store))
+
+	// A run interrupted while running
+	if err := store.Save(ctx, "run", wf.NewState()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two workers resume the same run
+	run1, err := pipeline.ResumeRun(ctx, "run", nil)
+	if err != nil {
+		t.Fatalf("ResumeRun failed: %v", err)
+	}
+	run2, err := pipeline.ResumeRun(ctx, "run", nil)
+	if err != nil {
+		t.Fatalf("ResumeRun failed: %v", err)
+	}
+
+	// The first worker lost the run to the second one
+	err = run1.Execute()
+	if !errors.Is(err, wf.ErrStateConflict) {
+		t.Errorf("Expected ErrStateConflict for the first worker, got %v", err)
+	}
+	if err != nil && strings.Count(err.Error(), "checkpoint failed") != 1 {
+		t.Errorf("Expected the checkpoint failure once, got %v", err)
+	}
+
+	if err := run2.Execute(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	saved, err := store.Load(ctx, "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.GetStatus() != wf.StateStatusComplete {
+		t.Errorf("Expected the saved run to be completed, got %s", saved.GetStatus())
+	}
+
+	// A finished run is not resumed, and not saved again
+	if _, err := pipeline.ResumeRun(ctx, "run", nil); err == nil {
+		t.Error("Expected an error when resuming a finished run")
+	}
+	reloaded, err := store.Load(ctx, "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reloaded.(wf.VersionedState).GetVersion(), saved.(wf.VersionedState).GetVersion(); got != want {
+		t.Errorf("Expected the finished run to stay at version %d, got %d", want, got)
+	}
+}
+
+func Test_Store_FindRuns(t *testing.T) {
+	ctx := context.Background()
+	store, db := newTestStore(t)
+
+	now := time.Now()
+	runs := []struct {
+		id          string
+		status      wf.StateStatus
+		lastUpdated time.Time
+	}{
+		{"old-paused", wf.StateStatusPaused, now.Add(-2 * time.Hour)},
+		{"old-running", wf.StateStatusRunning, now.Add(-3 * time.Hour)},
+		{"old-complete", wf.StateStatusComplete, now.Add(-2 * time.Hour)},
+		{"new-running", wf.StateStatusRunning, now},
+	}
+
+	for _, run := range runs {
+		state := wf.NewState()
+		state.SetStatus(run.status)
+		if err := store.Save(ctx, run.id, state); err != nil {
+			t.Fatal(err)
+		}
+
+		// Saving records the current time, so move the update time to the past
+		if _, err := db.Exec(`UPDATE wf_runs SET last_updated = ? WHERE run_id = ?`, run.lastUpdated.UnixNano(), run.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stale, err := store.FindRuns(ctx, RunQuery{
+		Statuses:      []wf.StateStatus{wf.StateStatusPaused, wf.StateStatusRunning},
+		UpdatedBefore: now.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("FindRuns failed: %v", err)
+	}
+
+	ids := []string{}
+	for _, run := range stale {
+		ids = append(ids, run.RunID)
+	}
+	if !slices.Equal(ids, []string{"old-running", "old-paused"}) {
+		t.Fatalf("Expected [old-running old-paused], oldest first, got %v", ids)
+	}
+	if stale[0].Status != wf.StateStatusRunning || stale[0].Version != 1 {
+		t.Errorf("Unexpected run info %+v", stale[0])
+	}
+
+	limited, err := store.FindRuns(ctx, RunQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("FindRuns failed: %v", err)
+	}
+	if len(limited) != 1 {
+		t.Errorf("Expected 1 run, got %d", len(limited))
+	}
+}
+
+func Test_Store_Migrations(t *testing.T) {
+	ctx := context.Background()
+	_, db := newTestStore(t)
+
+	// Creating the store again does not apply the migrations twice
+	if _, err := NewStore(ctx, db); err != nil {
+		t.Fatalf("NewStore failed on a migrated database: %v", err)
+	}
+
+	var applied int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM wf_runs_migrations`).Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Expected %d applied migrations, got %d", len(migrations), applied)
+	}
+
+	if _, err := NewStore(ctx, db, WithTableName("runs; DROP TABLE wf_runs")); err == nil {
+		t.Error("Expected an error for an invalid table name")
+	}
+}
+
+func Test_Store_Dag_RunTwice(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	var calls atomic.Int32
+	step := wf.NewStep(wf.WithID("step"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		calls.Add(1)
+		return ctx, data, nil
+	}))
+
+	dag := wf.NewDag(wf.WithID("dag"), wf.WithRunnables(step), wf.WithStateStore(store))
+
+	// Every Run of the definition replaces the saved state of the previous one
+	for i := range 2 {
+		if _, _, err := dag.Run(ctx, map[string]any{}); err != nil {
+			t.Fatalf("Run %d failed: %v", i+1, err)
+		}
+		if !dag.IsCompleted() {
+			t.Errorf("Expected run %d to be completed, got %s", i+1, dag.GetState().GetStatus())
+		}
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("Expected the step to run twice, got %d", calls.Load())
+	}
+
+	saved, err := store.Load(ctx, "dag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.GetStatus() != wf.StateStatusComplete {
+		t.Errorf("Expected the saved run to be completed, got %s", saved.GetStatus())
+	}
+}
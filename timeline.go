@@ -0,0 +1,413 @@
+package wf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Timeline is the execution of a run, node by node, built from the start and
+// end times recorded in its state. It is exported as a Gantt chart with
+// ToMermaid, as a trace with ToTraceJSON, or as a web page with ToHTML.
+type Timeline struct {
+	// ID and Name of the root of the run
+	ID   string
+	Name string
+
+	Status    StateStatus
+	StartedAt time.Time
+	EndedAt   time.Time
+
+	// Entries are the nodes that ran, nested ones included, each followed
+	// by its own nodes, in the order they started
+	Entries []*TimelineEntry
+}
+
+// TimelineEntry is the execution of a node of a run
+type TimelineEntry struct {
+	ID   string
+	Name string
+
+	// Path holds the IDs of the nodes from the root of the run down to the node, e.g. [dag pipeline step]
+	Path []string
+
+	Status    StateStatus
+	StartedAt time.Time
+	EndedAt   time.Time
+	Attempts  int
+	Error     string
+
+	// Critical is true for the nodes of the critical path: the chain of nodes,
+	// each waiting for the one before it, that ended last
+	Critical bool
+}
+
+// NewTimeline returns the timeline of a run of a Pipeline or Dag, from its state:
+// the state of the workflow after Run, or the state of a run loaded from a StateStore.
+//
+// Example:
+//   _, _, err := dag.Run(ctx, data)
+//   timeline := wf.NewTimeline(dag, dag.GetState())
+//   fmt.Println(timeline.ToMermaid())
+func NewTimeline(node RunnableInterface, state StateInterface) *Timeline {
+	timeline := &Timeline{
+		ID:      node.GetID(),
+		Name:    node.GetName(),
+		Entries: []*TimelineEntry{},
+	}
+
+	if state == nil {
+		return timeline
+	}
+
+	timeline.Status = state.GetStatus()
+	timeline.StartedAt = stateStartedAt(state)
+	timeline.EndedAt = stateEndedAt(state)
+	timeline.addNodes(node, state, []string{node.GetID()}, true)
+
+	return timeline
+}
+
+// addNodes adds the entries of the nodes of a Pipeline or Dag that ran, and marks
+// its critical path, if the Pipeline or Dag is on the critical path of its parent
+func (t *Timeline) addNodes(node RunnableInterface, state StateInterface, path []string, critical bool) {
+	var nodes []RunnableInterface
+	dependencies := map[string][]string{}
+
+	switch n := node.(type) {
+	case *Dag:
+		for _, id := range n.runnableSequence {
+			if child, ok := n.findNode(id); ok {
+				nodes = append(nodes, child)
+			}
+		}
+		dependencies = n.dependencyIDs()
+	case *pipelineImplementation:
+		nodes = n.nodes
+		for i := 1; i < len(nodes); i++ {
+			dependencies[nodes[i].GetID()] = []string{nodes[i-1].GetID()}
+		}
+	default:
+		return
+	}
+
+	entries := map[string]*TimelineEntry{}
+	ordered := []*TimelineEntry{}
+	children := map[string]RunnableInterface{}
+	for _, child := range nodes {
+		childState := stateChild(state, child.GetID())
+		if childState == nil || stateStartedAt(childState).IsZero() {
+			continue
+		}
+
+		entry := &TimelineEntry{
+			ID:        child.GetID(),
+			Name:      child.GetName(),
+			Path:      append(append([]string{}, path...), child.GetID()),
+			Status:    childState.GetStatus(),
+			StartedAt: stateStartedAt(childState),
+			EndedAt:   stateEndedAt(childState),
+			Attempts:  stateAttempts(childState),
+			Error:     stateLastError(childState),
+		}
+		entries[entry.ID] = entry
+		ordered = append(ordered, entry)
+		children[entry.ID] = child
+	}
+
+	if critical {
+		markCriticalPath(ordered, entries, dependencies)
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].StartedAt.Before(ordered[j].StartedAt)
+	})
+
+	for _, entry := range ordered {
+		t.Entries = append(t.Entries, entry)
+		t.addNodes(children[entry.ID], stateChild(state, entry.ID), entry.Path, entry.Critical)
+	}
+}
+
+// markCriticalPath marks the node that ended last, then, going back, the
+// dependency that ended last of each marked node, as it is the one the node
+// waited for
+func markCriticalPath(ordered []*TimelineEntry, entries map[string]*TimelineEntry, dependencies map[string][]string) {
+	var last *TimelineEntry
+	for _, entry := range ordered {
+		if last == nil || entry.EndedAt.After(last.EndedAt) {
+			last = entry
+		}
+	}
+
+	for last != nil && !last.Critical {
+		last.Critical = true
+
+		var previous *TimelineEntry
+		for _, id := range dependencies[last.ID] {
+			if entry, ok := entries[id]; ok && (previous == nil || entry.EndedAt.After(previous.EndedAt)) {
+				previous = entry
+			}
+		}
+		last = previous
+	}
+}
+
+// Duration returns the time the node ran
+func (e *TimelineEntry) Duration() time.Duration {
+	if e.EndedAt.Before(e.StartedAt) {
+		return 0
+	}
+	return e.EndedAt.Sub(e.StartedAt)
+}
+
+// Duration returns the time the run took
+func (t *Timeline) Duration() time.Duration {
+	if t.EndedAt.Before(t.StartedAt) {
+		return 0
+	}
+	return t.EndedAt.Sub(t.StartedAt)
+}
+
+// offset returns the time from the start of the run to the given time
+func (t *Timeline) offset(at time.Time) time.Duration {
+	return at.Sub(t.StartedAt)
+}
+
+// label returns the name of the node, with its status and attempts, e.g. "Charge (failed, 3 attempts)"
+func (e *TimelineEntry) label() string {
+	label := fmt.Sprintf("%s (%s", e.Name, e.Status)
+	if e.Attempts > 1 {
+		label += fmt.Sprintf(", %d attempts", e.Attempts)
+	}
+	return label + ")"
+}
+
+// mermaidGanttEscaper removes the characters that end a Mermaid Gantt task name
+var mermaidGanttEscaper = strings.NewReplacer(
+	":", "",
+	";", ",",
+	"#", "",
+	"\r", "",
+	"\n", " ",
+)
+
+// ToMermaid returns the timeline as a Mermaid Gantt chart, with a section for
+// each node of the root. The times are in milliseconds from the start of the
+// run, the completed nodes are drawn as done, and the critical path as critical.
+func (t *Timeline) ToMermaid() string {
+	var sb strings.Builder
+	sb.WriteString("gantt\n")
+	fmt.Fprintf(&sb, "    title %s\n", mermaidGanttEscaper.Replace(t.Name))
+	sb.WriteString("    dateFormat x\n")
+	sb.WriteString("    axisFormat %M:%S.%L\n")
+
+	for i, entry := range t.Entries {
+		if len(entry.Path) == 2 {
+			fmt.Fprintf(&sb, "    section %s\n", mermaidGanttEscaper.Replace(entry.Name))
+		}
+
+		tags := []string{}
+		if entry.Critical {
+			tags = append(tags, "crit")
+		}
+		switch entry.Status {
+		case StateStatusComplete, StateStatusSkipped:
+			tags = append(tags, "done")
+		case StateStatusRunning, StateStatusPaused:
+			tags = append(tags, "active")
+		}
+
+		start := t.offset(entry.StartedAt).Milliseconds()
+		end := max(t.offset(entry.EndedAt).Milliseconds(), start+1)
+
+		fmt.Fprintf(&sb, "    %s%s :%s, %d, %d\n",
+			strings.Repeat("  ", len(entry.Path)-2),
+			mermaidGanttEscaper.Replace(entry.label()),
+			strings.Join(append(tags, fmt.Sprintf("t%d", i+1)), ", "),
+			start,
+			end,
+		)
+	}
+
+	return sb.String()
+}
+
+// traceEvent is a complete event of the Chrome trace event format
+type traceEvent struct {
+	Name     string         `json:"name"`
+	Category string         `json:"cat"`
+	Phase    string         `json:"ph"`
+	Time     int64          `json:"ts"`  // Microseconds from the start of the run
+	Duration int64          `json:"dur"` // Microseconds
+	Process  int            `json:"pid"`
+	Thread   int            `json:"tid"`
+	Args     map[string]any `json:"args,omitempty"`
+}
+
+// ToTraceJSON returns the timeline in the Chrome trace event format, which
+// can be opened in chrome://tracing or Perfetto. The nodes running at the same
+// time are drawn on separate rows, and the nodes of the critical path have
+// the "critical" category.
+func (t *Timeline) ToTraceJSON() ([]byte, error) {
+	events := []traceEvent{{
+		Name:     t.Name,
+		Category: "workflow",
+		Phase:    "X",
+		Duration: t.Duration().Microseconds(),
+		Process:  1,
+		Args:     map[string]any{"id": t.ID, "status": t.Status},
+	}}
+
+	// Each row ends when its last node ended
+	rows := []time.Time{t.EndedAt}
+	for _, entry := range t.Entries {
+		row := len(rows)
+		for i, endedAt := range rows {
+			if !endedAt.After(entry.StartedAt) {
+				row = i
+				break
+			}
+		}
+		if row == len(rows) {
+			rows = append(rows, entry.EndedAt)
+		} else {
+			rows[row] = entry.EndedAt
+		}
+
+		category := "node"
+		if entry.Critical {
+			category = "node,critical"
+		}
+
+		args := map[string]any{
+			"path":     strings.Join(entry.Path, "/"),
+			"status":   entry.Status,
+			"attempts": entry.Attempts,
+			"critical": entry.Critical,
+		}
+		if entry.Error != "" {
+			args["error"] = entry.Error
+		}
+
+		events = append(events, traceEvent{
+			Name:     entry.Name,
+			Category: category,
+			Phase:    "X",
+			Time:     t.offset(entry.StartedAt).Microseconds(),
+			Duration: entry.Duration().Microseconds(),
+			Process:  1,
+			Thread:   row,
+			Args:     args,
+		})
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	}, "", "  ")
+}
+
+// timelineTemplate is the web page of ToHTML, with no external resources
+var timelineTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: Arial, sans-serif; margin: 24px; color: #212121; }
+.row { display: flex; align-items: center; height: 28px; }
+.label { width: 320px; flex: none; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; font-size: 14px; }
+.track { position: relative; flex: auto; height: 20px; background: #F5F5F5; }
+.bar { position: absolute; top: 2px; height: 16px; min-width: 2px; border-radius: 2px; }
+.critical { outline: 2px solid #212121; }
+.critical-label { font-weight: bold; }
+.legend span { display: inline-block; margin-right: 16px; font-size: 13px; }
+.legend i { display: inline-block; width: 12px; height: 12px; margin-right: 4px; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{.Status}} in {{.Duration}}</p>
+<p class="legend">
+{{range .Legend}}<span><i style="background: {{.Color}}"></i>{{.Status}}</span>
+{{end}}<span><i class="critical"></i>critical path</span>
+</p>
+{{range .Rows}}<div class="row">
+<div class="label{{if .Critical}} critical-label{{end}}" style="padding-left: {{.Indent}}px" title="{{.Path}}">{{.Label}}</div>
+<div class="track"><div class="bar{{if .Critical}} critical{{end}}" style="left: {{.Left}}%; width: {{.Width}}%; background: {{.Color}}" title="{{.Title}}"></div></div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// timelineStatuses are the statuses of the nodes, in the order of the legend of ToHTML
+var timelineStatuses = []StateStatus{StateStatusComplete, StateStatusFailed, StateStatusPaused, StateStatusRunning, StateStatusSkipped}
+
+// timelineRow is a row of the web page of ToHTML
+type timelineRow struct {
+	Label    string
+	Path     string
+	Title    string
+	Indent   int
+	Left     string
+	Width    string
+	Color    string
+	Critical bool
+}
+
+// ToHTML returns the timeline as a self-contained web page, with a bar for
+// each node, colored by its status like in Visualize, and the critical path
+// outlined.
+func (t *Timeline) ToHTML() (string, error) {
+	total := float64(t.Duration())
+	percent := func(d time.Duration) string {
+		if total <= 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%.2f", float64(d)/total*100)
+	}
+
+	rows := []timelineRow{}
+	for _, entry := range t.Entries {
+		title := fmt.Sprintf("%s\nstatus: %s\nstart: +%s\nduration: %s\nattempts: %d",
+			entry.Name, entry.Status, t.offset(entry.StartedAt), entry.Duration(), entry.Attempts)
+		if entry.Error != "" {
+			title += "\nerror: " + entry.Error
+		}
+
+		rows = append(rows, timelineRow{
+			Label:    entry.label(),
+			Path:     strings.Join(entry.Path, "/"),
+			Title:    title,
+			Indent:   16 * (len(entry.Path) - 2),
+			Left:     percent(t.offset(entry.StartedAt)),
+			Width:    percent(entry.Duration()),
+			Color:    StatusColor(entry.Status),
+			Critical: entry.Critical,
+		})
+	}
+
+	legend := []map[string]any{}
+	for _, status := range timelineStatuses {
+		legend = append(legend, map[string]any{"Status": status, "Color": StatusColor(status)})
+	}
+
+	var buffer bytes.Buffer
+	err := timelineTemplate.Execute(&buffer, map[string]any{
+		"Name":     t.Name,
+		"Status":   t.Status,
+		"Duration": t.Duration(),
+		"Legend":   legend,
+		"Rows":     rows,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
@@ -0,0 +1,246 @@
+package wf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTimelineTestDag returns a run DAG, with the times of its nodes set to:
+//
+//	validate  0-10ms
+//	stock     0-30ms
+//	payment   10-25ms (charge 10-20ms, with 2 attempts, then receipt 20-25ms)
+//	ship      30-40ms, after validate, stock and payment
+func newTimelineTestDag(t *testing.T) (DagInterface, time.Time) {
+	t.Helper()
+
+	newStep := func(id string, name string) StepInterface {
+		return NewStep(WithID(id), WithName(name), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			return ctx, data, nil
+		}))
+	}
+
+	attempts := 0
+	charge := NewStep(WithID("charge"), WithName("Charge: card"), WithRetry(RetryPolicy{MaxAttempts: 2}), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		attempts++
+		if attempts == 1 {
+			return ctx, data, errors.New("temporary failure")
+		}
+		return ctx, data, nil
+	}))
+
+	validate := newStep("validate", "Validate")
+	stock := newStep("stock", "Stock")
+	payment := NewPipeline(WithID("payment"), WithName("Payment"), WithRunnables(charge, newStep("receipt", "Receipt")))
+	ship := newStep("ship", "Ship")
+
+	dag := NewDag(
+		WithID("order"),
+		WithName("Order"),
+		WithRunnables(validate, stock, payment, ship),
+		WithDependency(payment, validate),
+		WithDependency(ship, validate, stock, payment),
+	)
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	setTimes := func(state StateInterface, from int, to int) {
+		state.(TimedState).SetStartedAt(start.Add(time.Duration(from) * time.Millisecond))
+		state.(TimedState).SetEndedAt(start.Add(time.Duration(to) * time.Millisecond))
+	}
+
+	state := dag.GetState()
+	paymentState := state.(ParentState).GetChildState("payment")
+	setTimes(state, 0, 40)
+	setTimes(state.(ParentState).GetChildState("validate"), 0, 10)
+	setTimes(state.(ParentState).GetChildState("stock"), 0, 30)
+	setTimes(paymentState, 10, 25)
+	setTimes(paymentState.(ParentState).GetChildState("charge"), 10, 20)
+	setTimes(paymentState.(ParentState).GetChildState("receipt"), 20, 25)
+	setTimes(state.(ParentState).GetChildState("ship"), 30, 40)
+
+	return dag, start
+}
+
+func Test_Run_RecordsTimes(t *testing.T) {
+	step := NewStep(WithID("step"), WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	pipeline := NewPipeline(WithRunnables(step))
+
+	before := time.Now()
+	if _, _, err := pipeline.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	after := time.Now()
+
+	for name, state := range map[string]TimedState{"pipeline": pipeline.GetState().(TimedState), "step": pipeline.GetState().(ParentState).GetChildState("step").(TimedState)} {
+		if state.GetStartedAt().Before(before) || state.GetEndedAt().Before(state.GetStartedAt()) || state.GetEndedAt().After(after) {
+			t.Errorf("Unexpected times of the %s: %v to %v", name, state.GetStartedAt(), state.GetEndedAt())
+		}
+	}
+}
+
+func Test_Timeline(t *testing.T) {
+	dag, start := newTimelineTestDag(t)
+
+	timeline := NewTimeline(dag, dag.GetState())
+
+	if timeline.ID != "order" || timeline.Status != StateStatusComplete || !timeline.StartedAt.Equal(start) || timeline.Duration() != 40*time.Millisecond {
+		t.Errorf("Unexpected timeline: %+v", timeline)
+	}
+
+	expected := []struct {
+		path     string
+		attempts int
+		critical bool
+	}{
+		{"order/validate", 1, false},
+		{"order/stock", 1, true},
+		{"order/payment", 0, false},
+		{"order/payment/charge", 2, false},
+		{"order/payment/receipt", 1, false},
+		{"order/ship", 1, true},
+	}
+	if len(timeline.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(timeline.Entries))
+	}
+	for i, entry := range timeline.Entries {
+		if path := strings.Join(entry.Path, "/"); path != expected[i].path || entry.Attempts != expected[i].attempts || entry.Critical != expected[i].critical {
+			t.Errorf("Entry %d: expected %+v, got %s with %d attempts, critical %v", i, expected[i], path, entry.Attempts, entry.Critical)
+		}
+	}
+
+	if timeline.Entries[3].Duration() != 10*time.Millisecond {
+		t.Errorf("Expected a duration of 10ms, got %v", timeline.Entries[3].Duration())
+	}
+}
+
+func Test_Timeline_CriticalPathNested(t *testing.T) {
+	dag, start := newTimelineTestDag(t)
+
+	// The payment ends last, so the ship waited for it
+	state := dag.GetState()
+	state.(ParentState).GetChildState("stock").(TimedState).SetEndedAt(start.Add(5 * time.Millisecond))
+	state.(ParentState).GetChildState("payment").(TimedState).SetEndedAt(start.Add(28 * time.Millisecond))
+
+	critical := []string{}
+	for _, entry := range NewTimeline(dag, state).Entries {
+		if entry.Critical {
+			critical = append(critical, entry.ID)
+		}
+	}
+
+	if strings.Join(critical, ",") != "validate,payment,charge,receipt,ship" {
+		t.Errorf("Unexpected critical path: %v", critical)
+	}
+}
+
+func Test_Timeline_NotRun(t *testing.T) {
+	dag := NewDag(WithName("Empty"))
+
+	timeline := NewTimeline(dag, nil)
+	if timeline.Name != "Empty" || len(timeline.Entries) != 0 {
+		t.Errorf("Unexpected timeline: %+v", timeline)
+	}
+	if timeline.ToMermaid() == "" {
+		t.Error("Expected a chart without tasks")
+	}
+}
+
+func Test_Timeline_ToMermaid(t *testing.T) {
+	dag, _ := newTimelineTestDag(t)
+
+	expected := `gantt
+    title Order
+    dateFormat x
+    axisFormat %M:%S.%L
+    section Validate
+    Validate (complete) :done, t1, 0, 10
+    section Stock
+    Stock (complete) :crit, done, t2, 0, 30
+    section Payment
+    Payment (complete) :done, t3, 10, 25
+      Charge card (complete, 2 attempts) :done, t4, 10, 20
+      Receipt (complete) :done, t5, 20, 25
+    section Ship
+    Ship (complete) :crit, done, t6, 30, 40
+`
+	if mermaid := NewTimeline(dag, dag.GetState()).ToMermaid(); mermaid != expected {
+		t.Errorf("Unexpected Gantt chart.\nExpected:\n%s\nGot:\n%s", expected, mermaid)
+	}
+}
+
+func Test_Timeline_ToTraceJSON(t *testing.T) {
+	dag, _ := newTimelineTestDag(t)
+
+	data, err := NewTimeline(dag, dag.GetState()).ToTraceJSON()
+	if err != nil {
+		t.Fatalf("ToTraceJSON failed: %v", err)
+	}
+
+	trace := struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}{}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+
+	if len(trace.TraceEvents) != 7 {
+		t.Fatalf("Expected 7 events, got %d", len(trace.TraceEvents))
+	}
+
+	events := map[string]traceEvent{}
+	for _, event := range trace.TraceEvents {
+		events[event.Name] = event
+	}
+
+	if root := events["Order"]; root.Category != "workflow" || root.Duration != 40000 {
+		t.Errorf("Unexpected workflow event: %+v", root)
+	}
+
+	charge := events["Charge: card"]
+	if charge.Phase != "X" || charge.Time != 10000 || charge.Duration != 10000 || charge.Args["attempts"] != float64(2) || charge.Args["path"] != "order/payment/charge" {
+		t.Errorf("Unexpected charge event: %+v", charge)
+	}
+
+	if stock := events["Stock"]; stock.Category != "node,critical" {
+		t.Errorf("Expected the stock on the critical path, got %+v", stock)
+	}
+
+	// The nodes running at the same time are on separate rows
+	if events["Validate"].Thread == events["Stock"].Thread || events["Stock"].Thread == events["Payment"].Thread || events["Payment"].Thread == events["Charge: card"].Thread {
+		t.Errorf("Expected the parallel nodes on separate rows, got %+v", events)
+	}
+	if events["Ship"].Thread != events["Validate"].Thread {
+		t.Errorf("Expected the ship on the free row of the validate, got %+v", events)
+	}
+}
+
+func Test_Timeline_ToHTML(t *testing.T) {
+	dag, _ := newTimelineTestDag(t)
+
+	page, err := NewTimeline(dag, dag.GetState()).ToHTML()
+	if err != nil {
+		t.Fatalf("ToHTML failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"<title>Order</title>",
+		"<p>complete in 40ms</p>",
+		`<div class="label critical-label" style="padding-left: 0px" title="order/stock">Stock (complete)</div>`,
+		`<div class="bar critical" style="left: 0.00%; width: 75.00%; background: #4CAF50"`,
+		`style="padding-left: 16px" title="order/payment/charge">Charge: card (complete, 2 attempts)</div>`,
+		`<div class="bar" style="left: 25.00%; width: 25.00%; background: #4CAF50"`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, page)
+		}
+	}
+}
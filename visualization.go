@@ -16,18 +16,39 @@ const (
 	colorBlue   = "#2196F3" // Running status
 	colorGreen  = "#4CAF50" // Completed status/edges
 	colorGrey   = "#9E9E9E" // Default edge, fallback fill
+	colorSilver = "#E0E0E0" // Skipped status
 )
 
+// StatusColor returns the color of a status in the graphs and timelines,
+// so other views of the runs (e.g. web pages) can use the same colors
+func StatusColor(status StateStatus) string {
+	switch status {
+	case StateStatusComplete:
+		return colorGreen
+	case StateStatusFailed:
+		return colorRed
+	case StateStatusPaused:
+		return colorYellow
+	case StateStatusRunning:
+		return colorBlue
+	case StateStatusSkipped:
+		return colorSilver
+	default:
+		return colorGrey
+	}
+}
+
 // Node style constants
 const (
-	nodeStyleSolid  = "solid"
-	nodeStyleFilled = "filled"
+	nodeStyleSolid        = "solid"
+	nodeStyleFilled       = "filled"
+	nodeStyleFilledDashed = "filled,dashed" // Skipped nodes
 )
 
 // Edge style constants
 const (
-	edgeStyleSolid = "solid"
-	// edgeStyleDashed = "dashed" // Example if needed later
+	edgeStyleSolid  = "solid"
+	edgeStyleDashed = "dashed" // Conditional dependencies
 )
 
 // --- Structs (DotNodeSpec, DotEdgeSpec) remain the same ---
@@ -38,7 +59,7 @@ type DotNodeSpec struct {
 	DisplayName string
 	Tooltip     string
 	Shape       string
-	Style       string // Use nodeStyleSolid or nodeStyleFilled
+	Style       string // Use nodeStyleSolid, nodeStyleFilled or nodeStyleFilledDashed
 	FillColor   string
 	// FontColor is handled conditionally by dotTemplateFuncs
 }
@@ -52,18 +73,36 @@ type DotEdgeSpec struct {
 	Color        string
 }
 
+// DotClusterSpec represents a nested pipeline or DAG in the DOT graph,
+// drawn as a cluster around its nodes (see VisualizeExpanded)
+type DotClusterSpec struct {
+	Name     string // Unique name, starting with "cluster" as required by DOT
+	Label    string
+	Tooltip  string
+	Style    string // Use nodeStyleSolid or nodeStyleFilledDashed
+	Color    string // Border color, from the status of the nested node
+	Nodes    []*DotNodeSpec
+	Clusters []*DotClusterSpec
+}
+
 // --- Visualize Methods ---
 
 // Visualize returns a DOT graph representation of the pipeline.
 func (p *pipelineImplementation) Visualize() string {
+	nodes, edges := p.graphSpecs(p.state)
+	return dotTemplateFuncs(nodes, edges)
+}
+
+// graphSpecs returns the node and edge specs of the pipeline, styled by the given state
+func (p *pipelineImplementation) graphSpecs(state StateInterface) ([]*DotNodeSpec, []*DotEdgeSpec) {
 	if len(p.nodes) == 0 {
-		return dotTemplateFuncs([]*DotNodeSpec{}, []*DotEdgeSpec{})
+		return []*DotNodeSpec{}, []*DotEdgeSpec{}
 	}
 
 	nodes := make([]*DotNodeSpec, 0, len(p.nodes))
 	edges := make([]*DotEdgeSpec, 0, len(p.nodes)-1)
 
-	status, currentStepID, _ := getWorkflowStateInfo(p.state) // Use helper
+	status, currentStepID, _ := getWorkflowStateInfo(state) // Use helper
 
 	// Determine current step index once for edge coloring
 	currentStepIndex := -1
@@ -77,7 +116,7 @@ func (p *pipelineImplementation) Visualize() string {
 	for i, node := range p.nodes {
 		isCurrentStep := currentStepID == node.GetID()
 		// Use pipeline-specific node styling helper
-		nodeStyle, fillColor := getPipelineNodeStyleAndColor(p.state, node.GetID(), i, len(p.nodes), isCurrentStep)
+		nodeStyle, fillColor := getPipelineNodeStyleAndColor(state, node.GetID(), i, len(p.nodes), isCurrentStep)
 		nodes = append(nodes, createDotNodeSpec(node, nodeStyle, fillColor)) // Use helper
 	}
 
@@ -96,27 +135,42 @@ func (p *pipelineImplementation) Visualize() string {
 		edges = append(edges, createDotEdgeSpec(fromNode, toNode, edgeStyle, edgeColor)) // Use helper
 	}
 
-	return dotTemplateFuncs(nodes, edges)
+	return nodes, edges
 }
 
 // Visualize returns a DOT graph representation of the DAG.
 func (d *Dag) Visualize() string {
+	nodes, edges := d.graphSpecs(d.state)
+	return dotTemplateFuncs(nodes, edges)
+}
+
+// graphSpecs returns the node and edge specs of the DAG, styled by the given state
+func (d *Dag) graphSpecs(state StateInterface) ([]*DotNodeSpec, []*DotEdgeSpec) {
 	if len(d.runnables) == 0 {
-		return dotTemplateFuncs([]*DotNodeSpec{}, []*DotEdgeSpec{})
+		return []*DotNodeSpec{}, []*DotEdgeSpec{}
 	}
 
-	status, currentStepID, completedSteps := getWorkflowStateInfo(d.state)
-	nodes := d.createDagNodeSpecs(currentStepID, completedSteps)
+	status, currentStepID, completedSteps := getWorkflowStateInfo(state)
+	nodes := d.createDagNodeSpecs(state, currentStepID, completedSteps)
 	edges := d.createDagEdgeSpecs(status, completedSteps)
 
-	return dotTemplateFuncs(nodes, edges)
+	return nodes, edges
 }
 
 // Visualize returns a DOT graph representation of the step.
 func (s *stepImplementation) Visualize() string {
+	nodeSpec := s.nodeSpec(s.state)
+
+	edges := []*DotEdgeSpec{} // Steps have no edges
+
+	return dotTemplateFuncs([]*DotNodeSpec{nodeSpec}, edges)
+}
+
+// nodeSpec returns the node spec of the step, styled by the given state
+func (s *stepImplementation) nodeSpec(state StateInterface) *DotNodeSpec {
 	nodeStyle, fillColor := nodeStyleSolid, colorWhite // Default
 
-	status, _, _ := getWorkflowStateInfo(s.state) // Use helper for consistency
+	status, _, _ := getWorkflowStateInfo(state) // Use helper for consistency
 
 	// Determine style and color based on status
 	switch status {
@@ -132,14 +186,68 @@ func (s *stepImplementation) Visualize() string {
 	case StateStatusPaused:
 		nodeStyle = nodeStyleFilled
 		fillColor = colorYellow
+	case StateStatusSkipped:
+		nodeStyle = nodeStyleFilledDashed
+		fillColor = colorSilver
 	}
 
-	// FIX: Use createDotNodeSpec to ensure consistent node creation including tooltip
-	nodeSpec := createDotNodeSpec(s, nodeStyle, fillColor)
+	return createDotNodeSpec(s, nodeStyle, fillColor)
+}
 
-	edges := []*DotEdgeSpec{} // Steps have no edges
+// graphSpecs returns the node and edge specs of a Pipeline or Dag, styled by the
+// given state, and false for the other nodes, which have no inner graph
+func graphSpecs(node RunnableInterface, state StateInterface) ([]*DotNodeSpec, []*DotEdgeSpec, bool) {
+	switch n := node.(type) {
+	case *pipelineImplementation:
+		nodes, edges := n.graphSpecs(state)
+		return nodes, edges, true
+	case *Dag:
+		nodes, edges := n.graphSpecs(state)
+		return nodes, edges, true
+	default:
+		return nil, nil, false
+	}
+}
 
-	return dotTemplateFuncs([]*DotNodeSpec{nodeSpec}, edges)
+// isCompositeNode checks whether the node is a Pipeline or Dag, with an inner graph
+func isCompositeNode(node RunnableInterface) bool {
+	switch node.(type) {
+	case *pipelineImplementation, *Dag:
+		return true
+	default:
+		return false
+	}
+}
+
+// nestedState returns the state of a node of a Pipeline or Dag: the state
+// recorded by the parent, or the state of the node itself, nil if there is none
+func nestedState(parent StateInterface, node RunnableInterface) StateInterface {
+	if state := stateChild(parent, node.GetID()); state != nil {
+		return state
+	}
+
+	if stateful, ok := node.(interface{ GetState() StateInterface }); ok {
+		return stateful.GetState()
+	}
+	return nil
+}
+
+// VisualizeExpanded returns a DOT graph representation of the pipeline, with its
+// nested pipelines and DAGs expanded into clusters showing their own nodes.
+func (p *pipelineImplementation) VisualizeExpanded() string {
+	return dotExpandedGraph(p, p.state)
+}
+
+// VisualizeExpanded returns a DOT graph representation of the DAG, with its
+// nested pipelines and DAGs expanded into clusters showing their own nodes.
+func (d *Dag) VisualizeExpanded() string {
+	return dotExpandedGraph(d, d.state)
+}
+
+// VisualizeExpanded returns a DOT graph representation of the step,
+// the same as Visualize, as a step has no nested nodes.
+func (s *stepImplementation) VisualizeExpanded() string {
+	return s.Visualize()
 }
 
 // --- Helper Functions ---
@@ -154,6 +262,15 @@ func escapeDotString(s string) string {
 	return "" // Return empty string if input was empty or invalid
 }
 
+// dotStyleAttribute quotes a style made of several values (e.g. "filled,dashed"),
+// as required by the DOT language. Single values are returned unchanged.
+func dotStyleAttribute(style string) string {
+	if strings.Contains(style, ",") {
+		return fmt.Sprintf("%q", style)
+	}
+	return style
+}
+
 // getWorkflowStateInfo safely extracts common state information from a StateInterface.
 // It returns default values if the state is nil.
 func getWorkflowStateInfo(state StateInterface) (status StateStatus, currentStepID string, completedSteps []string) {
@@ -209,6 +326,11 @@ func getNodeStyleAndColor(state StateInterface, isCurrentStep bool) (style, fill
 // getPipelineNodeStyleAndColor determines the style and fill color for a node within a pipeline.
 // It considers the overall pipeline status, the node's position, and whether it's the current step.
 func getPipelineNodeStyleAndColor(state StateInterface, nodeID string, index, nodeCount int, isCurrentStep bool) (style, fillColor string) {
+	// Skipped steps have their own style, whatever the pipeline status
+	if isSkippedStep(state, nodeID) {
+		return nodeStyleFilledDashed, colorSilver
+	}
+
 	// If it's the current step, use the general current step styling logic
 	if isCurrentStep {
 		return getNodeStyleAndColor(state, true)
@@ -243,6 +365,16 @@ func getPipelineNodeStyleAndColor(state StateInterface, nodeID string, index, no
 // getDagNodeStyleAndColor determines the style and fill color for a node within a DAG.
 // It considers the overall DAG status, whether the node is completed, and if it's the current step.
 func getDagNodeStyleAndColor(state StateInterface, nodeID string, isCurrentStep bool, completedSteps []string) (style, fillColor string) {
+	// Skipped nodes have their own style, whatever the DAG status
+	if isSkippedStep(state, nodeID) {
+		return nodeStyleFilledDashed, colorSilver
+	}
+
+	// Failed nodes are red, as a DAG may run other nodes after a failure
+	if isFailedStep(state, nodeID) {
+		return nodeStyleFilled, colorRed
+	}
+
 	// If it's the current step, use the general current step styling logic
 	if isCurrentStep {
 		return getNodeStyleAndColor(state, true)
@@ -269,6 +401,22 @@ func getDagNodeStyleAndColor(state StateInterface, nodeID string, isCurrentStep
 	return style, fillColor
 }
 
+// isSkippedStep checks whether the state records the node as skipped
+func isSkippedStep(state StateInterface, nodeID string) bool {
+	if state == nil {
+		return false
+	}
+	return slices.Contains(skippedSteps(state), nodeID)
+}
+
+// isFailedStep checks whether the state records the node as failed
+func isFailedStep(state StateInterface, nodeID string) bool {
+	if state == nil {
+		return false
+	}
+	return slices.Contains(failedSteps(state), nodeID)
+}
+
 // createDotNodeSpec creates a DotNodeSpec struct with common defaults and provided style/color.
 func createDotNodeSpec(node RunnableInterface, style, fillColor string) *DotNodeSpec {
 	name := node.GetName()
@@ -306,6 +454,11 @@ func createDotEdgeSpec(fromNode, toNode RunnableInterface, style, color string)
 
 // dotTemplateFuncs generates the final DOT language string from node and edge specifications.
 func dotTemplateFuncs(nodes []*DotNodeSpec, edges []*DotEdgeSpec) string {
+	return dotClusterTemplateFuncs(nodes, nil, edges)
+}
+
+// dotClusterTemplateFuncs generates the final DOT language string from node, cluster and edge specifications.
+func dotClusterTemplateFuncs(nodes []*DotNodeSpec, clusters []*DotClusterSpec, edges []*DotEdgeSpec) string {
 	var sb strings.Builder
 
 	sb.WriteString("digraph {\n")
@@ -317,29 +470,19 @@ func dotTemplateFuncs(nodes []*DotNodeSpec, edges []*DotEdgeSpec) string {
 	// Add Nodes
 	sb.WriteString("\t// Nodes\n")
 	for _, node := range nodes {
-		// Use DisplayName if available, otherwise Name (which defaults to ID if original name was empty)
-		label := node.DisplayName
-		tooltip := node.Tooltip // Use pre-formatted tooltip
-
-		// Build node attributes string
-		attrs := fmt.Sprintf("label=\"%s\", style=%s, tooltip=\"%s\", fillcolor=\"%s\"",
-			escapeDotString(label),
-			node.Style,
-			escapeDotString(tooltip),
-			node.FillColor,
-		)
-		// Add fontcolor=white for filled nodes for better contrast
-		if node.Style == nodeStyleFilled {
-			attrs += ", fontcolor=\"white\""
-		}
-
-		sb.WriteString(fmt.Sprintf("\t\"%s\" [%s];\n",
-			escapeDotString(node.Name), // Node ID must be unique
-			attrs,
-		))
+		writeDotNode(&sb, node, "\t")
 	}
 	sb.WriteString("\n")
 
+	// Add Clusters
+	if len(clusters) > 0 {
+		sb.WriteString("\t// Clusters\n")
+		for _, cluster := range clusters {
+			writeDotCluster(&sb, cluster, "\t")
+		}
+		sb.WriteString("\n")
+	}
+
 	// Add Edges
 	sb.WriteString("\t// Edges\n")
 	for _, edge := range edges {
@@ -361,12 +504,173 @@ func dotTemplateFuncs(nodes []*DotNodeSpec, edges []*DotEdgeSpec) string {
 	return sb.String()
 }
 
+// writeDotNode writes the DOT statement of a node
+func writeDotNode(sb *strings.Builder, node *DotNodeSpec, indent string) {
+	// Use DisplayName if available, otherwise Name (which defaults to ID if original name was empty)
+	label := node.DisplayName
+	tooltip := node.Tooltip // Use pre-formatted tooltip
+
+	// Build node attributes string
+	attrs := fmt.Sprintf("label=\"%s\", style=%s, tooltip=\"%s\", fillcolor=\"%s\"",
+		escapeDotString(label),
+		dotStyleAttribute(node.Style),
+		escapeDotString(tooltip),
+		node.FillColor,
+	)
+	// Add fontcolor=white for filled nodes for better contrast
+	if node.Style == nodeStyleFilled {
+		attrs += ", fontcolor=\"white\""
+	}
+
+	sb.WriteString(fmt.Sprintf("%s\"%s\" [%s];\n",
+		indent,
+		escapeDotString(node.Name), // Node ID must be unique
+		attrs,
+	))
+}
+
+// writeDotCluster writes the DOT subgraph of a cluster, with its nodes and nested clusters
+func writeDotCluster(sb *strings.Builder, cluster *DotClusterSpec, indent string) {
+	style := "rounded"
+	if cluster.Style == nodeStyleFilledDashed {
+		style = "rounded,dashed"
+	}
+
+	sb.WriteString(fmt.Sprintf("%ssubgraph \"%s\" {\n", indent, escapeDotString(cluster.Name)))
+	sb.WriteString(fmt.Sprintf("%s\tlabel=\"%s\"; tooltip=\"%s\"; style=%s; color=\"%s\";\n",
+		indent,
+		escapeDotString(cluster.Label),
+		escapeDotString(cluster.Tooltip),
+		dotStyleAttribute(style),
+		cluster.Color,
+	))
+
+	for _, node := range cluster.Nodes {
+		writeDotNode(sb, node, indent+"\t")
+	}
+	for _, nested := range cluster.Clusters {
+		writeDotCluster(sb, nested, indent+"\t")
+	}
+
+	sb.WriteString(indent + "}\n")
+}
+
+// dotExpandedGraph returns the DOT graph of a Pipeline or Dag, styled by the
+// given state, with its nested pipelines and DAGs drawn as clusters
+func dotExpandedGraph(node RunnableInterface, state StateInterface) string {
+	graph := expandGraph(node, state, "")
+	return dotClusterTemplateFuncs(graph.nodes, graph.clusters, graph.edges)
+}
+
+// expandedGraph is the graph of a Pipeline or Dag, with its nested pipelines and DAGs expanded
+type expandedGraph struct {
+	nodes    []*DotNodeSpec
+	clusters []*DotClusterSpec
+	edges    []*DotEdgeSpec
+
+	// names of the nodes the edges into the graph go to,
+	// and of the nodes the edges out of the graph come from
+	entries []string
+	exits   []string
+}
+
+// expandGraph expands the graph of a Pipeline or Dag. The names of the nested
+// nodes are prefixed with the path of their parent (e.g. "payment/charge"), as
+// they must be unique in the DOT graph. An edge into or out of a nested
+// pipeline or DAG is drawn to each of its entry nodes, or from each of its exit
+// nodes: the nodes without dependencies, and the nodes nothing depends on.
+func expandGraph(node RunnableInterface, state StateInterface, prefix string) expandedGraph {
+	graph := expandedGraph{}
+
+	nodes, edges, ok := graphSpecs(node, state)
+	if !ok {
+		return graph
+	}
+
+	finder, _ := node.(interface {
+		findNode(id string) (RunnableInterface, bool)
+	})
+
+	entries := make(map[string][]string, len(nodes))
+	exits := make(map[string][]string, len(nodes))
+
+	for _, spec := range nodes {
+		name := prefix + spec.Name
+
+		var child RunnableInterface
+		if finder != nil {
+			child, _ = finder.findNode(spec.Name)
+		}
+
+		nested := expandedGraph{}
+		if isCompositeNode(child) {
+			nested = expandGraph(child, nestedState(state, child), name+"/")
+		}
+
+		// A step, or an empty pipeline or DAG, is a single node
+		if len(nested.entries) == 0 {
+			nodeSpec := *spec
+			nodeSpec.Name = name
+			graph.nodes = append(graph.nodes, &nodeSpec)
+			entries[spec.Name] = []string{name}
+			exits[spec.Name] = []string{name}
+			continue
+		}
+
+		color := spec.FillColor
+		if color == colorWhite {
+			color = colorGrey
+		}
+
+		graph.clusters = append(graph.clusters, &DotClusterSpec{
+			Name:     "cluster_" + name,
+			Label:    spec.DisplayName,
+			Tooltip:  spec.Tooltip,
+			Style:    spec.Style,
+			Color:    color,
+			Nodes:    nested.nodes,
+			Clusters: nested.clusters,
+		})
+		graph.edges = append(graph.edges, nested.edges...)
+		entries[spec.Name] = nested.entries
+		exits[spec.Name] = nested.exits
+	}
+
+	hasIncoming := make(map[string]bool, len(nodes))
+	hasOutgoing := make(map[string]bool, len(nodes))
+	for _, edge := range edges {
+		hasOutgoing[edge.FromNodeName] = true
+		hasIncoming[edge.ToNodeName] = true
+
+		for _, from := range exits[edge.FromNodeName] {
+			for _, to := range entries[edge.ToNodeName] {
+				edgeSpec := *edge
+				edgeSpec.FromNodeName = from
+				edgeSpec.ToNodeName = to
+				graph.edges = append(graph.edges, &edgeSpec)
+			}
+		}
+	}
+
+	for _, spec := range nodes {
+		if !hasIncoming[spec.Name] {
+			graph.entries = append(graph.entries, entries[spec.Name]...)
+		}
+		if !hasOutgoing[spec.Name] {
+			graph.exits = append(graph.exits, exits[spec.Name]...)
+		}
+	}
+
+	return graph
+}
+
 // createDagEdgeSpecs generates the list of DotEdgeSpec for a DAG based on its dependencies and state.
 func (d *Dag) createDagEdgeSpecs(status StateStatus, completedSteps []string) []*DotEdgeSpec {
 	edges := make([]*DotEdgeSpec, 0) // Initialize empty slice
 
-	// Iterate through the DAG's defined dependencies
-	for dependentID, dependencyIDs := range d.dependencies {
+	// Iterate through the DAG's defined dependencies, in the order the nodes were added
+	for _, dependentID := range d.runnableSequence {
+		dependencyIDs := d.dependencies[dependentID]
 		dependent, depExists := d.runnables[dependentID]
 		if !depExists {
 			continue // Skip if the dependent node doesn't exist in the runnables map
@@ -396,19 +700,48 @@ func (d *Dag) createDagEdgeSpecs(status StateStatus, completedSteps []string) []
 			edges = append(edges, createDotEdgeSpec(dependency, dependent, edgeStyle, edgeColor))
 		}
 	}
+
+	// Iterate through the DAG's conditional dependencies, drawn as dashed edges
+	for _, dependentID := range d.runnableSequence {
+		conditions := d.conditionalDependencies[dependentID]
+		dependent, depExists := d.runnables[dependentID]
+		if !depExists {
+			continue // Skip if the dependent node doesn't exist in the runnables map
+		}
+
+		for _, conditional := range conditions {
+			for _, dependencyID := range conditional.dependencyIDs {
+				dependency, depExists2 := d.runnables[dependencyID]
+				if !depExists2 {
+					continue // Skip if the dependency node doesn't exist
+				}
+
+				edgeColor := colorGrey // Default color
+
+				// Color edge green, if the dependent node actually ran
+				if slices.Contains(completedSteps, dependentID) {
+					edgeColor = colorGreen
+				}
+
+				edges = append(edges, createDotEdgeSpec(dependency, dependent, edgeStyleDashed, edgeColor))
+			}
+		}
+	}
+
 	return edges
 }
 
 // createDagNodeSpecs generates the list of DotNodeSpec for a DAG based on its state.
-func (d *Dag) createDagNodeSpecs(currentStepID string, completedSteps []string) []*DotNodeSpec {
+func (d *Dag) createDagNodeSpecs(state StateInterface, currentStepID string, completedSteps []string) []*DotNodeSpec {
 	nodes := make([]*DotNodeSpec, 0, len(d.runnables)) // Pre-allocate slice capacity
 
-	// Iterate through all runnable nodes in the DAG
-	for _, node := range d.runnables {
+	// Iterate through all runnable nodes in the DAG, in the order they were added
+	for _, id := range d.runnableSequence {
+		node := d.runnables[id]
 		isCurrentStep := currentStepID == node.GetID()
 
 		// Use the DAG-specific helper function to determine style and color
-		nodeStyle, fillColor := getDagNodeStyleAndColor(d.state, node.GetID(), isCurrentStep, completedSteps)
+		nodeStyle, fillColor := getDagNodeStyleAndColor(state, node.GetID(), isCurrentStep, completedSteps)
 
 		// Create and add the node specification
 		nodes = append(nodes, createDotNodeSpec(node, nodeStyle, fillColor))
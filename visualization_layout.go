@@ -0,0 +1,411 @@
+package wf
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// Sizes used by the layered layout, in points, matching the default
+// Graphviz box of 14pt Arial labels
+const (
+	layoutNodeHeight   = 36.0
+	layoutNodeMinWidth = 54.0
+	layoutNodePadding  = 16.0 // Horizontal padding, on each side of the label
+	layoutCharWidth    = 7.8  // Average width of a 14pt Arial character
+	layoutLayerGap     = 36.0 // Horizontal gap between the layers
+	layoutNodeGap      = 18.0 // Vertical gap between the nodes of a layer
+	layoutMargin       = 4.0
+	layoutSweeps       = 8 // Sweeps of the crossing reduction and of the coordinate assignment
+)
+
+// graphLayout is the position of the nodes and edges of a graph, laid out
+// from left to right in layers, such that every edge goes to a later layer
+type graphLayout struct {
+	width  float64
+	height float64
+	nodes  []layoutBox  // In the order of the node specs
+	edges  []layoutPath // In the order of the edge specs
+}
+
+// layoutBox is the rectangle of a node, from its top left corner
+type layoutBox struct {
+	x, y, width, height float64
+}
+
+// layoutPath is the route of an edge, from the border of its source node to
+// the border of its target node. It has no points, for a loop on a node.
+type layoutPath struct {
+	points []layoutPoint
+}
+
+// layoutPoint is a point of an edge route
+type layoutPoint struct {
+	x, y float64
+}
+
+// layoutVertex is a node of the layered graph. The edges longer than one layer
+// are split by dummy vertices, with no width, so they are routed around the
+// nodes of the layers they cross.
+type layoutVertex struct {
+	layer  int
+	order  int
+	width  float64
+	height float64
+	y      float64 // Center
+	preds  []int
+	succs  []int
+}
+
+// layoutGraph lays out the nodes and edges of a graph, with the layered
+// (Sugiyama) method:
+//  1. the edges closing cycles are reversed, so the graph is acyclic
+//  2. the nodes are put in layers, by their longest path from a source
+//  3. the edges longer than one layer are split by dummy vertices
+//  4. the nodes of each layer are ordered to reduce the edge crossings,
+//     by the barycenter of their neighbors
+//  5. the nodes are placed near the center of their neighbors
+//
+// The layout is deterministic: ties keep the order of the node specs.
+func layoutGraph(nodes []*DotNodeSpec, edges []*DotEdgeSpec) *graphLayout {
+	// 1. and 2. Put the nodes in layers
+	nodeLayers, layoutEdges := layoutLayers(nodes, edges)
+
+	vertices := make([]*layoutVertex, 0, len(nodes))
+	for i, node := range nodes {
+		width := math.Max(layoutNodeMinWidth, float64(utf8.RuneCountInString(node.DisplayName))*layoutCharWidth+2*layoutNodePadding)
+		vertices = append(vertices, &layoutVertex{layer: nodeLayers[i], width: width, height: layoutNodeHeight})
+	}
+
+	// 3. Split the long edges, and keep the chain of vertices of each edge
+	chains := make([][]int, len(layoutEdges))
+	for i, edge := range layoutEdges {
+		if !edge.valid || edge.loop {
+			continue
+		}
+		chain := []int{edge.from}
+		for layer := vertices[edge.from].layer + 1; layer < vertices[edge.to].layer; layer++ {
+			vertices = append(vertices, &layoutVertex{layer: layer})
+			chain = append(chain, len(vertices)-1)
+		}
+		chain = append(chain, edge.to)
+		for j := 1; j < len(chain); j++ {
+			vertices[chain[j-1]].succs = append(vertices[chain[j-1]].succs, chain[j])
+			vertices[chain[j]].preds = append(vertices[chain[j]].preds, chain[j-1])
+		}
+		chains[i] = chain
+	}
+
+	layerCount := 0
+	for _, vertex := range vertices {
+		layerCount = max(layerCount, vertex.layer+1)
+	}
+	layers := make([][]int, layerCount)
+	for v, vertex := range vertices {
+		vertex.order = len(layers[vertex.layer])
+		layers[vertex.layer] = append(layers[vertex.layer], v)
+	}
+
+	// 4. Order the layers, keeping the order with the fewest crossings
+	best := cloneLayers(layers)
+	bestCrossings := layoutCrossings(vertices, layers)
+	for sweep := 0; sweep < layoutSweeps && bestCrossings > 0; sweep++ {
+		if sweep%2 == 0 {
+			for l := 1; l < len(layers); l++ {
+				orderByBarycenter(vertices, layers[l], func(v *layoutVertex) []int { return v.preds })
+			}
+		} else {
+			for l := len(layers) - 2; l >= 0; l-- {
+				orderByBarycenter(vertices, layers[l], func(v *layoutVertex) []int { return v.succs })
+			}
+		}
+
+		if crossings := layoutCrossings(vertices, layers); crossings < bestCrossings {
+			best, bestCrossings = cloneLayers(layers), crossings
+		}
+	}
+	layers = best
+	for _, layer := range layers {
+		for order, v := range layer {
+			vertices[v].order = order
+		}
+	}
+
+	// 5. Stack the layers, then move the nodes to the center of their neighbors
+	for _, layer := range layers {
+		y := 0.0
+		for _, v := range layer {
+			vertices[v].y = y + vertices[v].height/2
+			y += vertices[v].height + layoutNodeGap
+		}
+	}
+	for sweep := 0; sweep < layoutSweeps; sweep++ {
+		if sweep%2 == 0 {
+			for l := 1; l < len(layers); l++ {
+				alignLayer(vertices, layers[l], func(v *layoutVertex) []int { return v.preds })
+			}
+		} else {
+			for l := len(layers) - 2; l >= 0; l-- {
+				alignLayer(vertices, layers[l], func(v *layoutVertex) []int { return v.succs })
+			}
+		}
+	}
+
+	// The x of each layer, from the width of its widest node
+	layerX := make([]float64, layerCount)
+	layerWidth := make([]float64, layerCount)
+	for _, vertex := range vertices {
+		layerWidth[vertex.layer] = math.Max(layerWidth[vertex.layer], vertex.width)
+	}
+	x := layoutMargin
+	for l := range layers {
+		layerX[l] = x
+		x += layerWidth[l] + layoutLayerGap
+	}
+
+	// Move the graph to the top margin
+	top := math.Inf(1)
+	bottom := 0.0
+	for _, vertex := range vertices {
+		top = math.Min(top, vertex.y-vertex.height/2)
+	}
+	for _, vertex := range vertices {
+		vertex.y += layoutMargin - top
+		bottom = math.Max(bottom, vertex.y+vertex.height/2)
+	}
+
+	layout := &graphLayout{
+		width:  math.Max(x-layoutLayerGap+layoutMargin, 2*layoutMargin),
+		height: math.Max(bottom+layoutMargin, 2*layoutMargin),
+		nodes:  make([]layoutBox, len(nodes)),
+		edges:  make([]layoutPath, len(edges)),
+	}
+
+	for i := range nodes {
+		vertex := vertices[i]
+		layout.nodes[i] = layoutBox{
+			x:      layerX[vertex.layer] + (layerWidth[vertex.layer]-vertex.width)/2,
+			y:      vertex.y - vertex.height/2,
+			width:  vertex.width,
+			height: vertex.height,
+		}
+	}
+
+	// Route the edges from the right of their source, through the layers
+	// they cross, to the left of their target
+	for i, chain := range chains {
+		if len(chain) == 0 {
+			continue
+		}
+
+		source := layout.nodes[chain[0]]
+		target := layout.nodes[chain[len(chain)-1]]
+
+		points := []layoutPoint{{source.x + source.width, source.y + source.height/2}}
+		for _, v := range chain[1 : len(chain)-1] {
+			vertex := vertices[v]
+			points = append(points,
+				layoutPoint{layerX[vertex.layer], vertex.y},
+				layoutPoint{layerX[vertex.layer] + layerWidth[vertex.layer], vertex.y},
+			)
+		}
+		points = append(points, layoutPoint{target.x, target.y + target.height/2})
+
+		if layoutEdges[i].reversed {
+			for l, r := 0, len(points)-1; l < r; l, r = l+1, r-1 {
+				points[l], points[r] = points[r], points[l]
+			}
+		}
+
+		layout.edges[i] = layoutPath{points: points}
+	}
+
+	return layout
+}
+
+// layoutEdge is an edge between known nodes, with its direction in the layout
+type layoutEdge struct {
+	from, to int
+	reversed bool
+	loop     bool
+	valid    bool
+}
+
+// layoutLayers puts the nodes in layers, steps 1 and 2 of layoutGraph: the
+// edges closing cycles are reversed, and each node is put one layer after its
+// latest predecessor. It returns the layer of each node, in the order of the
+// node specs, and the edges in the order of the edge specs.
+func layoutLayers(nodes []*DotNodeSpec, edges []*DotEdgeSpec) ([]int, []layoutEdge) {
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.Name] = i
+	}
+
+	layoutEdges := make([]layoutEdge, len(edges))
+	for i, edge := range edges {
+		from, okFrom := index[edge.FromNodeName]
+		to, okTo := index[edge.ToNodeName]
+		layoutEdges[i] = layoutEdge{from: from, to: to, loop: from == to, valid: okFrom && okTo}
+	}
+
+	// 1. Reverse the edges to a node on the depth first search stack
+	adjacency := make([][]int, len(nodes))
+	for i, edge := range layoutEdges {
+		if edge.valid && !edge.loop {
+			adjacency[edge.from] = append(adjacency[edge.from], i)
+		}
+	}
+	const (
+		unvisited = iota
+		onStack
+		visited
+	)
+	marks := make([]int, len(nodes))
+	var visit func(v int)
+	visit = func(v int) {
+		marks[v] = onStack
+		for _, i := range adjacency[v] {
+			switch marks[layoutEdges[i].to] {
+			case onStack:
+				layoutEdges[i].reversed = true
+			case unvisited:
+				visit(layoutEdges[i].to)
+			}
+		}
+		marks[v] = visited
+	}
+	for v := range nodes {
+		if marks[v] == unvisited {
+			visit(v)
+		}
+	}
+
+	preds := make([][]int, len(nodes))
+	for i := range layoutEdges {
+		edge := &layoutEdges[i]
+		if !edge.valid || edge.loop {
+			continue
+		}
+		if edge.reversed {
+			edge.from, edge.to = edge.to, edge.from
+		}
+		preds[edge.to] = append(preds[edge.to], edge.from)
+	}
+
+	// 2. Put each node one layer after its latest predecessor
+	layers := make([]int, len(nodes))
+	layered := make([]bool, len(nodes))
+	var layerOf func(v int) int
+	layerOf = func(v int) int {
+		if !layered[v] {
+			layered[v] = true
+			for _, pred := range preds[v] {
+				layers[v] = max(layers[v], layerOf(pred)+1)
+			}
+		}
+		return layers[v]
+	}
+	for v := range nodes {
+		layerOf(v)
+	}
+
+	return layers, layoutEdges
+}
+
+// orderByBarycenter orders the vertices of a layer by the mean order of their
+// neighbors in the previous layer of the sweep. The vertices without
+// neighbors keep their order.
+func orderByBarycenter(vertices []*layoutVertex, layer []int, neighbors func(v *layoutVertex) []int) {
+	barycenters := make(map[int]float64, len(layer))
+	for _, v := range layer {
+		barycenters[v] = float64(vertices[v].order)
+		if adjacent := neighbors(vertices[v]); len(adjacent) > 0 {
+			sum := 0.0
+			for _, n := range adjacent {
+				sum += float64(vertices[n].order)
+			}
+			barycenters[v] = sum / float64(len(adjacent))
+		}
+	}
+
+	sort.SliceStable(layer, func(i, j int) bool {
+		return barycenters[layer[i]] < barycenters[layer[j]]
+	})
+	for order, v := range layer {
+		vertices[v].order = order
+	}
+}
+
+// layoutCrossings counts the crossings of the edges between the adjacent layers
+func layoutCrossings(vertices []*layoutVertex, layers [][]int) int {
+	crossings := 0
+	for _, layer := range layers {
+		type segment struct{ from, to int }
+		segments := []segment{}
+		for _, v := range layer {
+			for _, succ := range vertices[v].succs {
+				segments = append(segments, segment{vertices[v].order, vertices[succ].order})
+			}
+		}
+		for i := range segments {
+			for j := i + 1; j < len(segments); j++ {
+				a, b := segments[i], segments[j]
+				if (a.from < b.from && a.to > b.to) || (a.from > b.from && a.to < b.to) {
+					crossings++
+				}
+			}
+		}
+	}
+	return crossings
+}
+
+// alignLayer moves the vertices of a layer to the mean y of their neighbors
+// in the previous layer of the sweep, keeping their order and their gaps.
+// The vertices are packed once downwards and once upwards from the wanted
+// positions, and placed halfway, so the layer is not pushed to one side.
+func alignLayer(vertices []*layoutVertex, layer []int, neighbors func(v *layoutVertex) []int) {
+	wanted := make([]float64, len(layer))
+	for i, v := range layer {
+		wanted[i] = vertices[v].y
+		if adjacent := neighbors(vertices[v]); len(adjacent) > 0 {
+			sum := 0.0
+			for _, n := range adjacent {
+				sum += vertices[n].y
+			}
+			wanted[i] = sum / float64(len(adjacent))
+		}
+	}
+
+	gap := func(i int) float64 {
+		return (vertices[layer[i-1]].height+vertices[layer[i]].height)/2 + layoutNodeGap
+	}
+
+	down := make([]float64, len(layer))
+	for i := range layer {
+		down[i] = wanted[i]
+		if i > 0 {
+			down[i] = math.Max(down[i], down[i-1]+gap(i))
+		}
+	}
+
+	up := make([]float64, len(layer))
+	for i := len(layer) - 1; i >= 0; i-- {
+		up[i] = wanted[i]
+		if i < len(layer)-1 {
+			up[i] = math.Min(up[i], up[i+1]-gap(i+1))
+		}
+	}
+
+	for i, v := range layer {
+		vertices[v].y = (down[i] + up[i]) / 2
+	}
+}
+
+// cloneLayers returns a copy of the orders of the layers
+func cloneLayers(layers [][]int) [][]int {
+	clone := make([][]int, len(layers))
+	for l, layer := range layers {
+		clone[l] = append([]int(nil), layer...)
+	}
+	return clone
+}
@@ -0,0 +1,140 @@
+package wf
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func newLayoutSpecs(names []string, edges [][2]string) ([]*DotNodeSpec, []*DotEdgeSpec) {
+	nodeSpecs := []*DotNodeSpec{}
+	for _, name := range names {
+		nodeSpecs = append(nodeSpecs, &DotNodeSpec{Name: name, DisplayName: "Step " + name})
+	}
+	edgeSpecs := []*DotEdgeSpec{}
+	for _, edge := range edges {
+		edgeSpecs = append(edgeSpecs, &DotEdgeSpec{FromNodeName: edge[0], ToNodeName: edge[1]})
+	}
+	return nodeSpecs, edgeSpecs
+}
+
+func Test_LayoutGraph(t *testing.T) {
+	nodes, edges := newLayoutSpecs(
+		[]string{"a", "b", "c", "d", "e"},
+		[][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}, {"a", "e"}, {"d", "e"}},
+	)
+
+	layout := layoutGraph(nodes, edges)
+
+	box := func(name string) layoutBox {
+		for i, node := range nodes {
+			if node.Name == name {
+				return layout.nodes[i]
+			}
+		}
+		t.Fatalf("No node %q", name)
+		return layoutBox{}
+	}
+
+	// Each node is in a layer after the nodes it depends on
+	for _, edge := range edges {
+		from, to := box(edge.FromNodeName), box(edge.ToNodeName)
+		if from.x+from.width >= to.x {
+			t.Errorf("Expected %s to be left of %s, got %+v and %+v", edge.FromNodeName, edge.ToNodeName, from, to)
+		}
+	}
+
+	// The nodes of a layer do not overlap
+	b, c := box("b"), box("c")
+	if b.x != c.x || (b.y+b.height > c.y && c.y+c.height > b.y) {
+		t.Errorf("Expected b and c in the same layer, without overlap, got %+v and %+v", b, c)
+	}
+
+	// The nodes are inside the image
+	for i, node := range layout.nodes {
+		if node.x < 0 || node.y < 0 || node.x+node.width > layout.width || node.y+node.height > layout.height {
+			t.Errorf("Node %s outside of the %vx%v image: %+v", nodes[i].Name, layout.width, layout.height, node)
+		}
+	}
+
+	// The edges go from their source to their target, and the long edge
+	// from a to e is routed through the layers of b and d
+	for i, edge := range edges {
+		points := layout.edges[i].points
+		from, to := box(edge.FromNodeName), box(edge.ToNodeName)
+		if len(points) < 2 || points[0].x != from.x+from.width || points[len(points)-1].x != to.x {
+			t.Errorf("Unexpected route of %s->%s: %+v", edge.FromNodeName, edge.ToNodeName, points)
+		}
+	}
+	if points := layout.edges[4].points; len(points) != 6 {
+		t.Errorf("Expected the long edge to cross 2 layers, got %+v", points)
+	}
+
+	// The layout is deterministic
+	if again := layoutGraph(nodes, edges); !reflect.DeepEqual(layout, again) {
+		t.Error("Expected the same layout for the same graph")
+	}
+}
+
+func Test_LayoutGraph_Crossings(t *testing.T) {
+	// Without reordering, the edges a->d and b->c cross
+	nodes, edges := newLayoutSpecs(
+		[]string{"a", "b", "c", "d"},
+		[][2]string{{"a", "d"}, {"b", "c"}},
+	)
+
+	layout := layoutGraph(nodes, edges)
+
+	if a, b, c, d := layout.nodes[0], layout.nodes[1], layout.nodes[2], layout.nodes[3]; (a.y < b.y) != (d.y < c.y) {
+		t.Errorf("Expected the edges not to cross, got a=%+v b=%+v c=%+v d=%+v", a, b, c, d)
+	}
+}
+
+func Test_LayoutGraph_Cycle(t *testing.T) {
+	nodes, edges := newLayoutSpecs(
+		[]string{"a", "b", "c"},
+		[][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "c"}, {"c", "unknown"}},
+	)
+
+	layout := layoutGraph(nodes, edges)
+
+	// The edge closing the cycle is drawn backwards, to its target
+	back := layout.edges[2].points
+	if len(back) < 2 || back[0].x != layout.nodes[2].x || back[len(back)-1].x != layout.nodes[0].x+layout.nodes[0].width {
+		t.Errorf("Unexpected route of the back edge: %+v", back)
+	}
+
+	// Loops and edges to unknown nodes are not routed
+	for _, i := range []int{3, 4} {
+		if points := layout.edges[i].points; len(points) != 0 {
+			t.Errorf("Expected edge %d not to be routed, got %+v", i, points)
+		}
+	}
+}
+
+func Test_LayoutGraph_Empty(t *testing.T) {
+	layout := layoutGraph(nil, nil)
+
+	if len(layout.nodes) != 0 || layout.width <= 0 || layout.height <= 0 {
+		t.Errorf("Unexpected layout of an empty graph: %+v", layout)
+	}
+}
+
+func Test_LayoutGraph_Wide(t *testing.T) {
+	names := []string{"root"}
+	edges := [][2]string{}
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("n%d", i)
+		names = append(names, name)
+		edges = append(edges, [2]string{"root", name})
+	}
+
+	nodes, edgeSpecs := newLayoutSpecs(names, edges)
+	layout := layoutGraph(nodes, edgeSpecs)
+
+	for i := 2; i < len(layout.nodes); i++ {
+		if layout.nodes[i].y < layout.nodes[i-1].y+layout.nodes[i-1].height {
+			t.Fatalf("Expected the nodes of the layer to be stacked, got %+v and %+v", layout.nodes[i-1], layout.nodes[i])
+		}
+	}
+}
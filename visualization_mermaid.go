@@ -0,0 +1,147 @@
+package wf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mermaidEscaper replaces the characters that cannot appear in a quoted
+// Mermaid label by their Mermaid entity codes
+var mermaidEscaper = strings.NewReplacer(
+	"#", "#35;",
+	"\"", "#quot;",
+	"<", "#lt;",
+	">", "#gt;",
+	"`", "#96;",
+	"\r", "",
+	"\n", " ",
+)
+
+// VisualizeMermaid returns a Mermaid flowchart representation of the pipeline.
+// Nested pipelines and DAGs are drawn as subgraphs.
+func (p *pipelineImplementation) VisualizeMermaid() string {
+	return mermaidFlowchart(p, p.state)
+}
+
+// VisualizeMermaid returns a Mermaid flowchart representation of the DAG.
+// Nested pipelines and DAGs are drawn as subgraphs.
+func (d *Dag) VisualizeMermaid() string {
+	return mermaidFlowchart(d, d.state)
+}
+
+// VisualizeMermaid returns a Mermaid flowchart representation of the step.
+func (s *stepImplementation) VisualizeMermaid() string {
+	return mermaidFlowchart(s, s.state)
+}
+
+// mermaidRenderer writes a Mermaid flowchart. The nodes get generated IDs
+// (n1, n2, ...), as nested nodes may have the same IDs as other nodes, and
+// Mermaid reserves some words (e.g. "end").
+type mermaidRenderer struct {
+	sb         strings.Builder
+	ids        int
+	styles     []string
+	linkStyles []string
+}
+
+// mermaidFlowchart returns the Mermaid flowchart of a node, styled by the given
+// state, with the same colors as Visualize
+func mermaidFlowchart(node RunnableInterface, state StateInterface) string {
+	r := &mermaidRenderer{}
+	r.sb.WriteString("flowchart LR\n")
+
+	if step, ok := node.(*stepImplementation); ok {
+		r.writeNode(step.nodeSpec(state), "    ")
+	} else {
+		r.writeGraph(node, state, "    ")
+	}
+
+	for _, style := range append(r.styles, r.linkStyles...) {
+		r.sb.WriteString("    " + style + "\n")
+	}
+
+	return r.sb.String()
+}
+
+// writeGraph writes the nodes and edges of a Pipeline or Dag. The nested
+// pipelines and DAGs are written as subgraphs, with their own nodes and edges.
+func (r *mermaidRenderer) writeGraph(node RunnableInterface, state StateInterface, indent string) {
+	nodes, edges, ok := graphSpecs(node, state)
+	if !ok {
+		return
+	}
+
+	finder, _ := node.(interface {
+		findNode(id string) (RunnableInterface, bool)
+	})
+
+	ids := make(map[string]string, len(nodes))
+	for _, spec := range nodes {
+		var child RunnableInterface
+		if finder != nil {
+			child, _ = finder.findNode(spec.Name)
+		}
+
+		if isCompositeNode(child) {
+			ids[spec.Name] = r.writeSubgraph(spec, child, nestedState(state, child), indent)
+		} else {
+			ids[spec.Name] = r.writeNode(spec, indent)
+		}
+	}
+
+	for _, edge := range edges {
+		arrow := "-->"
+		if edge.Style == edgeStyleDashed {
+			arrow = "-.->"
+		}
+
+		fmt.Fprintf(&r.sb, "%s%s %s %s\n", indent, ids[edge.FromNodeName], arrow, ids[edge.ToNodeName])
+		// Links are styled by their index, in the order they are written
+		r.linkStyles = append(r.linkStyles, fmt.Sprintf("linkStyle %d stroke:%s", len(r.linkStyles), edge.Color))
+	}
+}
+
+// writeNode writes a node, and returns its Mermaid ID
+func (r *mermaidRenderer) writeNode(spec *DotNodeSpec, indent string) string {
+	id := r.newID(spec)
+	fmt.Fprintf(&r.sb, "%s%s[\"%s\"]\n", indent, id, escapeMermaidString(spec.DisplayName))
+	return id
+}
+
+// writeSubgraph writes a nested pipeline or DAG as a subgraph, and returns its Mermaid ID
+func (r *mermaidRenderer) writeSubgraph(spec *DotNodeSpec, node RunnableInterface, state StateInterface, indent string) string {
+	id := r.newID(spec)
+	fmt.Fprintf(&r.sb, "%ssubgraph %s[\"%s\"]\n", indent, id, escapeMermaidString(spec.DisplayName))
+	r.writeGraph(node, state, indent+"    ")
+	fmt.Fprintf(&r.sb, "%send\n", indent)
+	return id
+}
+
+// newID returns a new Mermaid ID, and records the style of the node
+func (r *mermaidRenderer) newID(spec *DotNodeSpec) string {
+	r.ids++
+	id := fmt.Sprintf("n%d", r.ids)
+	r.styles = append(r.styles, fmt.Sprintf("style %s %s", id, mermaidNodeStyle(spec)))
+	return id
+}
+
+// mermaidNodeStyle converts the style and fill color of a node to a Mermaid style
+func mermaidNodeStyle(spec *DotNodeSpec) string {
+	style := "fill:" + spec.FillColor
+
+	// White text on filled nodes, like in the DOT graph
+	if spec.Style == nodeStyleFilled {
+		style += ",color:#ffffff"
+	}
+
+	if spec.Style == nodeStyleFilledDashed {
+		style += ",stroke-dasharray:5 5"
+	}
+
+	return style
+}
+
+// escapeMermaidString escapes characters in a string for use in quoted Mermaid labels.
+func escapeMermaidString(s string) string {
+	return mermaidEscaper.Replace(s)
+}
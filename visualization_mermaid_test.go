@@ -0,0 +1,114 @@
+package wf_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dracory/wf"
+)
+
+func newMermaidTestStep(id string, name string, err error) wf.StepInterface {
+	return wf.NewStep(wf.WithID(id), wf.WithName(name), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, err
+	}))
+}
+
+func TestVisualizeMermaid_Nested(t *testing.T) {
+	validate := newMermaidTestStep("validate", `Validate "order" #1`, nil)
+	charge := newMermaidTestStep("charge", "Charge", nil)
+	receipt := newMermaidTestStep("receipt", "Receipt <email>", nil)
+	payment := wf.NewPipeline(wf.WithID("payment"), wf.WithName("Payment"), wf.WithRunnables(charge, receipt))
+	ship := newMermaidTestStep("ship", "Ship", nil)
+
+	dag := wf.NewDag(
+		wf.WithRunnables(validate, payment, ship),
+		wf.WithDependency(payment, validate),
+		wf.WithDependency(ship, payment),
+	)
+
+	expected := `flowchart LR
+    n1["Validate #quot;order#quot; #35;1"]
+    subgraph n2["Payment"]
+        n3["Charge"]
+        n4["Receipt #lt;email#gt;"]
+        n3 --> n4
+    end
+    n5["Ship"]
+    n1 --> n2
+    n2 --> n5
+    style n1 fill:#ffffff
+    style n2 fill:#ffffff
+    style n3 fill:#ffffff
+    style n4 fill:#ffffff
+    style n5 fill:#ffffff
+    linkStyle 0 stroke:#9E9E9E
+    linkStyle 1 stroke:#9E9E9E
+    linkStyle 2 stroke:#9E9E9E
+`
+	if mermaid := dag.VisualizeMermaid(); mermaid != expected {
+		t.Errorf("Unexpected Mermaid flowchart.\nExpected:\n%s\nGot:\n%s", expected, mermaid)
+	}
+}
+
+func TestVisualizeMermaid_Status(t *testing.T) {
+	skipper := newMermaidTestStep("skipper", "Skipper", wf.ErrSkip)
+	failing := newMermaidTestStep("failing", "Failing", context.DeadlineExceeded)
+	other := newMermaidTestStep("other", "Other", nil)
+
+	dag := wf.NewDag(
+		wf.WithRunnables(skipper, failing, other),
+		wf.WithFailurePolicy(wf.FailurePolicyContinue),
+	)
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err == nil {
+		t.Fatal("Expected the DAG to fail")
+	}
+
+	mermaid := dag.VisualizeMermaid()
+
+	// The same colors as in the DOT graph
+	for _, style := range []string{
+		"style n1 fill:#E0E0E0,stroke-dasharray:5 5", // skipped
+		"style n2 fill:#F44336,color:#ffffff",        // failed
+		"style n3 fill:#ffffff",                      // completed, in a failed DAG
+	} {
+		if !strings.Contains(mermaid, style) {
+			t.Errorf("Expected %q in:\n%s", style, mermaid)
+		}
+	}
+
+	// A running pipeline colors its current step and the edges before it
+	first := newMermaidTestStep("first", "First", nil)
+	second := newMermaidTestStep("second", "Second", nil)
+	pipeline := wf.NewPipeline(wf.WithRunnables(first, second))
+	pipeline.GetState().SetStatus(wf.StateStatusRunning)
+	pipeline.GetState().AddCompletedStep("first")
+	pipeline.GetState().SetCurrentStepID("second")
+
+	mermaid = pipeline.VisualizeMermaid()
+	for _, style := range []string{
+		"style n1 fill:#4CAF50,color:#ffffff",
+		"style n2 fill:#2196F3,color:#ffffff",
+		"linkStyle 0 stroke:#4CAF50",
+	} {
+		if !strings.Contains(mermaid, style) {
+			t.Errorf("Expected %q in:\n%s", style, mermaid)
+		}
+	}
+}
+
+func TestVisualizeMermaid_Step(t *testing.T) {
+	step := newMermaidTestStep("step", "Step", nil)
+	if _, _, err := step.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := "flowchart LR\n    n1[\"Step\"]\n    style n1 fill:#4CAF50,color:#ffffff\n"
+	if mermaid := step.VisualizeMermaid(); mermaid != expected {
+		t.Errorf("Expected %q, got %q", expected, mermaid)
+	}
+
+	if mermaid := wf.NewPipeline().VisualizeMermaid(); mermaid != "flowchart LR\n" {
+		t.Errorf("Expected an empty flowchart, got %q", mermaid)
+	}
+}
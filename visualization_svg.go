@@ -0,0 +1,148 @@
+package wf
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Sizes of the SVG drawing, in points
+const (
+	svgArrowLength = 10.0
+	svgArrowWidth  = 7.0
+	svgFontSize    = 14.0
+)
+
+// VisualizeSVG returns an SVG image of the pipeline, laid out in Go,
+// without Graphviz. The nodes are colored like in Visualize.
+func (p *pipelineImplementation) VisualizeSVG() string {
+	return VisualizeSVG(p, p.state)
+}
+
+// VisualizeSVG returns an SVG image of the DAG, laid out in Go,
+// without Graphviz. The nodes are colored like in Visualize.
+func (d *Dag) VisualizeSVG() string {
+	return VisualizeSVG(d, d.state)
+}
+
+// VisualizeSVG returns an SVG image of the step.
+func (s *stepImplementation) VisualizeSVG() string {
+	return VisualizeSVG(s, s.state)
+}
+
+// VisualizeSVG returns an SVG image of a Step, Pipeline or Dag, with the nodes
+// colored by the given state, e.g. the state of a run loaded from a StateStore.
+// It returns an empty image for a runnable implemented outside this package.
+func VisualizeSVG(node RunnableInterface, state StateInterface) string {
+	if step, ok := node.(*stepImplementation); ok {
+		return svgGraph([]*DotNodeSpec{step.nodeSpec(state)}, nil)
+	}
+
+	nodes, edges, _ := graphSpecs(node, state)
+	return svgGraph(nodes, edges)
+}
+
+// svgGraph lays out the node and edge specifications, and draws them as an
+// SVG image, in the same shapes and colors as the Graphviz rendering of the
+// DOT graph
+func svgGraph(nodes []*DotNodeSpec, edges []*DotEdgeSpec) string {
+	layout := layoutGraph(nodes, edges)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<svg width=\"%spt\" height=\"%spt\" viewBox=\"0.00 0.00 %s %s\" xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\">\n",
+		svgNumber(layout.width), svgNumber(layout.height), svgNumber(layout.width), svgNumber(layout.height))
+	sb.WriteString("<g id=\"graph0\" class=\"graph\">\n")
+	fmt.Fprintf(&sb, "<rect x=\"0\" y=\"0\" width=\"%s\" height=\"%s\" fill=\"white\" stroke=\"none\"/>\n",
+		svgNumber(layout.width), svgNumber(layout.height))
+
+	// Edges first, so the nodes are drawn over their ends
+	for i, edge := range edges {
+		writeSvgEdge(&sb, i+1, edge, layout.edges[i])
+	}
+
+	for i, node := range nodes {
+		writeSvgNode(&sb, i+1, node, layout.nodes[i])
+	}
+
+	sb.WriteString("</g>\n</svg>\n")
+	return sb.String()
+}
+
+// writeSvgNode draws a node, as a box with its label and tooltip
+func writeSvgNode(sb *strings.Builder, number int, node *DotNodeSpec, box layoutBox) {
+	attrs := ""
+	if node.Style == nodeStyleFilledDashed {
+		attrs = " stroke-dasharray=\"5,2\""
+	}
+
+	// White text on filled nodes, like in the DOT graph
+	textColor := "black"
+	if node.Style == nodeStyleFilled {
+		textColor = "white"
+	}
+
+	fmt.Fprintf(sb, "<g id=\"node%d\" class=\"node\">\n", number)
+	fmt.Fprintf(sb, "<title>%s</title>\n", escapeSvgString(node.Name))
+	fmt.Fprintf(sb, "<a xlink:title=\"%s\">\n", escapeSvgString(node.Tooltip))
+	fmt.Fprintf(sb, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\" stroke=\"black\"%s/>\n",
+		svgNumber(box.x), svgNumber(box.y), svgNumber(box.width), svgNumber(box.height), node.FillColor, attrs)
+	fmt.Fprintf(sb, "<text text-anchor=\"middle\" x=\"%s\" y=\"%s\" font-family=\"Arial\" font-size=\"%s\" fill=\"%s\">%s</text>\n",
+		svgNumber(box.x+box.width/2), svgNumber(box.y+box.height/2+svgFontSize/3), svgNumber(svgFontSize), textColor, escapeSvgString(node.DisplayName))
+	sb.WriteString("</a>\n</g>\n")
+}
+
+// writeSvgEdge draws an edge, as a curve through the points of its route,
+// ending with an arrow head at its target
+func writeSvgEdge(sb *strings.Builder, number int, edge *DotEdgeSpec, path layoutPath) {
+	if len(path.points) < 2 {
+		return
+	}
+
+	points := append([]layoutPoint(nil), path.points...)
+
+	// The curve stops at the base of the arrow head
+	end := points[len(points)-1]
+	direction := 1.0
+	if points[len(points)-2].x > end.x {
+		direction = -1.0
+	}
+	points[len(points)-1].x -= direction * svgArrowLength
+
+	var d strings.Builder
+	fmt.Fprintf(&d, "M%s,%s", svgNumber(points[0].x), svgNumber(points[0].y))
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+		middle := (from.x + to.x) / 2
+		fmt.Fprintf(&d, " C%s,%s %s,%s %s,%s",
+			svgNumber(middle), svgNumber(from.y),
+			svgNumber(middle), svgNumber(to.y),
+			svgNumber(to.x), svgNumber(to.y))
+	}
+
+	attrs := ""
+	if edge.Style == edgeStyleDashed {
+		attrs = " stroke-dasharray=\"5,2\""
+	}
+
+	base := end.x - direction*svgArrowLength
+	fmt.Fprintf(sb, "<g id=\"edge%d\" class=\"edge\">\n", number)
+	fmt.Fprintf(sb, "<title>%s</title>\n", escapeSvgString(edge.FromNodeName+"->"+edge.ToNodeName))
+	fmt.Fprintf(sb, "<a xlink:title=\"%s\">\n", escapeSvgString(edge.Tooltip))
+	fmt.Fprintf(sb, "<path fill=\"none\" stroke=\"%s\"%s d=\"%s\"/>\n", edge.Color, attrs, d.String())
+	fmt.Fprintf(sb, "<polygon fill=\"%s\" stroke=\"%s\" points=\"%s,%s %s,%s %s,%s\"/>\n",
+		edge.Color, edge.Color,
+		svgNumber(end.x), svgNumber(end.y),
+		svgNumber(base), svgNumber(end.y-svgArrowWidth/2),
+		svgNumber(base), svgNumber(end.y+svgArrowWidth/2))
+	sb.WriteString("</a>\n</g>\n")
+}
+
+// svgNumber formats a coordinate with two decimals, like Graphviz
+func svgNumber(n float64) string {
+	return fmt.Sprintf("%.2f", n)
+}
+
+// escapeSvgString escapes characters in a string for use in SVG text and attributes.
+func escapeSvgString(s string) string {
+	return html.EscapeString(s)
+}
@@ -0,0 +1,84 @@
+package wf_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dracory/wf"
+)
+
+func TestVisualizeSVG(t *testing.T) {
+	validate := newMermaidTestStep("validate", "Validate <order> & pay", nil)
+	charge := newMermaidTestStep("charge", "Charge", errors.New("card declined"))
+	ship := newMermaidTestStep("ship", "Ship", nil)
+
+	dag := wf.NewDag(
+		wf.WithRunnables(validate, charge, ship),
+		wf.WithDependency(charge, validate),
+		wf.WithDependency(ship, charge),
+	)
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err == nil {
+		t.Fatal("Expected the DAG to fail")
+	}
+
+	svg := dag.VisualizeSVG()
+
+	// The image is well formed XML
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				t.Fatalf("Invalid SVG: %v\n%s", err, svg)
+			}
+			break
+		}
+	}
+
+	for _, expected := range []string{
+		`<svg width="`,
+		`<g id="node1" class="node">`,
+		`>Validate &lt;order&gt; &amp; pay</text>`,
+		`<g id="edge2" class="edge">`,
+		`<title>charge-&gt;ship</title>`,
+		`fill="#F44336" stroke="black"/>`, // the failed step
+		`<a xlink:title="From Validate &lt;order&gt; &amp; pay to Charge">`,
+	} {
+		if !strings.Contains(svg, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, svg)
+		}
+	}
+
+	if svg != dag.VisualizeSVG() {
+		t.Error("Expected the same image for the same state")
+	}
+	if wf.VisualizeSVG(dag, dag.GetState()) != svg {
+		t.Error("Expected the image of the DAG, with the state of its run")
+	}
+}
+
+func TestVisualizeSVG_Pipeline(t *testing.T) {
+	skipped := newMermaidTestStep("skipped", "Skipped", wf.ErrSkip)
+	last := newMermaidTestStep("last", "Last", nil)
+	pipeline := wf.NewPipeline(wf.WithRunnables(skipped, last))
+	if _, _, err := pipeline.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	svg := pipeline.VisualizeSVG()
+	if strings.Count(svg, `class="node"`) != 2 || strings.Count(svg, `class="edge"`) != 1 {
+		t.Errorf("Expected 2 nodes and 1 edge, got:\n%s", svg)
+	}
+	if !strings.Contains(svg, `fill="#E0E0E0" stroke="black" stroke-dasharray="5,2"/>`) {
+		t.Errorf("Expected the skipped step to be dashed, got:\n%s", svg)
+	}
+
+	step := newMermaidTestStep("single", "Single", nil)
+	if svg := step.VisualizeSVG(); strings.Count(svg, `class="node"`) != 1 || !strings.Contains(svg, ">Single</text>") {
+		t.Errorf("Unexpected image of a step:\n%s", svg)
+	}
+}
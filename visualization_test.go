@@ -697,3 +697,155 @@ func TestStepVisualization(t *testing.T) {
 		t.Errorf("Paused step should be colored yellow. Expected substring: %s\nGot DOT:\n%s", stepNodeDefPaused, dot)
 	}
 }
+
+func TestDagVisualization_ConditionalDependency(t *testing.T) {
+	step1 := wf.NewStep(wf.WithName("Step 1"))
+	step2 := wf.NewStep(wf.WithName("Step 2"))
+
+	dag := wf.NewDag(
+		wf.WithRunnables(step1, step2),
+		wf.WithDependencyIf(step2, func(ctx context.Context, data map[string]any) bool {
+			return true
+		}, step1),
+	)
+
+	dot := dag.Visualize()
+
+	edgeDef := fmt.Sprintf(`"%s" -> "%s" [style=dashed, tooltip="From Step 1 to Step 2", color="#9E9E9E"]`, step1.GetID(), step2.GetID())
+	if !strings.Contains(dot, edgeDef) {
+		t.Errorf("Conditional dependency should be a dashed edge. Expected substring: %s\nGot DOT:\n%s", edgeDef, dot)
+	}
+}
+
+func TestDagVisualization_Skipped(t *testing.T) {
+	step1 := wf.NewStep(wf.WithName("Step 1"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, wf.ErrSkip
+	}))
+	step2 := wf.NewStep(wf.WithName("Step 2"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	step3 := wf.NewStep(wf.WithName("Step 3"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+
+	dag := wf.NewDag(
+		wf.WithRunnables(step1, step2, step3),
+		wf.WithDependency(step2, step1),
+	)
+
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	dot := dag.Visualize()
+
+	for _, step := range []wf.StepInterface{step1, step2} {
+		nodeDef := fmt.Sprintf(`"%s" [label="%s", style="filled,dashed", tooltip="Step: %s", fillcolor="#E0E0E0"]`, step.GetID(), step.GetName(), step.GetName())
+		if !strings.Contains(dot, nodeDef) {
+			t.Errorf("Skipped step should be drawn dashed and silver. Expected substring: %s\nGot DOT:\n%s", nodeDef, dot)
+		}
+	}
+
+	// Not skipped step keeps the regular style
+	nodeDef := fmt.Sprintf(`"%s" [label="Step 3", style=solid, tooltip="Step: Step 3", fillcolor="#ffffff"]`, step3.GetID())
+	if !strings.Contains(dot, nodeDef) {
+		t.Errorf("Completed step should have the default style. Expected substring: %s\nGot DOT:\n%s", nodeDef, dot)
+	}
+
+	// The skipped step itself is drawn with the same style
+	dot = step1.Visualize()
+	stepNodeDef := fmt.Sprintf(`"%s" [label="Step 1", style="filled,dashed", tooltip="Step: Step 1", fillcolor="#E0E0E0"]`, step1.GetID())
+	if !strings.Contains(dot, stepNodeDef) {
+		t.Errorf("Skipped step should be drawn dashed and silver. Expected substring: %s\nGot DOT:\n%s", stepNodeDef, dot)
+	}
+}
+
+func TestDagVisualization_Expanded(t *testing.T) {
+	newStep := func(id string, name string) wf.StepInterface {
+		return wf.NewStep(wf.WithID(id), wf.WithName(name), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+			return ctx, data, nil
+		}))
+	}
+
+	email := newStep("email", "Email")
+	sms := newStep("sms", "SMS")
+	notify := wf.NewDag(wf.WithID("notify"), wf.WithName("Notify"), wf.WithRunnables(email, sms))
+	payment := wf.NewPipeline(wf.WithID("payment"), wf.WithName("Payment"), wf.WithRunnables(newStep("charge", "Charge"), notify))
+	validate := newStep("validate", "Validate")
+	ship := newStep("ship", "Ship")
+
+	dag := wf.NewDag(
+		wf.WithRunnables(validate, payment, ship),
+		wf.WithDependency(payment, validate),
+		wf.WithDependency(ship, payment),
+	)
+
+	expected := `digraph {
+	rankdir = "LR"; // Left-to-right layout
+	node [fontname="Arial", shape=box]; // Default node attributes
+	edge [fontname="Arial"]; // Default edge attributes
+
+	// Nodes
+	"validate" [label="Validate", style=solid, tooltip="Step: Validate", fillcolor="#ffffff"];
+	"ship" [label="Ship", style=solid, tooltip="Step: Ship", fillcolor="#ffffff"];
+
+	// Clusters
+	subgraph "cluster_payment" {
+		label="Payment"; tooltip="Step: Payment"; style=rounded; color="#9E9E9E";
+		"payment/charge" [label="Charge", style=solid, tooltip="Step: Charge", fillcolor="#ffffff"];
+		subgraph "cluster_payment/notify" {
+			label="Notify"; tooltip="Step: Notify"; style=rounded; color="#9E9E9E";
+			"payment/notify/email" [label="Email", style=solid, tooltip="Step: Email", fillcolor="#ffffff"];
+			"payment/notify/sms" [label="SMS", style=solid, tooltip="Step: SMS", fillcolor="#ffffff"];
+		}
+	}
+
+	// Edges
+	"payment/charge" -> "payment/notify/email" [style=solid, tooltip="From Charge to Notify", color="#9E9E9E"];
+	"payment/charge" -> "payment/notify/sms" [style=solid, tooltip="From Charge to Notify", color="#9E9E9E"];
+	"validate" -> "payment/charge" [style=solid, tooltip="From Validate to Payment", color="#9E9E9E"];
+	"payment/notify/email" -> "ship" [style=solid, tooltip="From Payment to Ship", color="#9E9E9E"];
+	"payment/notify/sms" -> "ship" [style=solid, tooltip="From Payment to Ship", color="#9E9E9E"];
+}
+`
+	if dot := dag.VisualizeExpanded(); dot != expected {
+		t.Errorf("Unexpected expanded DOT graph.\nExpected:\n%s\nGot:\n%s", expected, dot)
+	}
+
+	// Without nested nodes, the expanded graph is the same as the graph
+	if dot := validate.VisualizeExpanded(); dot != validate.Visualize() {
+		t.Errorf("Expected the expanded graph of a step to be its graph, got:\n%s", dot)
+	}
+	if empty := wf.NewDag(); empty.VisualizeExpanded() != empty.Visualize() {
+		t.Error("Expected the expanded graph of an empty DAG to be its graph")
+	}
+}
+
+func TestDagVisualization_ExpandedStatus(t *testing.T) {
+	charge := wf.NewStep(wf.WithID("charge"), wf.WithName("Charge"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	receipt := wf.NewStep(wf.WithID("receipt"), wf.WithName("Receipt"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, fmt.Errorf("mail server down")
+	}))
+	payment := wf.NewPipeline(wf.WithID("payment"), wf.WithName("Payment"), wf.WithRunnables(charge, receipt))
+	dag := wf.NewDag(wf.WithRunnables(payment))
+
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err == nil {
+		t.Fatal("Expected the DAG to fail")
+	}
+
+	dot := dag.VisualizeExpanded()
+
+	// The failed step is colored by the state of the pipeline,
+	// and the cluster by the state of the pipeline in the DAG
+	for _, expected := range []string{
+		`subgraph "cluster_payment" {`,
+		`style=rounded; color="#F44336";`,
+		`"payment/receipt" [label="Receipt", style=filled, tooltip="Step: Receipt", fillcolor="#F44336", fontcolor="white"];`,
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, dot)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package wf
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// textDefaultWidth is the width of the text rendering, in characters
+const textDefaultWidth = 80
+
+// ANSI escape codes of the colors of the text rendering
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiBlue   = "\033[34m"
+	ansiGrey   = "\033[90m"
+)
+
+// ansiColors are the ANSI colors of the colors of the statuses (see StatusColor)
+var ansiColors = map[string]string{
+	colorGreen:  ansiGreen,
+	colorRed:    ansiRed,
+	colorYellow: ansiYellow,
+	colorBlue:   ansiBlue,
+	colorSilver: ansiGrey,
+}
+
+// textCharset holds the characters of the text rendering
+type textCharset struct {
+	topLeft, topRight, bottomLeft, bottomRight string
+	horizontal, vertical                       string
+	arrowDown, arrowLeft, ellipsis             string
+	markers                                    map[StateStatus]string
+	pending                                    string
+}
+
+// unicodeCharset draws boxes with the Unicode box drawing characters
+var unicodeCharset = textCharset{
+	topLeft: "┌", topRight: "┐", bottomLeft: "└", bottomRight: "┘",
+	horizontal: "─", vertical: "│",
+	arrowDown: "▼", arrowLeft: "←", ellipsis: "…",
+	markers: map[StateStatus]string{
+		StateStatusComplete: "✓",
+		StateStatusFailed:   "✗",
+		StateStatusPaused:   "⏸",
+		StateStatusRunning:  "▶",
+		StateStatusSkipped:  "↷",
+	},
+	pending: "○",
+}
+
+// asciiCharset draws boxes with ASCII characters only
+var asciiCharset = textCharset{
+	topLeft: "+", topRight: "+", bottomLeft: "+", bottomRight: "+",
+	horizontal: "-", vertical: "|",
+	arrowDown: "v", arrowLeft: "<-", ellipsis: "~",
+	markers: map[StateStatus]string{
+		StateStatusComplete: "+",
+		StateStatusFailed:   "x",
+		StateStatusPaused:   "=",
+		StateStatusRunning:  ">",
+		StateStatusSkipped:  "-",
+	},
+	pending: "o",
+}
+
+// textOptions are the options of VisualizeText
+type textOptions struct {
+	width   int
+	color   bool
+	charset textCharset
+}
+
+// TextOption configures the text rendering of VisualizeText
+type TextOption func(*textOptions)
+
+// WithTextWidth sets the width of the text rendering, 80 characters by default.
+// The nodes of a layer wider than this are wrapped to several rows, and the
+// names too long for a box are cut. A width of 0 or less disables the limit.
+func WithTextWidth(width int) TextOption {
+	return func(o *textOptions) {
+		o.width = width
+	}
+}
+
+// WithTextColor colors the nodes by their status, with ANSI escape codes
+func WithTextColor() TextOption {
+	return func(o *textOptions) {
+		o.color = true
+	}
+}
+
+// WithTextASCII draws with ASCII characters only, for terminals and logs
+// without Unicode support
+func WithTextASCII() TextOption {
+	return func(o *textOptions) {
+		o.charset = asciiCharset
+	}
+}
+
+// VisualizeText returns a text representation of the pipeline, for terminals and logs.
+func (p *pipelineImplementation) VisualizeText() string {
+	return VisualizeText(p, p.state)
+}
+
+// VisualizeText returns a text representation of the DAG, for terminals and logs.
+func (d *Dag) VisualizeText() string {
+	return VisualizeText(d, d.state)
+}
+
+// VisualizeText returns a text representation of the step, for terminals and logs.
+func (s *stepImplementation) VisualizeText() string {
+	return VisualizeText(s, s.state)
+}
+
+// VisualizeText returns a text representation of a Step, Pipeline or Dag, with
+// its nodes drawn as boxes in layers from top to bottom: each node is below the
+// nodes it depends on, which are listed in its box for a DAG. The nodes are
+// marked by their status in the given state: ✓ complete, ✗ failed, ⏸ paused,
+// ▶ running, ↷ skipped and ○ not started.
+//
+// Example:
+//   fmt.Println(wf.VisualizeText(dag, dag.GetState(), wf.WithTextColor()))
+//
+//   Orders ✗ failed
+//   ┌────────────┐ ┌─────────┐
+//   │ ✓ Validate │ │ ✓ Stock │
+//   └────────────┘ └─────────┘
+//             ▼
+//   ┌───────────────────┐
+//   │ ✗ Charge          │
+//   │ ← Validate, Stock │
+//   └───────────────────┘
+func VisualizeText(node RunnableInterface, state StateInterface, opts ...TextOption) string {
+	options := &textOptions{width: textDefaultWidth, charset: unicodeCharset}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	r := &textRenderer{options: options}
+
+	// A new state is running, so the status of a node that did not start is empty
+	var status StateStatus
+	if timed, ok := state.(TimedState); state != nil && (!ok || !timed.GetStartedAt().IsZero()) {
+		status = state.GetStatus()
+	}
+	r.writeLine(r.colorize(status, textLabel(node)+" "+r.marker(status)+" "+textStatusName(status)))
+
+	nodes, edges, ok := graphSpecs(node, state)
+	if !ok {
+		// A step is a single box
+		r.writeLayers([][]*textBox{{r.newBox(textLabel(node), status, nil)}})
+		return r.sb.String()
+	}
+
+	_, isDag := node.(*Dag)
+
+	// The dependencies of each node, the conditional ones marked
+	dependencies := make(map[string][]string, len(nodes))
+	displayNames := make(map[string]string, len(nodes))
+	for _, spec := range nodes {
+		displayNames[spec.Name] = spec.DisplayName
+	}
+	for _, edge := range edges {
+		name := displayNames[edge.FromNodeName]
+		if edge.Style == edgeStyleDashed {
+			name += " (if)"
+		}
+		dependencies[edge.ToNodeName] = append(dependencies[edge.ToNodeName], name)
+	}
+
+	layers := [][]*textBox{}
+	for _, layer := range textLayers(nodes, edges) {
+		boxes := []*textBox{}
+		for _, spec := range layer {
+			var deps []string
+			if isDag {
+				deps = dependencies[spec.Name]
+			}
+			boxes = append(boxes, r.newBox(spec.DisplayName, textNodeStatus(state, spec.Name), deps))
+		}
+		layers = append(layers, boxes)
+	}
+
+	r.writeLayers(layers)
+	return r.sb.String()
+}
+
+// textRenderer writes the text representation of a graph
+type textRenderer struct {
+	options *textOptions
+	sb      strings.Builder
+}
+
+// textBox is a node drawn as a box, with its lines without the borders
+type textBox struct {
+	lines  []string
+	width  int // Width of the lines, without the borders
+	status StateStatus
+}
+
+// newBox returns the box of a node, with its status marker and name, and its
+// dependencies. The lines too long for the width are cut, or wrapped for the
+// dependencies.
+func (r *textRenderer) newBox(name string, status StateStatus, dependencies []string) *textBox {
+	maxWidth := 0
+	if r.options.width > 0 {
+		// The borders and their padding take 4 characters
+		maxWidth = max(r.options.width-4, 8)
+	}
+
+	box := &textBox{status: status}
+	box.lines = append(box.lines, r.cut(r.marker(status)+" "+name, maxWidth))
+
+	if len(dependencies) > 0 {
+		prefix := r.options.charset.arrowLeft + " "
+		indent := strings.Repeat(" ", utf8.RuneCountInString(prefix))
+		line := prefix
+		for i, dependency := range dependencies {
+			if i < len(dependencies)-1 {
+				dependency += ","
+			}
+			if line != prefix && line != indent && maxWidth > 0 && textWidth(line+" "+dependency) > maxWidth {
+				box.lines = append(box.lines, r.cut(line, maxWidth))
+				line = indent
+			}
+			if line != prefix && line != indent {
+				line += " "
+			}
+			line += dependency
+		}
+		box.lines = append(box.lines, r.cut(line, maxWidth))
+	}
+
+	for _, line := range box.lines {
+		box.width = max(box.width, textWidth(line))
+	}
+
+	return box
+}
+
+// writeLayers writes the boxes of the layers, with arrows between the layers.
+// The boxes of a layer wider than the width are wrapped to several rows.
+func (r *textRenderer) writeLayers(layers [][]*textBox) {
+	for l, layer := range layers {
+		rows := r.wrap(layer)
+
+		if l > 0 {
+			// An arrow above each box of the first row of the layer
+			var arrows strings.Builder
+			for i, box := range rows[0] {
+				if i > 0 {
+					arrows.WriteString(" ")
+				}
+				center := (box.width + 4) / 2
+				arrows.WriteString(strings.Repeat(" ", center))
+				arrows.WriteString(r.options.charset.arrowDown)
+				arrows.WriteString(strings.Repeat(" ", box.width+4-center-1))
+			}
+			r.writeLine(strings.TrimRight(arrows.String(), " "))
+		}
+
+		for _, row := range rows {
+			r.writeRow(row)
+		}
+	}
+}
+
+// wrap splits the boxes of a layer into rows that fit in the width
+func (r *textRenderer) wrap(boxes []*textBox) [][]*textBox {
+	rows := [][]*textBox{}
+	row := []*textBox{}
+	rowWidth := 0
+
+	for _, box := range boxes {
+		boxWidth := box.width + 4
+		if len(row) > 0 && r.options.width > 0 && rowWidth+1+boxWidth > r.options.width {
+			rows = append(rows, row)
+			row, rowWidth = nil, 0
+		}
+		if len(row) > 0 {
+			rowWidth++
+		}
+		row = append(row, box)
+		rowWidth += boxWidth
+	}
+
+	return append(rows, row)
+}
+
+// writeRow writes a row of boxes side by side, with the same height
+func (r *textRenderer) writeRow(row []*textBox) {
+	charset := r.options.charset
+
+	height := 0
+	for _, box := range row {
+		height = max(height, len(box.lines))
+	}
+
+	parts := make([][]string, height+2)
+	for _, box := range row {
+		border := strings.Repeat(charset.horizontal, box.width+2)
+		parts[0] = append(parts[0], r.colorize(box.status, charset.topLeft+border+charset.topRight))
+		for i := 0; i < height; i++ {
+			line := ""
+			if i < len(box.lines) {
+				line = box.lines[i]
+			}
+			line += strings.Repeat(" ", box.width-textWidth(line))
+			parts[i+1] = append(parts[i+1], r.colorize(box.status, charset.vertical+" "+line+" "+charset.vertical))
+		}
+		parts[height+1] = append(parts[height+1], r.colorize(box.status, charset.bottomLeft+border+charset.bottomRight))
+	}
+
+	for _, line := range parts {
+		r.writeLine(strings.Join(line, " "))
+	}
+}
+
+// writeLine writes a line of the text
+func (r *textRenderer) writeLine(line string) {
+	r.sb.WriteString(line)
+	r.sb.WriteString("\n")
+}
+
+// marker returns the marker of a status
+func (r *textRenderer) marker(status StateStatus) string {
+	if marker, ok := r.options.charset.markers[status]; ok {
+		return marker
+	}
+	return r.options.charset.pending
+}
+
+// colorize colors a text by a status, if the colors are enabled,
+// with the ANSI color nearest to the color of the status in the graphs
+func (r *textRenderer) colorize(status StateStatus, text string) string {
+	if !r.options.color || status == "" {
+		return text
+	}
+
+	color, ok := ansiColors[StatusColor(status)]
+	if !ok {
+		return text
+	}
+
+	return color + text + ansiReset
+}
+
+// cut cuts a line to the given width, ending it with an ellipsis.
+// A width of 0 or less does not cut the line.
+func (r *textRenderer) cut(line string, width int) string {
+	if width <= 0 || textWidth(line) <= width {
+		return line
+	}
+
+	ellipsis := r.options.charset.ellipsis
+	runes := []rune(line)
+	return string(runes[:width-textWidth(ellipsis)]) + ellipsis
+}
+
+// textWidth returns the width of a text, in characters
+func textWidth(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// textLabel returns the name of a node, or its ID if it has no name
+func textLabel(node RunnableInterface) string {
+	if node.GetName() != "" {
+		return node.GetName()
+	}
+	return node.GetID()
+}
+
+// textStatusName returns the name of a status, "pending" for a node not started
+func textStatusName(status StateStatus) string {
+	if status == "" {
+		return "pending"
+	}
+	return string(status)
+}
+
+// textNodeStatus returns the status of a node of a Pipeline or Dag, from the
+// state of the Pipeline or Dag: the completed, skipped and failed nodes it
+// records, and the states of the nodes that started. It is empty for a node
+// that did not start.
+func textNodeStatus(state StateInterface, nodeID string) StateStatus {
+	if state == nil {
+		return ""
+	}
+
+	switch {
+	case isSkippedStep(state, nodeID):
+		return StateStatusSkipped
+	case isFailedStep(state, nodeID):
+		return StateStatusFailed
+	case slices.Contains(state.GetCompletedSteps(), nodeID):
+		return StateStatusComplete
+	}
+
+	if child := stateChild(state, nodeID); child != nil {
+		switch status := child.GetStatus(); status {
+		case StateStatusRunning, StateStatusPaused, StateStatusFailed:
+			return status
+		}
+	}
+
+	return ""
+}
+
+// textLayers puts the nodes in layers as in the graph layout (see layoutLayers),
+// each node one layer after its latest dependency, keeping the order of the
+// nodes in each layer
+func textLayers(nodes []*DotNodeSpec, edges []*DotEdgeSpec) [][]*DotNodeSpec {
+	nodeLayers, _ := layoutLayers(nodes, edges)
+
+	layers := [][]*DotNodeSpec{}
+	for i, spec := range nodes {
+		for len(layers) <= nodeLayers[i] {
+			layers = append(layers, nil)
+		}
+		layers[nodeLayers[i]] = append(layers[nodeLayers[i]], spec)
+	}
+
+	return layers
+}
@@ -0,0 +1,173 @@
+package wf_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/dracory/wf"
+)
+
+func newTextTestDag() wf.DagInterface {
+	validate := newMermaidTestStep("validate", "Validate", nil)
+	stock := newMermaidTestStep("stock", "Stock", nil)
+	charge := newMermaidTestStep("charge", "Charge", errors.New("card declined"))
+	gift := newMermaidTestStep("gift", "Gift wrap", nil)
+	ship := newMermaidTestStep("ship", "Ship", nil)
+
+	return wf.NewDag(
+		wf.WithName("Orders"),
+		wf.WithRunnables(validate, stock, charge, ship, gift),
+		wf.WithDependency(charge, validate, stock),
+		wf.WithDependencyIf(gift, func(ctx context.Context, data map[string]any) bool {
+			return false
+		}, charge),
+		wf.WithDependency(ship, charge),
+	)
+}
+
+func TestVisualizeText(t *testing.T) {
+	dag := newTextTestDag()
+
+	expected := `Orders ○ pending
+┌────────────┐ ┌─────────┐
+│ ○ Validate │ │ ○ Stock │
+└────────────┘ └─────────┘
+          ▼
+┌───────────────────┐
+│ ○ Charge          │
+│ ← Validate, Stock │
+└───────────────────┘
+      ▼              ▼
+┌──────────┐ ┌───────────────┐
+│ ○ Ship   │ │ ○ Gift wrap   │
+│ ← Charge │ │ ← Charge (if) │
+└──────────┘ └───────────────┘
+`
+	if text := dag.VisualizeText(); text != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, text)
+	}
+
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err == nil {
+		t.Fatal("Expected the DAG to fail")
+	}
+
+	text := dag.VisualizeText()
+	for _, expected := range []string{
+		"Orders ✗ failed\n",
+		"│ ✓ Validate │ │ ✓ Stock │",
+		"│ ✗ Charge          │",
+		"│ ○ Ship   │",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, text)
+		}
+	}
+
+	if wf.VisualizeText(dag, dag.GetState()) != text {
+		t.Error("Expected the text of the DAG, with the state of its run")
+	}
+}
+
+func TestVisualizeText_Options(t *testing.T) {
+	dag := newTextTestDag()
+	if _, _, err := dag.Run(context.Background(), map[string]any{}); err == nil {
+		t.Fatal("Expected the DAG to fail")
+	}
+
+	// A narrow width wraps the layers and the dependencies
+	expected := `Orders x failed
++------------+
+| + Validate |
++------------+
++---------+
+| + Stock |
++---------+
+        v
++--------------+
+| x Charge     |
+| <- Validate, |
+|    Stock     |
++--------------+
+      v
++-----------+
+| o Ship    |
+| <- Charge |
++-----------+
++----------------+
+| o Gift wrap    |
+| <- Charge (if) |
++----------------+
+`
+	text := wf.VisualizeText(dag, dag.GetState(), wf.WithTextASCII(), wf.WithTextWidth(20))
+	if text != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, text)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if len(line) > 20 {
+			t.Errorf("Expected lines of at most 20 characters, got %q", line)
+		}
+	}
+
+	colored := wf.VisualizeText(dag, dag.GetState(), wf.WithTextColor())
+	for _, expected := range []string{
+		"\033[31mOrders ✗ failed\033[0m\n",
+		"\033[32m│ ✓ Validate │\033[0m",
+		"\033[31m│ ✗ Charge          │\033[0m",
+		"\n│ ○ Ship   │", // nodes that did not start are not colored
+	} {
+		if !strings.Contains(colored, expected) {
+			t.Errorf("Expected %q in:\n%q", expected, colored)
+		}
+	}
+
+	// Names too long for the width are cut
+	long := newMermaidTestStep("long", "A step with a very long name", nil)
+	text = wf.VisualizeText(long, nil, wf.WithTextWidth(20))
+	if !strings.Contains(text, "│ ○ A step with a… │") {
+		t.Errorf("Expected the name to be cut in:\n%s", text)
+	}
+
+	// Dependencies too long for the width are cut, on every line
+	first := newMermaidTestStep("first", "A very long dependency name", nil)
+	second := newMermaidTestStep("second", "Another long dependency", nil)
+	last := newMermaidTestStep("last", "Last", nil)
+	dependent := wf.NewDag(
+		wf.WithRunnables(first, second, last),
+		wf.WithDependency(last, first, second),
+	)
+	text = wf.VisualizeText(dependent, nil, wf.WithTextWidth(20))
+	for _, line := range strings.Split(text, "\n") {
+		if utf8.RuneCountInString(line) > 20 {
+			t.Errorf("Expected lines of at most 20 characters, got %q", line)
+		}
+	}
+}
+
+func TestVisualizeText_Pipeline(t *testing.T) {
+	pipeline := wf.NewPipeline(
+		wf.WithName("Checkout"),
+		wf.WithRunnables(
+			newMermaidTestStep("cart", "Cart", nil),
+			newMermaidTestStep("pay", "Pay", nil),
+		),
+	)
+	if _, _, err := pipeline.Run(context.Background(), map[string]any{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `Checkout ✓ complete
+┌────────┐
+│ ✓ Cart │
+└────────┘
+    ▼
+┌───────┐
+│ ✓ Pay │
+└───────┘
+`
+	if text := pipeline.VisualizeText(); text != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, text)
+	}
+}
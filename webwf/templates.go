@@ -0,0 +1,137 @@
+package webwf
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/dracory/wf"
+)
+
+// layoutStyle is the style sheet of the pages, with no external resources
+const layoutStyle = `
+body { font-family: Arial, sans-serif; margin: 24px; color: #212121; }
+a { color: #1565C0; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 6px 12px; border-bottom: 1px solid #E0E0E0; }
+.status { display: inline-block; padding: 2px 8px; border-radius: 10px; color: white; font-size: 13px; }
+.actions form { display: inline; }
+.actions button { margin-right: 8px; }
+.graph { margin: 16px 0; overflow: auto; }
+.events { font-family: monospace; font-size: 13px; max-height: 240px; overflow: auto; }
+`
+
+// runsTemplate is the page listing the runs. It is reloaded when a run starts or ends.
+var runsTemplate = template.Must(template.New("runs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>` + layoutStyle + `</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+{{if .Runs}}<table>
+<tr><th>Run</th><th>Status</th><th>Started</th><th>Updated</th></tr>
+{{range .Runs}}<tr>
+<td><a href="{{.URL}}">{{.ID}}</a>{{if .Active}} &#9654;{{end}}</td>
+<td><span class="status" style="background: {{.Color}}">{{.Status}}</span></td>
+<td>{{.StartedAt}}</td>
+<td>{{.LastUpdated}}</td>
+</tr>
+{{end}}</table>
+{{if or .NewerURL .OlderURL}}<p>{{if .NewerURL}}<a href="{{.NewerURL}}">Newer runs</a>{{end}}
+{{if .OlderURL}}<a href="{{.OlderURL}}">Older runs</a>{{end}}</p>
+{{end}}{{else}}<p>No runs yet.</p>
+{{end}}<script>
+const source = new EventSource("events");
+source.onmessage = (message) => {
+  const event = JSON.parse(message.data);
+  if (event.path.length === 1 && event.type !== "node_started") {
+    location.reload();
+  }
+};
+</script>
+</body>
+</html>
+`))
+
+// runTemplate is the page of a run. Its graph is reloaded on every event of
+// the run, and the page when the run ends, to update its actions.
+var runTemplate = template.Must(template.New("run").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - {{.ID}}</title>
+<style>` + layoutStyle + `</style>
+</head>
+<body>
+<p><a href="../">{{.Name}}</a></p>
+<h1>{{.ID}}</h1>
+<p>
+<span class="status" id="status" style="background: {{.Color}}">{{.Status}}</span>
+{{if .StartedAt}}started {{.StartedAt}}, {{end}}updated {{.LastUpdated}}
+- <a href="{{.TimelineURL}}">timeline</a>
+</p>
+{{if .Error}}<p>{{.Error}}</p>
+{{end}}<p class="actions">
+{{if .CanPause}}<form method="post" action="{{.PauseURL}}"><button>Pause</button></form>{{end}}
+{{if .CanResume}}<form method="post" action="{{.ResumeURL}}"><button>Resume</button></form>{{end}}
+{{if .CanCancel}}<form method="post" action="{{.CancelURL}}"><button>Cancel</button></form>{{end}}
+{{if .CanRetry}}<form method="post" action="{{.RetryURL}}"><button>Retry failed nodes</button></form>{{end}}
+</p>
+<div class="graph" id="graph">{{.Graph}}</div>
+<h2>Events</h2>
+<div class="events" id="events"></div>
+<script>
+const graphURL = {{.GraphURL}};
+const source = new EventSource({{.EventsURL}});
+let refreshing = false;
+
+function refreshGraph() {
+  if (refreshing) {
+    return;
+  }
+  refreshing = true;
+  fetch(graphURL)
+    .then((response) => response.text())
+    .then((svg) => { document.getElementById("graph").innerHTML = svg; })
+    .finally(() => { refreshing = false; });
+}
+
+source.onmessage = (message) => {
+  const event = JSON.parse(message.data);
+
+  const line = document.createElement("div");
+  line.textContent = event.time + " " + event.type + " " + event.path.join(" > ") + (event.error ? ": " + event.error : "");
+  document.getElementById("events").prepend(line);
+
+  if (event.path.length === 1 && event.type !== "workflow_started" && event.type !== "resumed") {
+    location.reload();
+    return;
+  }
+  refreshGraph();
+};
+</script>
+</body>
+</html>
+`))
+
+// render writes a page, or the error of its template
+func (d *dashboardImplementation) render(w http.ResponseWriter, page *template.Template, data map[string]any) {
+	var buffer bytes.Buffer
+	if err := page.Execute(&buffer, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = buffer.WriteTo(w)
+}
+
+// graphHTML returns the graph of a run, to embed in its page. The SVG image is
+// generated by wf.VisualizeSVG, which escapes the names of the nodes.
+func graphHTML(workflow Workflow, state wf.StateInterface) template.HTML {
+	return template.HTML(wf.VisualizeSVG(workflow, state))
+}
@@ -0,0 +1,609 @@
+// Package webwf serves a web dashboard of workflow runs.
+//
+// The dashboard lists the runs saved in the state store of a workflow, and
+// shows the graph of each run, colored by the status of its nodes. The pages
+// are updated live, with Server-Sent Events fed by the events of the runs.
+// The runs started by the dashboard can be paused and canceled from it, the
+// paused runs resumed, and the failed runs retried.
+//
+// Example:
+//   dag := wf.NewDag(
+//       wf.WithName("Order Processing"),
+//       wf.WithStateStore(store),
+//       ...
+//   )
+//   dashboard, err := webwf.NewDashboard(dag)
+//   if err != nil {
+//       return err
+//   }
+//   http.Handle("/workflows/", http.StripPrefix("/workflows", dashboard))
+//
+//   run := dashboard.Start(ctx, data)
+package webwf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dracory/wf"
+)
+
+// keepAliveInterval is the time between the comments sent on an idle event
+// stream, so proxies do not close it
+const keepAliveInterval = 15 * time.Second
+
+// subscriberBuffer is the number of events kept for a slow event stream.
+// The events are dropped when it is full, as the runs must not wait for it.
+const subscriberBuffer = 64
+
+// runsPerPage is the number of runs listed per page
+const runsPerPage = 50
+
+// Workflow is the Step, Pipeline or Dag of the runs shown by the dashboard
+type Workflow interface {
+	wf.RunnableInterface
+
+	NewRun(ctx context.Context, data map[string]any) wf.RunInterface
+	ResumeRun(ctx context.Context, runID string, data map[string]any) (wf.RunInterface, error)
+	GetStateStore() wf.StateStore
+	AddListener(listener wf.EventListener)
+}
+
+// DashboardInterface is an http.Handler serving the dashboard of the runs of a workflow:
+//   - GET / lists the runs, newest first, and GET /?page=2 the older ones
+//   - GET /runs/{id} shows the graph of a run, with its actions
+//   - GET /runs/{id}/graph.svg returns the graph of a run
+//   - GET /runs/{id}/timeline shows the timeline of a run
+//   - GET /runs/{id}/events and GET /events stream the events of a run, or of all the runs
+//   - POST /runs/{id}/pause, /resume, /cancel and /retry act on a run
+//
+// The links are relative, so the dashboard can be served under any prefix.
+// The cross-origin requests of the actions are rejected (see http.CrossOriginProtection).
+type DashboardInterface interface {
+	http.Handler
+
+	// Start starts a run of the workflow in the background, and returns it.
+	// Until it ends, the run can be paused and canceled from the dashboard.
+	Start(ctx context.Context, data map[string]any) wf.RunInterface
+
+	// Wait waits for the runs executing in the background to end
+	Wait()
+}
+
+type dashboardImplementation struct {
+	workflow Workflow
+	store    wf.StateStore
+	mux      *http.ServeMux
+
+	// handler serves the mux, protected against cross-origin requests
+	handler http.Handler
+
+	// pageSize is the number of runs listed per page
+	pageSize int
+
+	// runs executing in the background, by run ID, and the IDs of the runs
+	// an action is preparing to resume
+	runsMu   sync.Mutex
+	runs     map[string]*activeRun
+	reserved map[string]struct{}
+	wg       sync.WaitGroup
+
+	// subscribersMu guards the channels of the event streams
+	subscribersMu sync.Mutex
+	subscribers   map[chan wf.Event]struct{}
+}
+
+// activeRun is a run executing in the background
+type activeRun struct {
+	run    wf.RunInterface
+	cancel context.CancelFunc
+}
+
+var _ DashboardInterface = (*dashboardImplementation)(nil)
+
+// NewDashboard creates the dashboard of the runs of a workflow, which must
+// have a state store. The dashboard listens to the events of the workflow.
+func NewDashboard(workflow Workflow) (DashboardInterface, error) {
+	store := workflow.GetStateStore()
+	if store == nil {
+		return nil, errors.New("webwf: the workflow has no state store")
+	}
+
+	d := &dashboardImplementation{
+		workflow:    workflow,
+		store:       store,
+		mux:         http.NewServeMux(),
+		pageSize:    runsPerPage,
+		runs:        map[string]*activeRun{},
+		reserved:    map[string]struct{}{},
+		subscribers: map[chan wf.Event]struct{}{},
+	}
+
+	d.mux.HandleFunc("GET /{$}", d.handleRuns)
+	d.mux.HandleFunc("GET /events", d.handleEvents)
+	d.mux.HandleFunc("GET /runs/{id}", d.handleRun)
+	d.mux.HandleFunc("GET /runs/{id}/graph.svg", d.handleGraph)
+	d.mux.HandleFunc("GET /runs/{id}/timeline", d.handleTimeline)
+	d.mux.HandleFunc("GET /runs/{id}/events", d.handleEvents)
+	d.mux.HandleFunc("POST /runs/{id}/pause", d.handlePause)
+	d.mux.HandleFunc("POST /runs/{id}/resume", d.handleResume)
+	d.mux.HandleFunc("POST /runs/{id}/cancel", d.handleCancel)
+	d.mux.HandleFunc("POST /runs/{id}/retry", d.handleRetry)
+
+	d.handler = http.NewCrossOriginProtection().Handler(d.mux)
+
+	workflow.AddListener(d.listen)
+
+	return d, nil
+}
+
+// ServeHTTP serves the pages and actions of the dashboard
+func (d *dashboardImplementation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.handler.ServeHTTP(w, r)
+}
+
+// Start starts a run of the workflow in the background
+func (d *dashboardImplementation) Start(ctx context.Context, data map[string]any) wf.RunInterface {
+	ctx, cancel := context.WithCancel(ctx)
+	run := d.workflow.NewRun(ctx, data)
+	d.execute(run, cancel)
+	return run
+}
+
+// Wait waits for the runs executing in the background to end
+func (d *dashboardImplementation) Wait() {
+	d.wg.Wait()
+}
+
+// execute executes a run in the background, until it ends
+func (d *dashboardImplementation) execute(run wf.RunInterface, cancel context.CancelFunc) {
+	d.runsMu.Lock()
+	d.runs[run.GetID()] = &activeRun{run: run, cancel: cancel}
+	d.runsMu.Unlock()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer cancel()
+
+		// The error is in the state of the run, shown by the dashboard
+		_ = run.Execute()
+
+		d.runsMu.Lock()
+		delete(d.runs, run.GetID())
+		d.runsMu.Unlock()
+	}()
+}
+
+// activeRun returns the run with the given ID, if it is executing in the background
+func (d *dashboardImplementation) activeRun(runID string) (*activeRun, bool) {
+	d.runsMu.Lock()
+	defer d.runsMu.Unlock()
+
+	active, ok := d.runs[runID]
+	return active, ok
+}
+
+// reserve reserves the ID of a run not executing in the background, until
+// the returned function is called. While an action loads and resumes the run,
+// the other actions on it are rejected.
+func (d *dashboardImplementation) reserve(runID string) (release func(), err error) {
+	d.runsMu.Lock()
+	defer d.runsMu.Unlock()
+
+	if _, ok := d.runs[runID]; ok {
+		return nil, errRunExecuting
+	}
+	if _, ok := d.reserved[runID]; ok {
+		return nil, errRunExecuting
+	}
+
+	d.reserved[runID] = struct{}{}
+	return func() {
+		d.runsMu.Lock()
+		delete(d.reserved, runID)
+		d.runsMu.Unlock()
+	}, nil
+}
+
+// state returns the state of a run: the live state of a run executing in the
+// background, the saved state otherwise
+func (d *dashboardImplementation) state(ctx context.Context, runID string) (wf.StateInterface, error) {
+	if active, ok := d.activeRun(runID); ok {
+		return active.run.GetState(), nil
+	}
+	return d.store.Load(ctx, runID)
+}
+
+// listen sends an event of the runs to the event streams
+func (d *dashboardImplementation) listen(event wf.Event) {
+	d.subscribersMu.Lock()
+	defer d.subscribersMu.Unlock()
+
+	for events := range d.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel receiving the events of the runs, until unsubscribe is called
+func (d *dashboardImplementation) subscribe() (events chan wf.Event, unsubscribe func()) {
+	events = make(chan wf.Event, subscriberBuffer)
+
+	d.subscribersMu.Lock()
+	d.subscribers[events] = struct{}{}
+	d.subscribersMu.Unlock()
+
+	return events, func() {
+		d.subscribersMu.Lock()
+		delete(d.subscribers, events)
+		d.subscribersMu.Unlock()
+	}
+}
+
+// runSummary is a row of the list of runs
+type runSummary struct {
+	ID          string
+	URL         string
+	Status      wf.StateStatus
+	Color       string
+	Active      bool
+	StartedAt   string
+	LastUpdated string
+}
+
+// handleRuns lists the runs, the newest first. Only the states of the runs
+// of the requested page are loaded.
+func (d *dashboardImplementation) handleRuns(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+		page = parsed
+	}
+
+	ids, err := d.store.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slices.Reverse(ids)
+
+	start := min((page-1)*d.pageSize, len(ids))
+	end := min(start+d.pageSize, len(ids))
+
+	var newerURL, olderURL string
+	if page > 1 {
+		newerURL = "?page=" + strconv.Itoa(page-1)
+	}
+	if end < len(ids) {
+		olderURL = "?page=" + strconv.Itoa(page+1)
+	}
+
+	runs := []runSummary{}
+	for _, id := range ids[start:end] {
+		state, err := d.state(r.Context(), id)
+		if errors.Is(err, wf.ErrStateNotFound) {
+			continue // deleted since listed
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, active := d.activeRun(id)
+		runs = append(runs, runSummary{
+			ID:          id,
+			URL:         "runs/" + url.PathEscape(id),
+			Status:      state.GetStatus(),
+			Color:       wf.StatusColor(state.GetStatus()),
+			Active:      active,
+			StartedAt:   formatStartedAt(state),
+			LastUpdated: formatTime(state.GetLastUpdated()),
+		})
+	}
+
+	d.render(w, runsTemplate, map[string]any{
+		"Name":     d.workflow.GetName(),
+		"Runs":     runs,
+		"NewerURL": newerURL,
+		"OlderURL": olderURL,
+	})
+}
+
+// handleRun shows the graph of a run, with its actions
+func (d *dashboardImplementation) handleRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	state, ok := d.loadState(w, r, id)
+	if !ok {
+		return
+	}
+
+	_, active := d.activeRun(id)
+	status := state.GetStatus()
+	escaped := url.PathEscape(id)
+
+	var lastError string
+	if attempts, ok := state.(wf.AttemptsState); ok {
+		lastError = attempts.GetLastError()
+	}
+
+	d.render(w, runTemplate, map[string]any{
+		"Name":        d.workflow.GetName(),
+		"ID":          id,
+		"Status":      status,
+		"Color":       wf.StatusColor(status),
+		"Error":       lastError,
+		"StartedAt":   formatStartedAt(state),
+		"LastUpdated": formatTime(state.GetLastUpdated()),
+		"Graph":       graphHTML(d.workflow, state),
+		"GraphURL":    escaped + "/graph.svg",
+		"EventsURL":   escaped + "/events",
+		"TimelineURL": escaped + "/timeline",
+		"PauseURL":    escaped + "/pause",
+		"ResumeURL":   escaped + "/resume",
+		"CancelURL":   escaped + "/cancel",
+		"RetryURL":    escaped + "/retry",
+		"CanPause":    active && status == wf.StateStatusRunning,
+		"CanCancel":   active,
+		"CanResume":   !active && status == wf.StateStatusPaused,
+		"CanRetry":    !active && status == wf.StateStatusFailed,
+	})
+}
+
+// handleGraph returns the graph of a run, as an SVG image
+func (d *dashboardImplementation) handleGraph(w http.ResponseWriter, r *http.Request) {
+	state, ok := d.loadState(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, wf.VisualizeSVG(d.workflow, state))
+}
+
+// handleTimeline shows the timeline of a run
+func (d *dashboardImplementation) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	state, ok := d.loadState(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	page, err := wf.NewTimeline(d.workflow, state).ToHTML()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, page)
+}
+
+// eventMessage is an event of the runs, as sent to the event streams
+type eventMessage struct {
+	Type     wf.EventType `json:"type"`
+	RunID    string       `json:"runId"`
+	NodeID   string       `json:"nodeId"`
+	NodeName string       `json:"nodeName"`
+	Path     []string     `json:"path"`
+	Time     time.Time    `json:"time"`
+	Attempts int          `json:"attempts,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// handleEvents streams the events of a run, or of all the runs, as Server-Sent Events
+func (d *dashboardImplementation) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	runID := r.PathValue("id")
+
+	events, unsubscribe := d.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event := <-events:
+			if runID != "" && event.RunID != runID {
+				continue
+			}
+
+			message := eventMessage{
+				Type:     event.Type,
+				RunID:    event.RunID,
+				NodeID:   event.NodeID,
+				NodeName: event.NodeName,
+				Path:     event.Path,
+				Time:     event.Time,
+				Attempts: event.Attempts,
+			}
+			if event.Err != nil {
+				message.Error = event.Err.Error()
+			}
+
+			data, err := json.Marshal(message)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// handlePause pauses a run executing in the background. The running nodes
+// finish, then the run is saved as paused.
+func (d *dashboardImplementation) handlePause(w http.ResponseWriter, r *http.Request) {
+	active, ok := d.activeRun(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "the run is not executing", http.StatusConflict)
+		return
+	}
+
+	if err := active.run.Pause(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	redirectToRun(w, r)
+}
+
+// handleCancel cancels the context of a run executing in the background
+func (d *dashboardImplementation) handleCancel(w http.ResponseWriter, r *http.Request) {
+	active, ok := d.activeRun(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "the run is not executing", http.StatusConflict)
+		return
+	}
+
+	active.cancel()
+	redirectToRun(w, r)
+}
+
+// handleResume resumes a paused run in the background
+func (d *dashboardImplementation) handleResume(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	release, err := d.reserve(id)
+	if err != nil {
+		writeActionError(w, err)
+		return
+	}
+	defer release()
+
+	if err := d.resume(id); err != nil {
+		writeActionError(w, err)
+		return
+	}
+
+	redirectToRun(w, r)
+}
+
+// handleRetry runs the failed nodes of a failed run again, in the background
+func (d *dashboardImplementation) handleRetry(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	release, err := d.reserve(id)
+	if err != nil {
+		writeActionError(w, err)
+		return
+	}
+	defer release()
+
+	state, err := d.store.Load(r.Context(), id)
+	if err != nil {
+		writeActionError(w, err)
+		return
+	}
+
+	if err := wf.RetryFailed(state); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err := d.store.Save(r.Context(), id, state); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := d.resume(id); err != nil {
+		writeActionError(w, err)
+		return
+	}
+
+	redirectToRun(w, r)
+}
+
+// resume resumes a saved run in the background. The ID of the run must be
+// reserved, the reservation can be released once the run is executing.
+func (d *dashboardImplementation) resume(runID string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	run, err := d.workflow.ResumeRun(ctx, runID, nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	d.execute(run, cancel)
+	return nil
+}
+
+// errRunExecuting is returned by the actions that need a run not executing in the background
+var errRunExecuting = errors.New("the run is executing")
+
+// loadState returns the state of a run, or writes the error
+func (d *dashboardImplementation) loadState(w http.ResponseWriter, r *http.Request, runID string) (wf.StateInterface, bool) {
+	state, err := d.state(r.Context(), runID)
+	if errors.Is(err, wf.ErrStateNotFound) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return state, true
+}
+
+// writeActionError writes the error of an action on a run: not found, or
+// not possible in the current status of the run
+func writeActionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, wf.ErrStateNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusConflict)
+}
+
+// redirectToRun redirects an action to the page of its run. The location is
+// relative (../{id}), as http.Redirect would drop the prefix the dashboard is
+// served under.
+func redirectToRun(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Location", "../"+url.PathEscape(r.PathValue("id")))
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+// formatStartedAt formats when a run started, empty if its state does not record it
+func formatStartedAt(state wf.StateInterface) string {
+	if timed, ok := state.(wf.TimedState); ok {
+		return formatTime(timed.GetStartedAt())
+	}
+	return ""
+}
+
+// formatTime formats a time for the pages, empty if it is zero
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
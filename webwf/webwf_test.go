@@ -0,0 +1,412 @@
+package webwf
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dracory/wf"
+)
+
+// newTestDashboard returns a dashboard of a DAG of 3 steps, whose second step
+// waits for the release channel, or for its context to be canceled, and fails
+// once when fail is set
+func newTestDashboard(t *testing.T, fail bool) (*dashboardImplementation, chan struct{}, *httptest.Server) {
+	t.Helper()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	failed := false
+
+	validate := wf.NewStep(wf.WithID("validate"), wf.WithName("Validate"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+	charge := wf.NewStep(wf.WithID("charge"), wf.WithName("Charge"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx, data, ctx.Err()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if fail && !failed {
+			failed = true
+			return ctx, data, errors.New("card declined")
+		}
+		return ctx, data, nil
+	}))
+
+	ship := wf.NewStep(wf.WithID("ship"), wf.WithName("Ship"), wf.WithHandler(func(ctx context.Context, data map[string]any) (context.Context, map[string]any, error) {
+		return ctx, data, nil
+	}))
+
+	dag := wf.NewDag(
+		wf.WithName("Orders"),
+		wf.WithRunnables(validate, charge, ship),
+		wf.WithDependency(charge, validate),
+		wf.WithDependency(ship, charge),
+		wf.WithStateStore(wf.NewMemoryStateStore()),
+	)
+
+	dashboard, err := NewDashboard(dag)
+	if err != nil {
+		t.Fatalf("NewDashboard failed: %v", err)
+	}
+
+	server := httptest.NewServer(http.StripPrefix("/workflows", dashboard))
+	t.Cleanup(server.Close)
+
+	return dashboard.(*dashboardImplementation), release, server
+}
+
+// noRedirects is an HTTP client returning the redirects of the actions
+var noRedirects = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer response.Body.Close()
+
+	var body strings.Builder
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		body.WriteString(scanner.Text() + "\n")
+	}
+	return response.StatusCode, body.String()
+}
+
+func post(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	response, err := noRedirects.Post(url, "", nil)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", url, err)
+	}
+	response.Body.Close()
+	return response
+}
+
+// waitForStatus waits for the saved state of a run to have the given status
+func waitForStatus(t *testing.T, d *dashboardImplementation, runID string, status wf.StateStatus) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, active := d.activeRun(runID); !active {
+			if state, err := d.store.Load(context.Background(), runID); err == nil && state.GetStatus() == status {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Run %s did not become %s", runID, status)
+}
+
+func Test_NewDashboard_NoStore(t *testing.T) {
+	if _, err := NewDashboard(wf.NewDag()); err == nil {
+		t.Error("Expected an error for a workflow without a state store")
+	}
+}
+
+func Test_Dashboard_Pages(t *testing.T) {
+	d, release, server := newTestDashboard(t, false)
+
+	run := d.Start(context.Background(), map[string]any{})
+	runURL := server.URL + "/workflows/runs/" + run.GetID()
+
+	// The live state of the run, before it is saved
+	if status, body := get(t, server.URL+"/workflows/"); status != http.StatusOK || !strings.Contains(body, `<a href="runs/`+run.GetID()+`">`) || !strings.Contains(body, "<h1>Orders</h1>") {
+		t.Errorf("Unexpected list of runs (%d):\n%s", status, body)
+	}
+
+	status, body := get(t, runURL)
+	if status != http.StatusOK || !strings.Contains(body, "<svg") || !strings.Contains(body, ">Charge</text>") {
+		t.Errorf("Unexpected page of the run (%d):\n%s", status, body)
+	}
+	if !strings.Contains(body, `action="`+run.GetID()+`/pause"`) || !strings.Contains(body, `action="`+run.GetID()+`/cancel"`) || strings.Contains(body, "/retry") {
+		t.Errorf("Expected the pause and cancel actions of a running run:\n%s", body)
+	}
+
+	if status, body := get(t, runURL+"/graph.svg"); status != http.StatusOK || !strings.HasPrefix(body, "<svg") {
+		t.Errorf("Unexpected graph (%d):\n%s", status, body)
+	}
+
+	close(release)
+	d.Wait()
+	waitForStatus(t, d, run.GetID(), wf.StateStatusComplete)
+
+	if status, body := get(t, runURL+"/timeline"); status != http.StatusOK || !strings.Contains(body, "Charge (complete)") {
+		t.Errorf("Unexpected timeline (%d):\n%s", status, body)
+	}
+
+	if status, _ := get(t, server.URL+"/workflows/runs/unknown"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown run, got %d", status)
+	}
+}
+
+func Test_Dashboard_RunsPages(t *testing.T) {
+	d, _, server := newTestDashboard(t, false)
+	d.pageSize = 2
+
+	for _, id := range []string{"run-1", "run-2", "run-3"} {
+		if err := d.store.Save(context.Background(), id, wf.NewState()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The newest runs are listed first
+	status, body := get(t, server.URL+"/workflows/")
+	if status != http.StatusOK || !strings.Contains(body, `href="runs/run-3"`) || !strings.Contains(body, `href="runs/run-2"`) || strings.Contains(body, `href="runs/run-1"`) {
+		t.Errorf("Unexpected first page of runs (%d):\n%s", status, body)
+	}
+	if !strings.Contains(body, `href="?page=2"`) || strings.Contains(body, "Newer runs") {
+		t.Errorf("Expected a link to the older runs only:\n%s", body)
+	}
+
+	status, body = get(t, server.URL+"/workflows/?page=2")
+	if status != http.StatusOK || !strings.Contains(body, `href="runs/run-1"`) || strings.Contains(body, `href="runs/run-2"`) {
+		t.Errorf("Unexpected second page of runs (%d):\n%s", status, body)
+	}
+	if !strings.Contains(body, `href="?page=1"`) || strings.Contains(body, "Older runs") {
+		t.Errorf("Expected a link to the newer runs only:\n%s", body)
+	}
+
+	if status, _ := get(t, server.URL+"/workflows/?page=0"); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid page, got %d", status)
+	}
+}
+
+func Test_Dashboard_CrossOrigin(t *testing.T) {
+	d, release, server := newTestDashboard(t, false)
+	defer d.Wait()
+	defer close(release)
+
+	run := d.Start(context.Background(), map[string]any{})
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/workflows/runs/"+run.GetID()+"/cancel", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Sec-Fetch-Site", "cross-site")
+
+	response, err := noRedirects.Do(request)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a cross-origin action, got %d", response.StatusCode)
+	}
+	if _, active := d.activeRun(run.GetID()); !active {
+		t.Error("Expected the run not to be canceled")
+	}
+}
+
+func Test_Dashboard_Events(t *testing.T) {
+	d, release, server := newTestDashboard(t, false)
+	close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/workflows/events", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", contentType)
+	}
+
+	run := d.Start(context.Background(), map[string]any{})
+
+	types := []wf.EventType{}
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		message := eventMessage{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &message); err != nil {
+			t.Fatalf("Invalid event %q: %v", line, err)
+		}
+		if message.RunID != run.GetID() {
+			t.Errorf("Unexpected run ID %q", message.RunID)
+		}
+
+		types = append(types, message.Type)
+		if message.Type == wf.EventWorkflowCompleted {
+			break
+		}
+	}
+
+	if len(types) < 2 || types[0] != wf.EventWorkflowStarted || types[len(types)-1] != wf.EventWorkflowCompleted {
+		t.Errorf("Unexpected events: %v", types)
+	}
+	d.Wait()
+}
+
+func Test_Dashboard_PauseResume(t *testing.T) {
+	d, release, server := newTestDashboard(t, false)
+
+	run := d.Start(context.Background(), map[string]any{})
+	runURL := server.URL + "/workflows/runs/" + run.GetID()
+
+	response := post(t, runURL+"/pause")
+	if response.StatusCode != http.StatusSeeOther || response.Header.Get("Location") != "../"+run.GetID() {
+		t.Fatalf("Unexpected response to pause: %d %q", response.StatusCode, response.Header.Get("Location"))
+	}
+
+	close(release)
+	d.Wait()
+	waitForStatus(t, d, run.GetID(), wf.StateStatusPaused)
+
+	if _, body := get(t, runURL); !strings.Contains(body, `action="`+run.GetID()+`/resume"`) {
+		t.Errorf("Expected the resume action of a paused run:\n%s", body)
+	}
+	if response := post(t, runURL+"/pause"); response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a conflict pausing a paused run, got %d", response.StatusCode)
+	}
+
+	if response := post(t, runURL+"/resume"); response.StatusCode != http.StatusSeeOther {
+		t.Fatalf("Unexpected response to resume: %d", response.StatusCode)
+	}
+	d.Wait()
+	waitForStatus(t, d, run.GetID(), wf.StateStatusComplete)
+
+	if response := post(t, runURL+"/resume"); response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a conflict resuming a completed run, got %d", response.StatusCode)
+	}
+}
+
+func Test_Dashboard_CancelRetry(t *testing.T) {
+	d, release, server := newTestDashboard(t, true)
+
+	run := d.Start(context.Background(), map[string]any{})
+	runURL := server.URL + "/workflows/runs/" + run.GetID()
+
+	if response := post(t, runURL+"/cancel"); response.StatusCode != http.StatusSeeOther {
+		t.Fatalf("Unexpected response to cancel: %d", response.StatusCode)
+	}
+	d.Wait()
+	waitForStatus(t, d, run.GetID(), wf.StateStatusFailed)
+
+	if _, body := get(t, runURL); !strings.Contains(body, `action="`+run.GetID()+`/retry"`) {
+		t.Errorf("Expected the retry action of a failed run:\n%s", body)
+	}
+
+	// The first retry fails with the error of the step, the second completes
+	close(release)
+	if response := post(t, runURL+"/retry"); response.StatusCode != http.StatusSeeOther {
+		t.Fatalf("Unexpected response to retry: %d", response.StatusCode)
+	}
+	d.Wait()
+	waitForStatus(t, d, run.GetID(), wf.StateStatusFailed)
+
+	if response := post(t, runURL+"/retry"); response.StatusCode != http.StatusSeeOther {
+		t.Fatalf("Unexpected response to retry: %d", response.StatusCode)
+	}
+	d.Wait()
+	waitForStatus(t, d, run.GetID(), wf.StateStatusComplete)
+
+	if response := post(t, runURL+"/retry"); response.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a conflict retrying a completed run, got %d", response.StatusCode)
+	}
+	if response := post(t, server.URL+"/workflows/runs/unknown/retry"); response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 retrying an unknown run, got %d", response.StatusCode)
+	}
+}
+
+func Test_Dashboard_ConcurrentRetries(t *testing.T) {
+	d, release, server := newTestDashboard(t, true)
+
+	run := d.Start(context.Background(), map[string]any{})
+	runURL := server.URL + "/workflows/runs/" + run.GetID()
+
+	if response := post(t, runURL+"/cancel"); response.StatusCode != http.StatusSeeOther {
+		t.Fatalf("Unexpected response to cancel: %d", response.StatusCode)
+	}
+	d.Wait()
+	waitForStatus(t, d, run.GetID(), wf.StateStatusFailed)
+
+	// Only one of the concurrent retries starts the run, which waits for the
+	// release channel, the others are rejected. The slow loads and resumes
+	// leave the retries time to overlap.
+	d.store = slowStore{d.store}
+	d.workflow = slowWorkflow{d.workflow}
+	var wg sync.WaitGroup
+	codes := make(chan int, 10)
+	for range 10 {
+		wg.Go(func() {
+			response, err := noRedirects.Post(runURL+"/retry", "", nil)
+			if err != nil {
+				t.Errorf("POST retry failed: %v", err)
+				return
+			}
+			response.Body.Close()
+			codes <- response.StatusCode
+		})
+	}
+	wg.Wait()
+	close(codes)
+
+	started := 0
+	for code := range codes {
+		switch code {
+		case http.StatusSeeOther:
+			started++
+		case http.StatusConflict:
+		default:
+			t.Errorf("Unexpected response to retry: %d", code)
+		}
+	}
+	if started != 1 {
+		t.Errorf("Expected one retry to start the run, got %d", started)
+	}
+
+	close(release)
+	d.Wait()
+}
+
+// slowStore is a state store whose loads take some time
+type slowStore struct {
+	wf.StateStore
+}
+
+func (s slowStore) Load(ctx context.Context, runID string) (wf.StateInterface, error) {
+	state, err := s.StateStore.Load(ctx, runID)
+	time.Sleep(20 * time.Millisecond)
+	return state, err
+}
+
+// slowWorkflow is a workflow whose runs take some time to resume
+type slowWorkflow struct {
+	Workflow
+}
+
+func (w slowWorkflow) ResumeRun(ctx context.Context, runID string, data map[string]any) (wf.RunInterface, error) {
+	time.Sleep(20 * time.Millisecond)
+	return w.Workflow.ResumeRun(ctx, runID, data)
+}